@@ -19,16 +19,124 @@ package v1
 import (
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"fmt"
+	"sort"
 	"strconv"
 )
 
+const (
+	// AllowPrivilegedAnnotation 设置为"true"时，webhook才允许容器以privileged模式运行
+	AllowPrivilegedAnnotation = "elasticweb.com.bolingcavalry/allow-privileged"
+	// AllowDisruptiveUpdateAnnotation 设置为"true"时，webhook放行会造成服务中断的修改，例如totalQPS一次缩减过多
+	AllowDisruptiveUpdateAnnotation = "elasticweb.com.bolingcavalry/allow-disruptive-update"
+	// ConfirmDeleteAnnotation 设置为"true"时，开启了删除保护的ElasticWeb才允许被删除
+	ConfirmDeleteAnnotation = "elasticweb.com.bolingcavalry/confirm-delete"
+
+	// ManagedAnnotationsAnnotation 记录controller在ServiceAccount上设置过的注解key（JSON数组），
+	// 从spec.serviceAccount.annotations中去掉的key据此从ServiceAccount上删除
+	ManagedAnnotationsAnnotation = "elasticweb.com.bolingcavalry/managed-annotations"
+
+	// NameLabel 标记pod属于哪个ElasticWeb，值为ElasticWeb的名称，status.selector按它选择pod
+	NameLabel = "elasticweb.com.bolingcavalry/name"
+
+	// CalibrationLabel 标记校准用的pod，值为ElasticWeb的名称，这些pod没有NameLabel，不接入service
+	CalibrationLabel = "elasticweb.com.bolingcavalry/calibration"
+
+	// VariantLabel 标记deployMode为Variants时pod属于哪个变体，值为spec.deploy中的name
+	VariantLabel = "elasticweb.com.bolingcavalry/variant"
+
+	// PlacementLabel 标记成员集群中由hub派生的ElasticWeb，值为hub上ElasticWeb的UID，hub只修改和删除带有该标签的对象
+	PlacementLabel = "elasticweb.com.bolingcavalry/placed-by"
+	// PlacementHashAnnotation 记录派生ElasticWeb的内容摘要，摘要不变时hub不覆盖成员集群中webhook填充的默认值
+	PlacementHashAnnotation = "elasticweb.com.bolingcavalry/placement-hash"
+	// PlacementFinalizer 保证hub上的ElasticWeb被删除或去掉spec.placement前，先删除成员集群中派生的ElasticWeb
+	PlacementFinalizer = "elasticweb.com.bolingcavalry/placement"
+	// OrphanPlacementAnnotation 设置为"true"时，manager没有开启hub模式也允许去掉PlacementFinalizer，
+	// 成员集群中派生的ElasticWeb不会被删除，需要手工清理
+	OrphanPlacementAnnotation = "elasticweb.com.bolingcavalry/orphan-placement"
+)
+
+// DeployMode 决定spec.deploy中的条目如何部署
+// +kubebuilder:validation:Enum=Containers;Variants
+type DeployMode string
+
+const (
+	// DeployModeContainers 所有条目是同一个pod中的容器，只有一个deployment，这是默认值
+	DeployModeContainers DeployMode = "Containers"
+	// DeployModeVariants 每个条目是一个变体（例如新旧两个版本的镜像），各自有一个deployment，
+	// 副本数按weight分配，所有变体的pod都在同一个service后面
+	DeployModeVariants DeployMode = "Variants"
+)
+
+// CalibrationPhase 是singlePodQPS校准的阶段
+// +kubebuilder:validation:Enum=Running;Succeeded;Failed
+type CalibrationPhase string
+
+const (
+	CalibrationRunning   CalibrationPhase = "Running"
+	CalibrationSucceeded CalibrationPhase = "Succeeded"
+	CalibrationFailed    CalibrationPhase = "Failed"
+)
+
+const (
+	// ConditionReady 期望的pod全部ready时为True
+	ConditionReady = "Ready"
+
+	// Ready condition的reason
+	ReasonPodsReady    = "PodsReady"
+	ReasonPodsNotReady = "PodsNotReady"
+	ReasonScaledToZero = "ScaledToZero"
+
+	// ConditionPaused spec.paused为true、controller不再修改下属资源时为True
+	ConditionPaused = "Paused"
+
+	// Paused condition的reason
+	ReasonPaused    = "Paused"
+	ReasonNotPaused = "NotPaused"
+
+	// ConditionIdle 开启了spec.idle、因为没有流量缩容到0时为True
+	ConditionIdle = "Idle"
+
+	// Idle condition的reason
+	ReasonNoTraffic        = "NoTraffic"
+	ReasonReceivingTraffic = "ReceivingTraffic"
+	ReasonWoken            = "Woken"
+	ReasonIdleUnavailable  = "IdleUnavailable"
+
+	// ConditionRouteAccepted 设置了spec.route、HTTPRoute被所有parentRefs指向的Gateway接受时为True
+	ConditionRouteAccepted = "RouteAccepted"
+
+	// RouteAccepted condition的reason
+	ReasonRouteAccepted         = "Accepted"
+	ReasonRouteNotAccepted      = "NotAccepted"
+	ReasonRoutePending          = "Pending"
+	ReasonGatewayAPIUnavailable = "GatewayAPIUnavailable"
+
+	// ConditionPlaced 设置了spec.placement时，所有成员集群中派生的ElasticWeb都已同步时为True
+	ConditionPlaced = "Placed"
+
+	// Placed condition的reason
+	ReasonPlaced          = "Placed"
+	ReasonPlacementFailed = "PlacementFailed"
+	ReasonHubDisabled     = "HubDisabled"
+)
+
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 // ElasticWebSpec defines the desired state of ElasticWeb.
+// 以下schema校验在webhook关闭（ENABLE_WEBHOOKS=false）时仍然生效，webhook的校验是它的超集
+// +kubebuilder:validation:XValidation:rule="self.totalQPS == 0 || self.totalQPS >= self.singlePodQPS",message="totalQPS must be 0 or at least singlePodQPS"
+// +kubebuilder:validation:XValidation:rule="self.service.ports.all(p, self.deploy.exists(d, has(d.ports) && d.ports.exists(c, (c.port == p.targetport || (size(c.name) > 0 && c.name == p.targetport)) && (has(c.protocol) ? c.protocol : 'TCP') == (has(p.protocol) ? p.protocol : 'TCP'))))",message="every service targetport must match the number or name of a port with the same protocol declared in deploy[].ports"
+// +kubebuilder:validation:XValidation:rule="(has(self.deployMode) && self.deployMode == 'Variants') || self.deploy.all(d, !has(d.weight))",message="weight can only be set when deployMode is Variants"
+// +kubebuilder:validation:XValidation:rule="!has(self.deployMode) || self.deployMode != 'Variants' || self.service.ports.all(p, self.deploy.all(d, has(d.ports) && d.ports.exists(c, (c.port == p.targetport || (size(c.name) > 0 && c.name == p.targetport)) && (has(c.protocol) ? c.protocol : 'TCP') == (has(p.protocol) ? p.protocol : 'TCP'))))",message="in Variants mode every service targetport must match a port of every variant"
+// +kubebuilder:validation:XValidation:rule="!has(self.deployMode) || self.deployMode != 'Variants' || self.deploy.exists(d, !has(d.weight) || d.weight > 0)",message="in Variants mode at least one variant must have a weight greater than 0"
 type ElasticWebSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
@@ -36,51 +144,709 @@ type ElasticWebSpec struct {
 	// Foo is an example field of ElasticWeb. Edit elasticweb_types.go to remove/update
 	// Foo string `json:"foo,omitempty"`
 
-	// 业务服务对应的镜像，包括tag
-	SinglePodQPS *int32                 `json:"singlePodQPS"`
-	TotalQPS     *int32                 `json:"totalQPS"`
-	Deploy       []ElasticWebSpecDeploy `json:"deploy"`
-	Service      ElasticWebSpecSvc      `json:"service"`
+	// 单个pod能承载的QPS，上限与webhook的maxSinglePodQPS一致
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000
+	SinglePodQPS *int32 `json:"singlePodQPS"`
+
+	// 期望的总QPS，为0时不运行pod
+	// +kubebuilder:validation:Minimum=0
+	TotalQPS *int32 `json:"totalQPS"`
+
+	// pod中的容器，deployMode为Variants时每一项是一个变体
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=32
+	// +listType=map
+	// +listMapKey=name
+	Deploy []ElasticWebSpecDeploy `json:"deploy"`
+
+	// spec.deploy的部署方式，默认Containers：所有条目在同一个pod中；
+	// Variants：每个条目单独一个名为<ElasticWeb名称>-<条目名称>的deployment，用于A/B实验
+	// +optional
+	DeployMode DeployMode `json:"deployMode,omitempty"`
+
+	Service ElasticWebSpecSvc `json:"service"`
+
+	// Gateway API：设置后controller创建一个同名的HTTPRoute，把请求转发到ElasticWeb的service
+	// +optional
+	Route *ElasticWebSpecRoute `json:"route,omitempty"`
+
+	// 设置后controller创建一个同名的NetworkPolicy，只允许指定的来源访问service的端口
+	// +optional
+	NetworkPolicy *ElasticWebSpecNetworkPolicy `json:"networkPolicy,omitempty"`
+
+	// 副本数，kubectl scale、HPA、KEDA通过scale子资源修改它；
+	// 设置后副本数不再按totalQPS/singlePodQPS计算，清空后恢复按QPS计算
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// 冗余容量：在totalQPS之外预留余量、备用pod和可用区冗余，副本数按它们一起计算；
+	// 设置了replicas时副本数不再计算，只有可用区分布仍然生效
+	// +optional
+	CapacityPolicy *ElasticWebSpecCapacityPolicy `json:"capacityPolicy,omitempty"`
+
+	// pod级别的存储卷，容器通过volumeMounts引用
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Volumes []ElasticWebSpecVolume `json:"volumes,omitempty"`
+
+	// pod级别的安全配置，未设置的字段由webhook按加固策略填充
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// pod使用的ServiceAccount，不设置时：serviceAccount.create为true则使用controller创建的同名ServiceAccount，否则使用namespace的default
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// 拉取私有仓库镜像用的secret
+	// +listType=atomic
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// 是否由controller为该ElasticWeb创建专用的ServiceAccount
+	// +kubebuilder:validation:XValidation:rule="self.create || !has(self.annotations) || size(self.annotations) == 0",message="annotations can only be set when create is true"
+	// +optional
+	ServiceAccount *ElasticWebSpecServiceAccount `json:"serviceAccount,omitempty"`
+
+	// 为true时controller把镜像的tag解析成digest，pod使用image@digest，
+	// 只有tag指向的digest变化时才会滚动更新，解析结果记录在status.imageDigests
+	// +optional
+	PinImageDigests bool `json:"pinImageDigests,omitempty"`
+
+	// 空闲缩容：连续一段时间没有流量后缩容到0，service改为指向activator，
+	// 第一个请求到达时activator唤醒并扩容，pod ready后再转发请求
+	// +optional
+	Idle *ElasticWebSpecIdle `json:"idle,omitempty"`
+
+	// 压测校准singlePodQPS，每次容器镜像变化后执行一次
+	// +optional
+	Calibration *ElasticWebSpecCalibration `json:"calibration,omitempty"`
+
+	// 暂停：为true时controller不再创建或修改deployment、service等下属资源，只更新status，
+	// 用于故障处理时冻结某个服务；改回false后恢复正常调谐
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// 删除保护：为true时，如果还有ready的pod或totalQPS不为0，webhook拒绝删除，
+	// 除非设置了confirm-delete注解
+	// +optional
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+
+	// 多集群部署：设置后本集群（hub）不运行pod，而是在每个成员集群中创建一个派生的ElasticWeb，
+	// totalQPS（或replicas）按share分配，status汇总所有成员集群的状态；
+	// manager没有开启hub模式时无法清理成员集群，删除会被finalizer阻塞，设置orphan-placement注解后放行
+	// +optional
+	Placement *ElasticWebSpecPlacement `json:"placement,omitempty"`
+}
+
+// ElasticWebRouteParentStatus 是HTTPRoute在一个Gateway上的状态
+type ElasticWebRouteParentStatus struct {
+	ParentRef ElasticWebSpecRouteParentRef `json:"parentRef"`
+
+	// 处理这个Gateway的controller
+	// +optional
+	ControllerName string `json:"controllerName,omitempty"`
+
+	// Gateway controller设置的Accepted、ResolvedRefs等condition
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ElasticWebVariantStatus 是一个变体的deployment的状态
+type ElasticWebVariantStatus struct {
+	// spec.deploy中的name
+	Name string `json:"name"`
+
+	// 按权重分到的副本数
+	DesiredReplicas int32 `json:"desiredReplicas"`
+
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+}
+
+// ElasticWebSpecCapacityPolicy 描述副本数在totalQPS/singlePodQPS之外预留的冗余，
+// 保证失去sparePods个pod，或者失去一个可用区后剩下的pod仍能承载totalQPS
+type ElasticWebSpecCapacityPolicy struct {
+	// QPS余量百分比，例如20表示按totalQPS的120%计算副本数，用来应对流量突增和滚动更新
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1000
+	// +optional
+	HeadroomPercent int32 `json:"headroomPercent,omitempty"`
+
+	// 额外的备用pod数量（N+k）
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	SparePods int32 `json:"sparePods,omitempty"`
+
+	// pod分布的可用区数量，大于1时pod均匀分布到各可用区，副本数保证失去任意一个可用区后仍能承载totalQPS
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=16
+	// +optional
+	Zones int32 `json:"zones,omitempty"`
+
+	// 区分可用区的节点标签，默认topology.kubernetes.io/zone
+	// +optional
+	ZoneTopologyKey string `json:"zoneTopologyKey,omitempty"`
+}
+
+// ElasticWebSpecIdle 是空闲缩容到0的配置
+// +kubebuilder:validation:XValidation:rule="duration(self.after) > duration('0s')",message="after must be greater than 0"
+type ElasticWebSpecIdle struct {
+	// 连续多长时间没有观测到流量后缩容到0，例如15m
+	After metav1.Duration `json:"after"`
+}
+
+// ElasticWebSpecServiceAccount 描述controller创建并持有的ServiceAccount
+type ElasticWebSpecServiceAccount struct {
+	// 为true时controller创建名为serviceAccountName（未设置时为ElasticWeb名称）的ServiceAccount
+	Create bool `json:"create"`
+
+	// ServiceAccount上的注解，例如workload identity需要的iam.gke.io/gcp-service-account、eks.amazonaws.com/role-arn
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 type ElasticWebSpecDeploy struct {
-	Name  string                      `json:"name"`
-	Image string                      `json:"image"`
+	// 容器名称，DNS-1123 label
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// 容器端口，和corev1.Container一样按端口号区分，name可以不填
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=port
 	Ports []ElasticWebSpecDeployPorts `json:"ports"`
+
+	// 容器的资源申请和上限，不设置时由webhook按默认策略填充，都没有时使用controller内置的值
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// +optional
+	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+	// +optional
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+
+	// 镜像拉取策略，不设置时为IfNotPresent
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	PullPolicy corev1.PullPolicy `json:"pullPolicy,omitempty"`
+
+	// 容器内的挂载点，name必须是spec.volumes中声明过的
+	// +optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// 容器级别的安全配置，未设置的字段由webhook按加固策略填充，显式设置的值不会被覆盖
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// deployMode为Variants时该变体的权重，副本数按各变体权重的比例分配，未设置时为1，为0时不运行pod
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1000
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
 }
 
 type ElasticWebSpecDeployPorts struct {
+	// IANA_SVC_NAME，可以为空
+	// +kubebuilder:validation:MaxLength=15
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?)?$`
 	Name string `json:"name"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
 	Port *int32 `json:"port"`
+
+	// 默认TCP
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	// +optional
+	Protocol string `json:"protocol,omitempty"`
+
+	// 应用层协议，例如h2c、grpc、kubernetes.io/ws，转发到该端口的service端口没有设置appProtocol时使用它
+	// +kubebuilder:validation:MaxLength=316
+	// +optional
+	AppProtocol string `json:"appProtocol,omitempty"`
 }
 
-// type ElasticWebSpecDeployResources struct {
-// 	Requests []ElasticWebSpecDeployResourcesRequests
-// 	Limits
-// }
+// ElasticWebSpecVolume 描述一个pod级别的存储卷，以下来源只能指定一个
+// +kubebuilder:validation:XValidation:rule="[has(self.emptyDir), has(self.configMap), has(self.secret), has(self.projected), has(self.persistentVolumeClaim), has(self.claimTemplate)].filter(x, x).size() == 1",message="must specify exactly one of emptyDir, configMap, secret, projected, persistentVolumeClaim, claimTemplate"
+type ElasticWebSpecVolume struct {
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// +optional
+	EmptyDir *corev1.EmptyDirVolumeSource `json:"emptyDir,omitempty"`
+	// +optional
+	ConfigMap *corev1.ConfigMapVolumeSource `json:"configMap,omitempty"`
+	// +optional
+	Secret *corev1.SecretVolumeSource `json:"secret,omitempty"`
+	// +optional
+	Projected *corev1.ProjectedVolumeSource `json:"projected,omitempty"`
+	// 引用一个已经存在的PVC
+	// +optional
+	PersistentVolumeClaim *corev1.PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim,omitempty"`
+	// 由controller按模板为每个ElasticWeb创建并持有的PVC，名称为<elasticweb名称>-<volume名称>
+	// +optional
+	ClaimTemplate *ElasticWebSpecVolumeClaimTemplate `json:"claimTemplate,omitempty"`
+}
+
+// ElasticWebSpecVolumeClaimTemplate 是controller创建PVC时使用的模板
+type ElasticWebSpecVolumeClaimTemplate struct {
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	Spec corev1.PersistentVolumeClaimSpec `json:"spec"`
+}
 
 type ElasticWebSpecSvc struct {
-	Type  string                   `json:"type"`
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	Type string `json:"type"`
+
+	// 只有一个端口时name可以为空，多个端口时name必须唯一
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=name
 	Ports []ElasticWebSpecSvcPorts `json:"ports"`
+
+	// 只能为None，表示headless service，DNS直接返回pod的IP；不设置时自动分配clusterIP。
+	// clusterIP不能修改，切换时controller会删除并重建service
+	// +kubebuilder:validation:Enum=None
+	// +optional
+	ClusterIP string `json:"clusterIP,omitempty"`
+
+	// ClientIP时同一个客户端的请求转发到同一个pod，默认None
+	// +kubebuilder:validation:Enum=None;ClientIP
+	// +optional
+	SessionAffinity string `json:"sessionAffinity,omitempty"`
+
+	// sessionAffinity为ClientIP时会话保持的秒数，默认10800
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	// +optional
+	SessionAffinityTimeoutSeconds *int32 `json:"sessionAffinityTimeoutSeconds,omitempty"`
+
+	// 只对NodePort和LoadBalancer有效，Local时保留客户端源IP，只转发到本节点的pod，默认Cluster
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// +optional
+	ExternalTrafficPolicy string `json:"externalTrafficPolicy,omitempty"`
+
+	// Local时集群内的请求只转发到本节点的pod，默认Cluster
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// +optional
+	InternalTrafficPolicy string `json:"internalTrafficPolicy,omitempty"`
+
+	// 只对LoadBalancer有效，允许访问的客户端网段
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=set
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// 双栈集群中service使用的IP协议族，默认SingleStack
+	// +kubebuilder:validation:Enum=SingleStack;PreferDualStack;RequireDualStack
+	// +optional
+	IPFamilyPolicy string `json:"ipFamilyPolicy,omitempty"`
+}
+
+// ElasticWebSpecRoute 描述controller生成的HTTPRoute，只有一条规则：匹配matches的请求按权重转发到各个后端
+type ElasticWebSpecRoute struct {
+	// HTTPRoute挂载到的Gateway
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=32
+	ParentRefs []ElasticWebSpecRouteParentRef `json:"parentRefs"`
+
+	// 匹配的Host，可以以*.开头
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=set
+	// +optional
+	Hostnames []ElasticWebSpecRouteHostname `json:"hostnames,omitempty"`
+
+	// 匹配的路径，任意一个匹配即可，不设置时匹配所有请求
+	// +kubebuilder:validation:MaxItems=8
+	// +optional
+	Matches []ElasticWebSpecRoutePathMatch `json:"matches,omitempty"`
+
+	// 转发到ElasticWeb的service的哪个端口，默认spec.service.ports中的第一个
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	ServicePort *int32 `json:"servicePort,omitempty"`
+
+	// ElasticWeb的service的权重，默认1，和backends一起按比例分配请求
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1000000
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+
+	// 同一namespace下的其他后端service，例如另一个ElasticWeb的service，用于蓝绿发布
+	// +kubebuilder:validation:MaxItems=15
+	// +optional
+	Backends []ElasticWebSpecRouteBackend `json:"backends,omitempty"`
+}
+
+// ElasticWebSpecRouteHostname 是Gateway API的Hostname
+// +kubebuilder:validation:MinLength=1
+// +kubebuilder:validation:MaxLength=253
+// +kubebuilder:validation:Pattern=`^(\*\.)?[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+type ElasticWebSpecRouteHostname string
+
+// ElasticWebSpecRouteParentRef 指向一个Gateway
+type ElasticWebSpecRouteParentRef struct {
+	// Gateway的名称
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Name string `json:"name"`
+
+	// Gateway所在的namespace，默认和ElasticWeb相同
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Gateway的listener名称，不设置时挂载到所有允许的listener
+	// +kubebuilder:validation:MaxLength=253
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+
+	// Gateway的listener端口
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+}
+
+// ElasticWebSpecRoutePathMatch 是一个路径匹配条件
+type ElasticWebSpecRoutePathMatch struct {
+	// 匹配方式，默认PathPrefix
+	// +kubebuilder:validation:Enum=Exact;PathPrefix;RegularExpression
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// +kubebuilder:validation:MaxLength=1024
+	// +kubebuilder:validation:Pattern=`^/`
+	Value string `json:"value"`
+}
+
+// ElasticWebSpecRouteBackend 是ElasticWeb的service之外的一个后端
+type ElasticWebSpecRouteBackend struct {
+	// 同一namespace下的service名称
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// 权重，默认1
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1000000
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+}
+
+// ElasticWebSpecPlacement 描述ElasticWeb分布在哪些成员集群中
+type ElasticWebSpecPlacement struct {
+	// 成员集群，每个集群运行一个同名的派生ElasticWeb
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:XValidation:rule="self.exists(c, !has(c.share) || c.share > 0)",message="at least one cluster must have a share greater than 0"
+	// +listType=map
+	// +listMapKey=name
+	Clusters []ElasticWebSpecPlacementCluster `json:"clusters"`
+}
+
+type ElasticWebSpecPlacementCluster struct {
+	// 成员集群的名称，也是manager所在namespace中保存该集群kubeconfig的Secret的名称
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// 分到的QPS份额，默认1；为0时保留派生的ElasticWeb但不运行pod，用于把流量从某个集群撤走
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Share *int32 `json:"share,omitempty"`
+}
+
+// ElasticWebSpecNetworkPolicy 描述controller生成的NetworkPolicy，它选中该ElasticWeb的所有pod（包括各个变体）：
+// 入口流量只放行from中的来源、Ingress controller和activator，且只能访问service的targetport；没有任何来源时拒绝所有入口流量
+type ElasticWebSpecNetworkPolicy struct {
+	// 允许访问的来源
+	// +kubebuilder:validation:MaxItems=32
+	// +optional
+	From []ElasticWebSpecNetworkPolicyPeer `json:"from,omitempty"`
+
+	// 是否放行manager配置的Ingress controller（--ingress-controller-namespace），默认true
+	// +optional
+	AllowIngressController *bool `json:"allowIngressController,omitempty"`
+
+	// 设置后pod只能访问这些目的地址，以及任意地址的53端口（DNS）；不设置时不限制出口流量
+	// +kubebuilder:validation:MaxItems=32
+	// +listType=atomic
+	// +optional
+	Egress []networkingv1.NetworkPolicyEgressRule `json:"egress,omitempty"`
+}
+
+// ElasticWebSpecNetworkPolicyPeer 描述一类来源：namespaces和namespaceSelector只能设置一个，都不设置时为ElasticWeb所在的namespace；
+// podSelector不设置时为这些namespace中的所有pod
+// +kubebuilder:validation:XValidation:rule="!(has(self.namespaces) && has(self.namespaceSelector))",message="namespaces and namespaceSelector are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="has(self.namespaces) || has(self.namespaceSelector) || has(self.podSelector)",message="at least one of namespaces, namespaceSelector and podSelector is required"
+type ElasticWebSpecNetworkPolicyPeer struct {
+	// namespace名称
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:items:MaxLength=63
+	// +listType=set
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// 按标签选择namespace，空的selector表示所有namespace
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// 按标签选择pod
+	// +optional
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
 }
 
 type ElasticWebSpecSvcPorts struct {
-	Name       string `json:"name"`
-	Port       *int32 `json:"port"`
-	TargetPort *int32 `json:"targetport"`
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?)?$`
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port *int32 `json:"port"`
+
+	// 转发到的容器端口，可以是端口号，也可以是deploy[].ports中的端口名称，必须和某个容器端口的协议一致
+	// +kubebuilder:validation:XIntOrString
+	TargetPort *intstr.IntOrString `json:"targetport"`
+
+	// 默认TCP
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	// +optional
+	Protocol string `json:"protocol,omitempty"`
+
+	// 应用层协议，例如h2c、grpc、kubernetes.io/ws，不设置时使用targetport对应的容器端口的appProtocol
+	// +kubebuilder:validation:MaxLength=316
+	// +optional
+	AppProtocol string `json:"appProtocol,omitempty"`
 }
 
 // ElasticWebStatus defines the observed state of ElasticWeb.
 type ElasticWebStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
-	RealQPS *int32 `json:"realQPS"`
+
+	// 当前实际能承载的QPS：单个pod的QPS * available的pod数量，滚动更新和pod故障期间可能低于desiredQPS
+	// +optional
+	RealQPS *int32 `json:"realQPS,omitempty"`
+
+	// 期望的pod全部available时能承载的QPS：单个pod的QPS * desiredReplicas
+	// +optional
+	DesiredQPS int32 `json:"desiredQPS,omitempty"`
+
+	// spec.pinImageDigests为true时，每个容器镜像tag解析出的digest
+	// +optional
+	ImageDigests []ElasticWebImageDigest `json:"imageDigests,omitempty"`
+
+	// deployment当前的pod数量，scale子资源的status.replicas
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// 选择该ElasticWeb的pod的label selector，HPA按它统计pod的指标
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// 期望的pod数量，spec.replicas或按QPS计算出的值
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas,omitempty"`
+
+	// ready的pod数量
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// ready并且持续了minReadySeconds的pod数量，realQPS按它计算
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+
+	// 按spec.capacityPolicy失去sparePods个pod或者一个可用区后，期望的pod仍能承载的QPS
+	// +optional
+	SurvivableQPS int32 `json:"survivableQPS,omitempty"`
+
+	// 开启spec.idle时，最近一次观测到流量的时间，还没有观测到流量时为开启后第一次检查的时间
+	// +optional
+	LastRequestTime *metav1.Time `json:"lastRequestTime,omitempty"`
+
+	// deployMode为Variants时每个变体的副本数
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Variants []ElasticWebVariantStatus `json:"variants,omitempty"`
+
+	// 设置了spec.route时，HTTPRoute在各个Gateway上的状态，从HTTPRoute的status.parents复制
+	// +optional
+	RouteParents []ElasticWebRouteParentStatus `json:"routeParents,omitempty"`
+
+	// 开启spec.calibration时，最近一次校准的结果
+	// +optional
+	Calibration *ElasticWebCalibrationStatus `json:"calibration,omitempty"`
+
+	// 设置了spec.placement时每个成员集群中派生ElasticWeb的状态，realQPS等字段是它们的合计
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Clusters []ElasticWebClusterStatus `json:"clusters,omitempty"`
+
+	// controller最近一次处理的metadata.generation
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ElasticWebSpecCalibration 描述singlePodQPS的压测校准：controller单独启动一个不接入service的pod，
+// 再启动一个运行内置压测工具的Job，逐步提高QPS，找出延迟仍满足SLO的最大QPS
+type ElasticWebSpecCalibration struct {
+	// 压测请求的路径，默认/
+	// +kubebuilder:validation:Pattern=`^/`
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// 延迟SLO，percentile分位的延迟不能超过它，默认100ms
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="latencySLO must be greater than 0"
+	// +optional
+	LatencySLO *metav1.Duration `json:"latencySLO,omitempty"`
+
+	// 延迟的分位，默认99
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Percentile int32 `json:"percentile,omitempty"`
+
+	// 压测的最大QPS，默认1000
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000
+	// +optional
+	MaxQPS int32 `json:"maxQPS,omitempty"`
+
+	// 每一档增加的QPS，默认maxQPS的1/20
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	StepQPS int32 `json:"stepQPS,omitempty"`
+
+	// 每一档QPS持续的时间，默认30s
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="stepDuration must be greater than 0"
+	// +optional
+	StepDuration *metav1.Duration `json:"stepDuration,omitempty"`
+
+	// 为true时把推荐值写入spec.singlePodQPS，否则只记录在status.calibration中
+	// +optional
+	Apply bool `json:"apply,omitempty"`
+}
+
+// ElasticWebClusterStatus 是一个成员集群中派生ElasticWeb的状态
+type ElasticWebClusterStatus struct {
+	// spec.placement.clusters中的name
+	Name string `json:"name"`
+
+	// 按share分到的totalQPS
+	TotalQPS int32 `json:"totalQPS"`
+
+	// +optional
+	RealQPS int32 `json:"realQPS,omitempty"`
+
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas,omitempty"`
+
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+
+	// 派生的ElasticWeb已处理最新的spec，并且Ready condition为True
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// 同步失败的原因，例如kubeconfig不可用或成员集群拒绝了派生的ElasticWeb
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ElasticWebCalibrationStatus 是最近一次校准的结果，容器镜像变化后重新校准
+type ElasticWebCalibrationStatus struct {
+	// Running、Succeeded或Failed
+	Phase CalibrationPhase `json:"phase"`
+
+	// 校准时使用的容器镜像
+	Images string `json:"images"`
+
+	// 延迟满足SLO的最大QPS，即推荐的spec.singlePodQPS
+	// +optional
+	RecommendedSinglePodQPS int32 `json:"recommendedSinglePodQPS,omitempty"`
+
+	// 校准完成的时间
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// 失败原因等补充信息
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ElasticWebImageDigest 记录某个容器的镜像被解析成的digest
+type ElasticWebImageDigest struct {
+	// 容器名称，对应spec.deploy[].name
+	Name string `json:"name"`
+	// spec中的镜像，包括tag
+	Image string `json:"image"`
+	// tag当前指向的digest
+	Digest string `json:"digest"`
+	// 最近一次解析出该digest的时间
+	// +optional
+	ResolvedAt metav1.Time `json:"resolvedAt,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+// +kubebuilder:storageversion
+// +kubebuilder:resource:shortName=ew,categories={all,elasticweb}
+// +kubebuilder:printcolumn:name="SinglePodQPS",type=integer,JSONPath=`.spec.singlePodQPS`
+// +kubebuilder:printcolumn:name="TotalQPS",type=integer,JSONPath=`.spec.totalQPS`
+// +kubebuilder:printcolumn:name="DesiredQPS",type=integer,JSONPath=`.status.desiredQPS`,priority=1
+// +kubebuilder:printcolumn:name="RealQPS",type=integer,JSONPath=`.status.realQPS`
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.status.desiredReplicas`
+// +kubebuilder:printcolumn:name="ReadyReplicas",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="SurvivableQPS",type=integer,JSONPath=`.status.survivableQPS`,priority=1
+// +kubebuilder:printcolumn:name="Images",type=string,JSONPath=`.spec.deploy[*].image`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // ElasticWeb is the Schema for the elasticwebs API.
+// Service和Deployment与ElasticWeb同名（deployMode为Variants时每个变体的Deployment名为<名称>-<变体名称>），Service名称必须是DNS-1035 label
+// +kubebuilder:validation:XValidation:rule="size(self.metadata.name) <= 63 && self.metadata.name.matches('^[a-z]([-a-z0-9]*[a-z0-9])?$')",message="metadata.name must be a DNS-1035 label"
 type ElasticWeb struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -117,6 +883,304 @@ func (in *ElasticWeb) String() string {
 	return strings.Join(Str, " ")
 }
 
+// GetExpectReplicas 返回期望的副本数：设置了spec.replicas时直接使用它，
+// 否则根据单个pod的QPS和总QPS计算（向上取整），再按spec.capacityPolicy加上冗余，QPS未设置时返回0
+func (in *ElasticWeb) GetExpectReplicas() int32 {
+	if in.Spec.Replicas != nil {
+		return max(*in.Spec.Replicas, 0)
+	}
+	if in.Spec.SinglePodQPS == nil || in.Spec.TotalQPS == nil || *in.Spec.SinglePodQPS <= 0 || *in.Spec.TotalQPS <= 0 {
+		return 0
+	}
+
+	// 单POD的QPS
+	singlePodQPS := int64(*(in.Spec.SinglePodQPS))
+
+	// 期望的总QPS，加上余量，用int64避免溢出
+	totalQPS := int64(*(in.Spec.TotalQPS))
+	policy := in.Spec.CapacityPolicy
+	if policy != nil && policy.HeadroomPercent > 0 {
+		totalQPS = (totalQPS*int64(100+policy.HeadroomPercent) + 99) / 100
+	}
+
+	replicas := int32(totalQPS / singlePodQPS)
+
+	if totalQPS%singlePodQPS > 0 {
+		replicas++
+	}
+	if policy == nil {
+		return replicas
+	}
+
+	// 失去k个pod后仍有replicas个pod
+	needed := replicas + policy.SparePods
+
+	// 失去pod最多的一个可用区后仍有replicas个pod
+	if policy.Zones > 1 {
+		zoneReplicas := replicas
+		for zoneReplicas-ceilDiv(zoneReplicas, policy.Zones) < replicas {
+			zoneReplicas++
+		}
+		needed = max(needed, zoneReplicas)
+	}
+	return needed
+}
+
+// SurvivableReplicas 返回按spec.capacityPolicy失去sparePods个pod，或者失去pod最多的一个可用区后剩下的pod数量
+func (in *ElasticWeb) SurvivableReplicas(replicas int32) int32 {
+	policy := in.Spec.CapacityPolicy
+	if policy == nil {
+		return replicas
+	}
+	lost := policy.SparePods
+	if policy.Zones > 1 {
+		lost = max(lost, ceilDiv(replicas, policy.Zones))
+	}
+	return max(replicas-lost, 0)
+}
+
+// GetZoneTopologyKey 返回区分可用区的节点标签
+func (in *ElasticWeb) GetZoneTopologyKey() string {
+	if in.Spec.CapacityPolicy == nil || in.Spec.CapacityPolicy.ZoneTopologyKey == "" {
+		return corev1.LabelTopologyZone
+	}
+	return in.Spec.CapacityPolicy.ZoneTopologyKey
+}
+
+func ceilDiv(a, b int32) int32 {
+	return (a + b - 1) / b
+}
+
+// GetRouteServicePort 返回HTTPRoute转发到的service端口，spec.route.servicePort未设置时为第一个service端口
+func (in *ElasticWeb) GetRouteServicePort() int32 {
+	if in.Spec.Route != nil && in.Spec.Route.ServicePort != nil {
+		return *in.Spec.Route.ServicePort
+	}
+	if len(in.Spec.Service.Ports) > 0 && in.Spec.Service.Ports[0].Port != nil {
+		return *in.Spec.Service.Ports[0].Port
+	}
+	return 0
+}
+
+// IsVariantMode 返回spec.deploy中的每一项是否单独部署成一个变体
+func (in *ElasticWeb) IsVariantMode() bool {
+	return in.Spec.DeployMode == DeployModeVariants
+}
+
+// VariantName 返回变体的deployment名称
+func (in *ElasticWeb) VariantName(deploy *ElasticWebSpecDeploy) string {
+	return in.Name + "-" + deploy.Name
+}
+
+// GetWeight 返回变体的权重，未设置时为1
+func (in *ElasticWebSpecDeploy) GetWeight() int32 {
+	if in.Weight == nil {
+		return 1
+	}
+	return *in.Weight
+}
+
+// IsIngressControllerAllowed 返回NetworkPolicy是否放行Ingress controller
+func (in *ElasticWebSpecNetworkPolicy) IsIngressControllerAllowed() bool {
+	return in.AllowIngressController == nil || *in.AllowIngressController
+}
+
+// IsHeadless 返回service是否为headless
+func (in *ElasticWebSpecSvc) IsHeadless() bool {
+	return in.ClusterIP == corev1.ClusterIPNone
+}
+
+// GetProtocol 返回service端口的协议，默认TCP
+func (in *ElasticWebSpecSvcPorts) GetProtocol() corev1.Protocol {
+	if in.Protocol == "" {
+		return corev1.ProtocolTCP
+	}
+	return corev1.Protocol(in.Protocol)
+}
+
+// GetProtocol 返回容器端口的协议，默认TCP
+func (in *ElasticWebSpecDeployPorts) GetProtocol() corev1.Protocol {
+	if in.Protocol == "" {
+		return corev1.ProtocolTCP
+	}
+	return corev1.Protocol(in.Protocol)
+}
+
+// FindPort 返回service端口转发到的容器端口：targetport为数字时按端口号匹配，为字符串时按端口名称匹配，协议也要一致；没有时返回nil
+func (in *ElasticWebSpecDeploy) FindPort(svcPort *ElasticWebSpecSvcPorts) *ElasticWebSpecDeployPorts {
+	if svcPort.TargetPort == nil {
+		return nil
+	}
+	for i, p := range in.Ports {
+		if p.GetProtocol() != svcPort.GetProtocol() {
+			continue
+		}
+		if svcPort.TargetPort.Type == intstr.String {
+			if p.Name != "" && p.Name == svcPort.TargetPort.StrVal {
+				return &in.Ports[i]
+			}
+		} else if p.Port != nil && *p.Port == svcPort.TargetPort.IntVal {
+			return &in.Ports[i]
+		}
+	}
+	return nil
+}
+
+// VariantReplicas 把副本数按权重分配给spec.deploy中的各个变体，顺序和spec.deploy一致：
+// 先按比例向下取整，剩下的副本按余数从大到小分配（最大余数法），总数始终等于replicas；
+// 副本数不少于权重大于0的变体数量时，每个这样的变体至少分到一个pod，从分到最多的变体中挪出
+func (in *ElasticWeb) VariantReplicas(replicas int32) []int32 {
+	weights := make([]int32, len(in.Spec.Deploy))
+	var positive int32
+	for i := range in.Spec.Deploy {
+		if weights[i] = in.Spec.Deploy[i].GetWeight(); weights[i] > 0 {
+			positive++
+		}
+	}
+	result := splitByWeight(replicas, weights)
+
+	if replicas < positive {
+		return result
+	}
+	for i := range in.Spec.Deploy {
+		if in.Spec.Deploy[i].GetWeight() <= 0 || result[i] > 0 {
+			continue
+		}
+		largest := 0
+		for j := range result {
+			if result[j] > result[largest] {
+				largest = j
+			}
+		}
+		result[largest]--
+		result[i]++
+	}
+	return result
+}
+
+// splitByWeight 按权重分配n：先按比例向下取整，剩下的按余数从大到小分配（最大余数法），
+// 总数始终等于n；所有权重都不大于0时全部分到0
+func splitByWeight(n int32, weights []int32) []int32 {
+	result := make([]int32, len(weights))
+	var total int64
+	for _, w := range weights {
+		total += int64(max(w, 0))
+	}
+	if total == 0 || n <= 0 {
+		return result
+	}
+
+	remainders := make([]int64, len(result))
+	left := n
+	for i, w := range weights {
+		share := int64(n) * int64(max(w, 0))
+		result[i] = int32(share / total)
+		remainders[i] = share % total
+		left -= result[i]
+	}
+	order := make([]int, len(result))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for _, i := range order[:left] {
+		result[i]++
+	}
+	return result
+}
+
+// GetShare 返回集群的QPS份额，默认1
+func (in *ElasticWebSpecPlacementCluster) GetShare() int32 {
+	if in.Share == nil {
+		return 1
+	}
+	return *in.Share
+}
+
+func (in *ElasticWeb) placementShares() []int32 {
+	shares := make([]int32, len(in.Spec.Placement.Clusters))
+	for i := range in.Spec.Placement.Clusters {
+		shares[i] = in.Spec.Placement.Clusters[i].GetShare()
+	}
+	return shares
+}
+
+// PlacementQPS 把totalQPS按share分配给spec.placement中的各个集群，顺序和clusters一致；
+// 分到的QPS不为0但低于singlePodQPS时提高到singlePodQPS，否则成员集群的schema校验会拒绝派生的ElasticWeb
+func (in *ElasticWeb) PlacementQPS() []int32 {
+	var totalQPS int32
+	if in.Spec.TotalQPS != nil {
+		totalQPS = *in.Spec.TotalQPS
+	}
+	result := splitByWeight(totalQPS, in.placementShares())
+	if in.Spec.SinglePodQPS != nil {
+		for i := range result {
+			if result[i] > 0 && result[i] < *in.Spec.SinglePodQPS {
+				result[i] = *in.Spec.SinglePodQPS
+			}
+		}
+	}
+	return result
+}
+
+// PlacementReplicas 设置了spec.replicas时，把副本数按share分配给各个集群，没有设置时返回nil
+func (in *ElasticWeb) PlacementReplicas() []int32 {
+	if in.Spec.Replicas == nil {
+		return nil
+	}
+	return splitByWeight(*in.Spec.Replicas, in.placementShares())
+}
+
+// IsIdle 返回ElasticWeb是否因为没有流量被缩容到0
+func (in *ElasticWeb) IsIdle() bool {
+	return in.Spec.Idle != nil && meta.IsStatusConditionTrue(in.Status.Conditions, ConditionIdle)
+}
+
+// QuotaUsage 返回一个ElasticWeb计入ElasticWebQuota的totalQPS和副本数，webhook和controller用同一套算法
+// 设置了spec.replicas时，totalQPS按副本数 * singlePodQPS计算
+func (in *ElasticWeb) QuotaUsage() (totalQPS int32, replicas int32) {
+	replicas = in.GetExpectReplicas()
+	switch {
+	case in.Spec.Replicas != nil && in.Spec.SinglePodQPS != nil:
+		totalQPS = replicas * *in.Spec.SinglePodQPS
+	case in.Spec.TotalQPS != nil && *in.Spec.TotalQPS > 0:
+		totalQPS = *in.Spec.TotalQPS
+	}
+	return totalQPS, replicas
+}
+
+// ClaimName 返回volume实际引用的PVC名称，非PVC类型的volume返回空字符串
+func (in *ElasticWeb) ClaimName(volume *ElasticWebSpecVolume) string {
+	switch {
+	case volume.ClaimTemplate != nil:
+		return in.Name + "-" + volume.Name
+	case volume.PersistentVolumeClaim != nil:
+		return volume.PersistentVolumeClaim.ClaimName
+	}
+	return ""
+}
+
+// GetServiceAccountName 返回pod实际使用的ServiceAccount名称，空字符串表示namespace的default
+func (in *ElasticWeb) GetServiceAccountName() string {
+	if in.Spec.ServiceAccountName != "" {
+		return in.Spec.ServiceAccountName
+	}
+	if in.Spec.ServiceAccount != nil && in.Spec.ServiceAccount.Create {
+		return in.Name
+	}
+	return ""
+}
+
+// GetImageDigest 返回容器镜像已解析出的digest，镜像变化后旧的记录不再有效
+func (in *ElasticWeb) GetImageDigest(deploy *ElasticWebSpecDeploy) string {
+	for _, d := range in.Status.ImageDigests {
+		if d.Name == deploy.Name && d.Image == deploy.Image {
+			return d.Digest
+		}
+	}
+	return ""
+}
+
 func init() {
 	SchemeBuilder.Register(&ElasticWeb{}, &ElasticWebList{})
 }
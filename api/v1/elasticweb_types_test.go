@@ -0,0 +1,122 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+)
+
+var _ = Describe("ElasticWeb replicas", func() {
+	newElasticWeb := func(totalQPS int32, policy *ElasticWebSpecCapacityPolicy) *ElasticWeb {
+		return &ElasticWeb{Spec: ElasticWebSpec{
+			SinglePodQPS:   ptr.To[int32](500),
+			TotalQPS:       ptr.To(totalQPS),
+			CapacityPolicy: policy,
+		}}
+	}
+
+	DescribeTable("should plan for the capacity policy",
+		func(totalQPS int32, policy *ElasticWebSpecCapacityPolicy, expectReplicas, survivableReplicas int32) {
+			ew := newElasticWeb(totalQPS, policy)
+			replicas := ew.GetExpectReplicas()
+			Expect(replicas).To(Equal(expectReplicas))
+			Expect(ew.SurvivableReplicas(replicas)).To(Equal(survivableReplicas))
+			// whatever the policy plans to lose, what is left still serves totalQPS
+			Expect(ew.SurvivableReplicas(replicas) * 500).To(BeNumerically(">=", totalQPS))
+		},
+		Entry("no policy", int32(1200), nil, int32(3), int32(3)),
+		Entry("headroom within the last pod", int32(1200), &ElasticWebSpecCapacityPolicy{HeadroomPercent: 20}, int32(3), int32(3)),
+		Entry("headroom needing another pod", int32(1200), &ElasticWebSpecCapacityPolicy{HeadroomPercent: 30}, int32(4), int32(4)),
+		Entry("N+1", int32(1200), &ElasticWebSpecCapacityPolicy{SparePods: 1}, int32(4), int32(3)),
+		Entry("three zones", int32(1500), &ElasticWebSpecCapacityPolicy{Zones: 3}, int32(5), int32(3)),
+		Entry("two zones", int32(1500), &ElasticWebSpecCapacityPolicy{Zones: 2}, int32(6), int32(3)),
+		Entry("spare pods beyond zone loss", int32(1500), &ElasticWebSpecCapacityPolicy{Zones: 3, SparePods: 3}, int32(6), int32(3)),
+		Entry("one zone is no zone redundancy", int32(1500), &ElasticWebSpecCapacityPolicy{Zones: 1}, int32(3), int32(3)),
+		Entry("no traffic", int32(0), &ElasticWebSpecCapacityPolicy{SparePods: 2, Zones: 3}, int32(0), int32(0)),
+	)
+
+	It("should use spec.replicas as is", func() {
+		ew := newElasticWeb(1200, &ElasticWebSpecCapacityPolicy{SparePods: 2})
+		ew.Spec.Replicas = ptr.To[int32](7)
+		Expect(ew.GetExpectReplicas()).To(Equal(int32(7)))
+		Expect(ew.SurvivableReplicas(7)).To(Equal(int32(5)))
+	})
+})
+
+var _ = Describe("ElasticWeb variants", func() {
+	newElasticWeb := func(weights ...*int32) *ElasticWeb {
+		ew := &ElasticWeb{Spec: ElasticWebSpec{DeployMode: DeployModeVariants}}
+		for _, w := range weights {
+			ew.Spec.Deploy = append(ew.Spec.Deploy, ElasticWebSpecDeploy{Weight: w})
+		}
+		return ew
+	}
+
+	DescribeTable("should split replicas by weight",
+		func(replicas int32, weights []*int32, expected []int32) {
+			split := newElasticWeb(weights...).VariantReplicas(replicas)
+			Expect(split).To(Equal(expected))
+			var sum int32
+			for _, r := range split {
+				sum += r
+			}
+			Expect(sum).To(Equal(replicas))
+		},
+		Entry("equal by default", int32(4), []*int32{nil, nil}, []int32{2, 2}),
+		Entry("odd replicas go to the first largest remainder", int32(5), []*int32{nil, nil}, []int32{3, 2}),
+		Entry("90/10", int32(10), []*int32{ptr.To[int32](90), ptr.To[int32](10)}, []int32{9, 1}),
+		Entry("largest remainder", int32(7), []*int32{ptr.To[int32](50), ptr.To[int32](30), ptr.To[int32](20)}, []int32{4, 2, 1}),
+		Entry("a small weight still gets a pod", int32(3), []*int32{ptr.To[int32](99), ptr.To[int32](1)}, []int32{2, 1}),
+		Entry("not enough pods for every variant", int32(1), []*int32{ptr.To[int32](99), ptr.To[int32](1)}, []int32{1, 0}),
+		Entry("weight 0 drains a variant", int32(4), []*int32{ptr.To[int32](0), nil}, []int32{0, 4}),
+		Entry("no pods", int32(0), []*int32{nil, nil}, []int32{0, 0}),
+	)
+})
+
+var _ = Describe("ElasticWeb placement", func() {
+	newElasticWeb := func(totalQPS int32, shares ...*int32) *ElasticWeb {
+		ew := &ElasticWeb{Spec: ElasticWebSpec{
+			SinglePodQPS: ptr.To[int32](500),
+			TotalQPS:     ptr.To(totalQPS),
+			Placement:    &ElasticWebSpecPlacement{},
+		}}
+		for _, s := range shares {
+			ew.Spec.Placement.Clusters = append(ew.Spec.Placement.Clusters, ElasticWebSpecPlacementCluster{Share: s})
+		}
+		return ew
+	}
+
+	DescribeTable("should split totalQPS by share",
+		func(totalQPS int32, shares []*int32, expected []int32) {
+			Expect(newElasticWeb(totalQPS, shares...).PlacementQPS()).To(Equal(expected))
+		},
+		Entry("equal by default", int32(3000), []*int32{nil, nil}, []int32{1500, 1500}),
+		Entry("largest remainder", int32(3001), []*int32{ptr.To[int32](2), ptr.To[int32](1)}, []int32{2001, 1000}),
+		Entry("share 0 drains a cluster", int32(3000), []*int32{ptr.To[int32](0), nil}, []int32{0, 3000}),
+		Entry("a small share still serves a whole pod", int32(3000), []*int32{ptr.To[int32](99), ptr.To[int32](1)}, []int32{2970, 500}),
+		Entry("no traffic", int32(0), []*int32{nil, nil}, []int32{0, 0}),
+	)
+
+	It("should split spec.replicas by share", func() {
+		ew := newElasticWeb(3000, ptr.To[int32](3), nil)
+		Expect(ew.PlacementReplicas()).To(BeNil())
+		ew.Spec.Replicas = ptr.To[int32](5)
+		Expect(ew.PlacementReplicas()).To(Equal([]int32{4, 1}))
+	})
+})
@@ -0,0 +1,76 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ElasticWebQuotaSpec 限制一个namespace中所有ElasticWeb加起来能申请的容量，不设置的字段不限制
+type ElasticWebQuotaSpec struct {
+	// namespace内所有ElasticWeb的totalQPS之和的上限
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxTotalQPS *int32 `json:"maxTotalQPS,omitempty"`
+
+	// namespace内所有ElasticWeb期望副本数之和的上限
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+}
+
+// ElasticWebQuotaStatus 是controller统计出的当前用量
+type ElasticWebQuotaStatus struct {
+	// +optional
+	UsedTotalQPS int32 `json:"usedTotalQPS"`
+	// +optional
+	UsedReplicas int32 `json:"usedReplicas"`
+	// 参与统计的ElasticWeb数量
+	// +optional
+	ElasticWebs int32 `json:"elasticWebs"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="MaxTotalQPS",type=integer,JSONPath=`.spec.maxTotalQPS`
+// +kubebuilder:printcolumn:name="UsedTotalQPS",type=integer,JSONPath=`.status.usedTotalQPS`
+// +kubebuilder:printcolumn:name="MaxReplicas",type=integer,JSONPath=`.spec.maxReplicas`
+// +kubebuilder:printcolumn:name="UsedReplicas",type=integer,JSONPath=`.status.usedReplicas`
+
+// ElasticWebQuota is the Schema for the elasticwebquotas API.
+// The validating webhook rejects ElasticWeb creates and updates that would push the
+// namespace over any ElasticWebQuota in it.
+type ElasticWebQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ElasticWebQuotaSpec   `json:"spec,omitempty"`
+	Status ElasticWebQuotaStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ElasticWebQuotaList contains a list of ElasticWebQuota.
+type ElasticWebQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ElasticWebQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ElasticWebQuota{}, &ElasticWebQuotaList{})
+}
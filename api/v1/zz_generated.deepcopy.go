@@ -21,7 +21,11 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -51,6 +55,56 @@ func (in *ElasticWeb) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebCalibrationStatus) DeepCopyInto(out *ElasticWebCalibrationStatus) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebCalibrationStatus.
+func (in *ElasticWebCalibrationStatus) DeepCopy() *ElasticWebCalibrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebCalibrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebClusterStatus) DeepCopyInto(out *ElasticWebClusterStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebClusterStatus.
+func (in *ElasticWebClusterStatus) DeepCopy() *ElasticWebClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebImageDigest) DeepCopyInto(out *ElasticWebImageDigest) {
+	*out = *in
+	in.ResolvedAt.DeepCopyInto(&out.ResolvedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebImageDigest.
+func (in *ElasticWebImageDigest) DeepCopy() *ElasticWebImageDigest {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebImageDigest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ElasticWebList) DeepCopyInto(out *ElasticWebList) {
 	*out = *in
@@ -83,6 +137,128 @@ func (in *ElasticWebList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebQuota) DeepCopyInto(out *ElasticWebQuota) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebQuota.
+func (in *ElasticWebQuota) DeepCopy() *ElasticWebQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ElasticWebQuota) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebQuotaList) DeepCopyInto(out *ElasticWebQuotaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ElasticWebQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebQuotaList.
+func (in *ElasticWebQuotaList) DeepCopy() *ElasticWebQuotaList {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebQuotaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ElasticWebQuotaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebQuotaSpec) DeepCopyInto(out *ElasticWebQuotaSpec) {
+	*out = *in
+	if in.MaxTotalQPS != nil {
+		in, out := &in.MaxTotalQPS, &out.MaxTotalQPS
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebQuotaSpec.
+func (in *ElasticWebQuotaSpec) DeepCopy() *ElasticWebQuotaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebQuotaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebQuotaStatus) DeepCopyInto(out *ElasticWebQuotaStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebQuotaStatus.
+func (in *ElasticWebQuotaStatus) DeepCopy() *ElasticWebQuotaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebQuotaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebRouteParentStatus) DeepCopyInto(out *ElasticWebRouteParentStatus) {
+	*out = *in
+	in.ParentRef.DeepCopyInto(&out.ParentRef)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebRouteParentStatus.
+func (in *ElasticWebRouteParentStatus) DeepCopy() *ElasticWebRouteParentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebRouteParentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ElasticWebSpec) DeepCopyInto(out *ElasticWebSpec) {
 	*out = *in
@@ -104,6 +280,63 @@ func (in *ElasticWebSpec) DeepCopyInto(out *ElasticWebSpec) {
 		}
 	}
 	in.Service.DeepCopyInto(&out.Service)
+	if in.Route != nil {
+		in, out := &in.Route, &out.Route
+		*out = new(ElasticWebSpecRoute)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(ElasticWebSpecNetworkPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.CapacityPolicy != nil {
+		in, out := &in.CapacityPolicy, &out.CapacityPolicy
+		*out = new(ElasticWebSpecCapacityPolicy)
+		**out = **in
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]ElasticWebSpecVolume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServiceAccount != nil {
+		in, out := &in.ServiceAccount, &out.ServiceAccount
+		*out = new(ElasticWebSpecServiceAccount)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Idle != nil {
+		in, out := &in.Idle, &out.Idle
+		*out = new(ElasticWebSpecIdle)
+		**out = **in
+	}
+	if in.Calibration != nil {
+		in, out := &in.Calibration, &out.Calibration
+		*out = new(ElasticWebSpecCalibration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = new(ElasticWebSpecPlacement)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpec.
@@ -116,6 +349,46 @@ func (in *ElasticWebSpec) DeepCopy() *ElasticWebSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecCalibration) DeepCopyInto(out *ElasticWebSpecCalibration) {
+	*out = *in
+	if in.LatencySLO != nil {
+		in, out := &in.LatencySLO, &out.LatencySLO
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.StepDuration != nil {
+		in, out := &in.StepDuration, &out.StepDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecCalibration.
+func (in *ElasticWebSpecCalibration) DeepCopy() *ElasticWebSpecCalibration {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecCalibration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecCapacityPolicy) DeepCopyInto(out *ElasticWebSpecCapacityPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecCapacityPolicy.
+func (in *ElasticWebSpecCapacityPolicy) DeepCopy() *ElasticWebSpecCapacityPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecCapacityPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ElasticWebSpecDeploy) DeepCopyInto(out *ElasticWebSpecDeploy) {
 	*out = *in
@@ -126,6 +399,38 @@ func (in *ElasticWebSpecDeploy) DeepCopyInto(out *ElasticWebSpecDeploy) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessProbe != nil {
+		in, out := &in.ReadinessProbe, &out.ReadinessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]corev1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecDeploy.
@@ -158,6 +463,254 @@ func (in *ElasticWebSpecDeployPorts) DeepCopy() *ElasticWebSpecDeployPorts {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecIdle) DeepCopyInto(out *ElasticWebSpecIdle) {
+	*out = *in
+	out.After = in.After
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecIdle.
+func (in *ElasticWebSpecIdle) DeepCopy() *ElasticWebSpecIdle {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecIdle)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecNetworkPolicy) DeepCopyInto(out *ElasticWebSpecNetworkPolicy) {
+	*out = *in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]ElasticWebSpecNetworkPolicyPeer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AllowIngressController != nil {
+		in, out := &in.AllowIngressController, &out.AllowIngressController
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Egress != nil {
+		in, out := &in.Egress, &out.Egress
+		*out = make([]networkingv1.NetworkPolicyEgressRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecNetworkPolicy.
+func (in *ElasticWebSpecNetworkPolicy) DeepCopy() *ElasticWebSpecNetworkPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecNetworkPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecNetworkPolicyPeer) DeepCopyInto(out *ElasticWebSpecNetworkPolicyPeer) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodSelector != nil {
+		in, out := &in.PodSelector, &out.PodSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecNetworkPolicyPeer.
+func (in *ElasticWebSpecNetworkPolicyPeer) DeepCopy() *ElasticWebSpecNetworkPolicyPeer {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecNetworkPolicyPeer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecPlacement) DeepCopyInto(out *ElasticWebSpecPlacement) {
+	*out = *in
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]ElasticWebSpecPlacementCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecPlacement.
+func (in *ElasticWebSpecPlacement) DeepCopy() *ElasticWebSpecPlacement {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecPlacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecPlacementCluster) DeepCopyInto(out *ElasticWebSpecPlacementCluster) {
+	*out = *in
+	if in.Share != nil {
+		in, out := &in.Share, &out.Share
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecPlacementCluster.
+func (in *ElasticWebSpecPlacementCluster) DeepCopy() *ElasticWebSpecPlacementCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecPlacementCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecRoute) DeepCopyInto(out *ElasticWebSpecRoute) {
+	*out = *in
+	if in.ParentRefs != nil {
+		in, out := &in.ParentRefs, &out.ParentRefs
+		*out = make([]ElasticWebSpecRouteParentRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Hostnames != nil {
+		in, out := &in.Hostnames, &out.Hostnames
+		*out = make([]ElasticWebSpecRouteHostname, len(*in))
+		copy(*out, *in)
+	}
+	if in.Matches != nil {
+		in, out := &in.Matches, &out.Matches
+		*out = make([]ElasticWebSpecRoutePathMatch, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServicePort != nil {
+		in, out := &in.ServicePort, &out.ServicePort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Backends != nil {
+		in, out := &in.Backends, &out.Backends
+		*out = make([]ElasticWebSpecRouteBackend, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecRoute.
+func (in *ElasticWebSpecRoute) DeepCopy() *ElasticWebSpecRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecRouteBackend) DeepCopyInto(out *ElasticWebSpecRouteBackend) {
+	*out = *in
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecRouteBackend.
+func (in *ElasticWebSpecRouteBackend) DeepCopy() *ElasticWebSpecRouteBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecRouteBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecRouteParentRef) DeepCopyInto(out *ElasticWebSpecRouteParentRef) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecRouteParentRef.
+func (in *ElasticWebSpecRouteParentRef) DeepCopy() *ElasticWebSpecRouteParentRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecRouteParentRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecRoutePathMatch) DeepCopyInto(out *ElasticWebSpecRoutePathMatch) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecRoutePathMatch.
+func (in *ElasticWebSpecRoutePathMatch) DeepCopy() *ElasticWebSpecRoutePathMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecRoutePathMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecServiceAccount) DeepCopyInto(out *ElasticWebSpecServiceAccount) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecServiceAccount.
+func (in *ElasticWebSpecServiceAccount) DeepCopy() *ElasticWebSpecServiceAccount {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecServiceAccount)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ElasticWebSpecSvc) DeepCopyInto(out *ElasticWebSpecSvc) {
 	*out = *in
@@ -168,6 +721,16 @@ func (in *ElasticWebSpecSvc) DeepCopyInto(out *ElasticWebSpecSvc) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.SessionAffinityTimeoutSeconds != nil {
+		in, out := &in.SessionAffinityTimeoutSeconds, &out.SessionAffinityTimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecSvc.
@@ -190,7 +753,7 @@ func (in *ElasticWebSpecSvcPorts) DeepCopyInto(out *ElasticWebSpecSvcPorts) {
 	}
 	if in.TargetPort != nil {
 		in, out := &in.TargetPort, &out.TargetPort
-		*out = new(int32)
+		*out = new(intstr.IntOrString)
 		**out = **in
 	}
 }
@@ -205,6 +768,81 @@ func (in *ElasticWebSpecSvcPorts) DeepCopy() *ElasticWebSpecSvcPorts {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecVolume) DeepCopyInto(out *ElasticWebSpecVolume) {
+	*out = *in
+	if in.EmptyDir != nil {
+		in, out := &in.EmptyDir, &out.EmptyDir
+		*out = new(corev1.EmptyDirVolumeSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(corev1.ConfigMapVolumeSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(corev1.SecretVolumeSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Projected != nil {
+		in, out := &in.Projected, &out.Projected
+		*out = new(corev1.ProjectedVolumeSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PersistentVolumeClaim != nil {
+		in, out := &in.PersistentVolumeClaim, &out.PersistentVolumeClaim
+		*out = new(corev1.PersistentVolumeClaimVolumeSource)
+		**out = **in
+	}
+	if in.ClaimTemplate != nil {
+		in, out := &in.ClaimTemplate, &out.ClaimTemplate
+		*out = new(ElasticWebSpecVolumeClaimTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecVolume.
+func (in *ElasticWebSpecVolume) DeepCopy() *ElasticWebSpecVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebSpecVolumeClaimTemplate) DeepCopyInto(out *ElasticWebSpecVolumeClaimTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebSpecVolumeClaimTemplate.
+func (in *ElasticWebSpecVolumeClaimTemplate) DeepCopy() *ElasticWebSpecVolumeClaimTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebSpecVolumeClaimTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ElasticWebStatus) DeepCopyInto(out *ElasticWebStatus) {
 	*out = *in
@@ -213,6 +851,46 @@ func (in *ElasticWebStatus) DeepCopyInto(out *ElasticWebStatus) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.ImageDigests != nil {
+		in, out := &in.ImageDigests, &out.ImageDigests
+		*out = make([]ElasticWebImageDigest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastRequestTime != nil {
+		in, out := &in.LastRequestTime, &out.LastRequestTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Variants != nil {
+		in, out := &in.Variants, &out.Variants
+		*out = make([]ElasticWebVariantStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.RouteParents != nil {
+		in, out := &in.RouteParents, &out.RouteParents
+		*out = make([]ElasticWebRouteParentStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Calibration != nil {
+		in, out := &in.Calibration, &out.Calibration
+		*out = new(ElasticWebCalibrationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]ElasticWebClusterStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebStatus.
@@ -224,3 +902,18 @@ func (in *ElasticWebStatus) DeepCopy() *ElasticWebStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticWebVariantStatus) DeepCopyInto(out *ElasticWebVariantStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticWebVariantStatus.
+func (in *ElasticWebVariantStatus) DeepCopy() *ElasticWebVariantStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticWebVariantStatus)
+	in.DeepCopyInto(out)
+	return out
+}
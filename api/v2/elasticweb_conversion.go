@@ -0,0 +1,381 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	v1 "elasticweb/api/v1"
+)
+
+// v1里必填的QPS和端口是*int32，转换成v2的int32时nil按0处理；
+// 存储的对象都经过了webhook的默认值和校验，这些字段不会是nil。
+
+// ConvertTo converts this ElasticWeb (v2) to the Hub version (v1).
+func (src *ElasticWeb) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1.ElasticWeb)
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Spec = v1.ElasticWebSpec{
+		SinglePodQPS:       ptr.To(src.Spec.Capacity.PerPodQPS),
+		TotalQPS:           ptr.To(src.Spec.Capacity.TotalQPS),
+		Replicas:           src.Spec.Capacity.Replicas,
+		CapacityPolicy:     (*v1.ElasticWebSpecCapacityPolicy)(src.Spec.Capacity.Policy),
+		Idle:               (*v1.ElasticWebSpecIdle)(src.Spec.Capacity.Idle),
+		Calibration:        (*v1.ElasticWebSpecCalibration)(src.Spec.Capacity.Calibration),
+		Service:            convertServiceTo(&src.Spec.Networking.Service),
+		Route:              convertRouteTo(src.Spec.Networking.Route),
+		NetworkPolicy:      convertNetworkPolicyTo(src.Spec.Networking.NetworkPolicy),
+		SecurityContext:    src.Spec.Workload.SecurityContext,
+		ServiceAccountName: src.Spec.Workload.ServiceAccountName,
+		ImagePullSecrets:   src.Spec.Workload.ImagePullSecrets,
+		PinImageDigests:    src.Spec.Rollout.PinImageDigests,
+		DeployMode:         v1.DeployMode(src.Spec.Workload.Mode),
+		Paused:             src.Spec.Paused,
+		DeletionProtection: src.Spec.DeletionProtection,
+		Placement:          convertPlacementTo(src.Spec.Placement),
+	}
+	for _, c := range src.Spec.Workload.Containers {
+		deploy := v1.ElasticWebSpecDeploy{
+			Name:            c.Name,
+			Image:           c.Image,
+			Resources:       c.Resources,
+			ReadinessProbe:  c.ReadinessProbe,
+			LivenessProbe:   c.LivenessProbe,
+			PullPolicy:      c.ImagePullPolicy,
+			VolumeMounts:    c.VolumeMounts,
+			SecurityContext: c.SecurityContext,
+			Weight:          c.Weight,
+		}
+		for _, p := range c.Ports {
+			deploy.Ports = append(deploy.Ports, v1.ElasticWebSpecDeployPorts{
+				Name:        p.Name,
+				Port:        ptr.To(p.ContainerPort),
+				Protocol:    string(p.Protocol),
+				AppProtocol: p.AppProtocol,
+			})
+		}
+		dst.Spec.Deploy = append(dst.Spec.Deploy, deploy)
+	}
+	for _, v := range src.Spec.Workload.Volumes {
+		volume := v1.ElasticWebSpecVolume{
+			Name:                  v.Name,
+			EmptyDir:              v.EmptyDir,
+			ConfigMap:             v.ConfigMap,
+			Secret:                v.Secret,
+			Projected:             v.Projected,
+			PersistentVolumeClaim: v.PersistentVolumeClaim,
+		}
+		if v.ClaimTemplate != nil {
+			volume.ClaimTemplate = &v1.ElasticWebSpecVolumeClaimTemplate{
+				Labels:      v.ClaimTemplate.Labels,
+				Annotations: v.ClaimTemplate.Annotations,
+				Spec:        v.ClaimTemplate.Spec,
+			}
+		}
+		dst.Spec.Volumes = append(dst.Spec.Volumes, volume)
+	}
+	if sa := src.Spec.Workload.ServiceAccount; sa != nil {
+		dst.Spec.ServiceAccount = &v1.ElasticWebSpecServiceAccount{Create: sa.Create, Annotations: sa.Annotations}
+	}
+	for _, p := range src.Spec.Networking.Service.Ports {
+		dst.Spec.Service.Ports = append(dst.Spec.Service.Ports, v1.ElasticWebSpecSvcPorts{
+			Name:        p.Name,
+			Port:        ptr.To(p.Port),
+			TargetPort:  ptr.To(p.TargetPort),
+			Protocol:    string(p.Protocol),
+			AppProtocol: p.AppProtocol,
+		})
+	}
+
+	dst.Status = v1.ElasticWebStatus{
+		RealQPS:            src.Status.RealQPS,
+		DesiredQPS:         src.Status.DesiredQPS,
+		Replicas:           src.Status.Replicas,
+		Selector:           src.Status.Selector,
+		DesiredReplicas:    src.Status.DesiredReplicas,
+		ReadyReplicas:      src.Status.ReadyReplicas,
+		AvailableReplicas:  src.Status.AvailableReplicas,
+		SurvivableQPS:      src.Status.SurvivableQPS,
+		LastRequestTime:    src.Status.LastRequestTime,
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Conditions:         src.Status.Conditions,
+	}
+	for _, d := range src.Status.ImageDigests {
+		dst.Status.ImageDigests = append(dst.Status.ImageDigests, v1.ElasticWebImageDigest(d))
+	}
+	for _, v := range src.Status.Variants {
+		dst.Status.Variants = append(dst.Status.Variants, v1.ElasticWebVariantStatus(v))
+	}
+	for _, c := range src.Status.Clusters {
+		dst.Status.Clusters = append(dst.Status.Clusters, v1.ElasticWebClusterStatus(c))
+	}
+	for _, p := range src.Status.RouteParents {
+		dst.Status.RouteParents = append(dst.Status.RouteParents, v1.ElasticWebRouteParentStatus{
+			ParentRef:      v1.ElasticWebSpecRouteParentRef(p.ParentRef),
+			ControllerName: p.ControllerName,
+			Conditions:     p.Conditions,
+		})
+	}
+	if c := src.Status.Calibration; c != nil {
+		dst.Status.Calibration = &v1.ElasticWebCalibrationStatus{
+			Phase:                   v1.CalibrationPhase(c.Phase),
+			Images:                  c.Images,
+			RecommendedSinglePodQPS: c.RecommendedSinglePodQPS,
+			CompletionTime:          c.CompletionTime,
+			Message:                 c.Message,
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts the Hub version (v1) to this version (v2).
+func (dst *ElasticWeb) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1.ElasticWeb)
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Spec = ElasticWebSpec{
+		Capacity: Capacity{
+			TotalQPS:    ptr.Deref(src.Spec.TotalQPS, 0),
+			PerPodQPS:   ptr.Deref(src.Spec.SinglePodQPS, 0),
+			Replicas:    src.Spec.Replicas,
+			Policy:      (*CapacityPolicy)(src.Spec.CapacityPolicy),
+			Idle:        (*Idle)(src.Spec.Idle),
+			Calibration: (*Calibration)(src.Spec.Calibration),
+		},
+		Workload: Workload{
+			Mode:               DeployMode(src.Spec.DeployMode),
+			SecurityContext:    src.Spec.SecurityContext,
+			ServiceAccountName: src.Spec.ServiceAccountName,
+			ImagePullSecrets:   src.Spec.ImagePullSecrets,
+		},
+		Networking: Networking{
+			Service:       convertServiceFrom(&src.Spec.Service),
+			Route:         convertRouteFrom(src.Spec.Route),
+			NetworkPolicy: convertNetworkPolicyFrom(src.Spec.NetworkPolicy),
+		},
+		Rollout:            Rollout{PinImageDigests: src.Spec.PinImageDigests},
+		Paused:             src.Spec.Paused,
+		DeletionProtection: src.Spec.DeletionProtection,
+		Placement:          convertPlacementFrom(src.Spec.Placement),
+	}
+	for _, d := range src.Spec.Deploy {
+		container := Container{
+			Name:            d.Name,
+			Image:           d.Image,
+			ImagePullPolicy: d.PullPolicy,
+			Resources:       d.Resources,
+			ReadinessProbe:  d.ReadinessProbe,
+			LivenessProbe:   d.LivenessProbe,
+			VolumeMounts:    d.VolumeMounts,
+			SecurityContext: d.SecurityContext,
+			Weight:          d.Weight,
+		}
+		for _, p := range d.Ports {
+			container.Ports = append(container.Ports, ContainerPort{
+				Name:          p.Name,
+				ContainerPort: ptr.Deref(p.Port, 0),
+				Protocol:      corev1.Protocol(p.Protocol),
+				AppProtocol:   p.AppProtocol,
+			})
+		}
+		dst.Spec.Workload.Containers = append(dst.Spec.Workload.Containers, container)
+	}
+	for _, v := range src.Spec.Volumes {
+		volume := Volume{
+			Name:                  v.Name,
+			EmptyDir:              v.EmptyDir,
+			ConfigMap:             v.ConfigMap,
+			Secret:                v.Secret,
+			Projected:             v.Projected,
+			PersistentVolumeClaim: v.PersistentVolumeClaim,
+		}
+		if v.ClaimTemplate != nil {
+			volume.ClaimTemplate = &VolumeClaimTemplate{
+				Labels:      v.ClaimTemplate.Labels,
+				Annotations: v.ClaimTemplate.Annotations,
+				Spec:        v.ClaimTemplate.Spec,
+			}
+		}
+		dst.Spec.Workload.Volumes = append(dst.Spec.Workload.Volumes, volume)
+	}
+	if sa := src.Spec.ServiceAccount; sa != nil {
+		dst.Spec.Workload.ServiceAccount = &ServiceAccount{Create: sa.Create, Annotations: sa.Annotations}
+	}
+	for _, p := range src.Spec.Service.Ports {
+		dst.Spec.Networking.Service.Ports = append(dst.Spec.Networking.Service.Ports, ServicePort{
+			Name:        p.Name,
+			Port:        ptr.Deref(p.Port, 0),
+			TargetPort:  ptr.Deref(p.TargetPort, intstr.IntOrString{}),
+			Protocol:    corev1.Protocol(p.Protocol),
+			AppProtocol: p.AppProtocol,
+		})
+	}
+
+	dst.Status = ElasticWebStatus{
+		RealQPS:            src.Status.RealQPS,
+		DesiredQPS:         src.Status.DesiredQPS,
+		Replicas:           src.Status.Replicas,
+		Selector:           src.Status.Selector,
+		DesiredReplicas:    src.Status.DesiredReplicas,
+		ReadyReplicas:      src.Status.ReadyReplicas,
+		AvailableReplicas:  src.Status.AvailableReplicas,
+		SurvivableQPS:      src.Status.SurvivableQPS,
+		LastRequestTime:    src.Status.LastRequestTime,
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Conditions:         src.Status.Conditions,
+	}
+	for _, d := range src.Status.ImageDigests {
+		dst.Status.ImageDigests = append(dst.Status.ImageDigests, ImageDigest(d))
+	}
+	for _, v := range src.Status.Variants {
+		dst.Status.Variants = append(dst.Status.Variants, VariantStatus(v))
+	}
+	for _, c := range src.Status.Clusters {
+		dst.Status.Clusters = append(dst.Status.Clusters, ClusterStatus(c))
+	}
+	for _, p := range src.Status.RouteParents {
+		dst.Status.RouteParents = append(dst.Status.RouteParents, RouteParentStatus{
+			ParentRef:      RouteParentRef(p.ParentRef),
+			ControllerName: p.ControllerName,
+			Conditions:     p.Conditions,
+		})
+	}
+	if c := src.Status.Calibration; c != nil {
+		dst.Status.Calibration = &CalibrationStatus{
+			Phase:                   CalibrationPhase(c.Phase),
+			Images:                  c.Images,
+			RecommendedSinglePodQPS: c.RecommendedSinglePodQPS,
+			CompletionTime:          c.CompletionTime,
+			Message:                 c.Message,
+		}
+	}
+	return nil
+}
+
+func convertRouteTo(src *Route) *v1.ElasticWebSpecRoute {
+	if src == nil {
+		return nil
+	}
+	dst := &v1.ElasticWebSpecRoute{ServicePort: src.ServicePort, Weight: src.Weight}
+	for _, p := range src.ParentRefs {
+		dst.ParentRefs = append(dst.ParentRefs, v1.ElasticWebSpecRouteParentRef(p))
+	}
+	for _, h := range src.Hostnames {
+		dst.Hostnames = append(dst.Hostnames, v1.ElasticWebSpecRouteHostname(h))
+	}
+	for _, m := range src.Matches {
+		dst.Matches = append(dst.Matches, v1.ElasticWebSpecRoutePathMatch(m))
+	}
+	for _, b := range src.Backends {
+		dst.Backends = append(dst.Backends, v1.ElasticWebSpecRouteBackend(b))
+	}
+	return dst
+}
+
+func convertRouteFrom(src *v1.ElasticWebSpecRoute) *Route {
+	if src == nil {
+		return nil
+	}
+	dst := &Route{ServicePort: src.ServicePort, Weight: src.Weight}
+	for _, p := range src.ParentRefs {
+		dst.ParentRefs = append(dst.ParentRefs, RouteParentRef(p))
+	}
+	for _, h := range src.Hostnames {
+		dst.Hostnames = append(dst.Hostnames, RouteHostname(h))
+	}
+	for _, m := range src.Matches {
+		dst.Matches = append(dst.Matches, RoutePathMatch(m))
+	}
+	for _, b := range src.Backends {
+		dst.Backends = append(dst.Backends, RouteBackend(b))
+	}
+	return dst
+}
+
+func convertNetworkPolicyTo(src *NetworkPolicy) *v1.ElasticWebSpecNetworkPolicy {
+	if src == nil {
+		return nil
+	}
+	dst := &v1.ElasticWebSpecNetworkPolicy{AllowIngressController: src.AllowIngressController, Egress: src.Egress}
+	for _, p := range src.From {
+		dst.From = append(dst.From, v1.ElasticWebSpecNetworkPolicyPeer(p))
+	}
+	return dst
+}
+
+func convertNetworkPolicyFrom(src *v1.ElasticWebSpecNetworkPolicy) *NetworkPolicy {
+	if src == nil {
+		return nil
+	}
+	dst := &NetworkPolicy{AllowIngressController: src.AllowIngressController, Egress: src.Egress}
+	for _, p := range src.From {
+		dst.From = append(dst.From, NetworkPolicyPeer(p))
+	}
+	return dst
+}
+
+func convertPlacementTo(src *Placement) *v1.ElasticWebSpecPlacement {
+	if src == nil {
+		return nil
+	}
+	dst := &v1.ElasticWebSpecPlacement{}
+	for _, c := range src.Clusters {
+		dst.Clusters = append(dst.Clusters, v1.ElasticWebSpecPlacementCluster(c))
+	}
+	return dst
+}
+
+func convertPlacementFrom(src *v1.ElasticWebSpecPlacement) *Placement {
+	if src == nil {
+		return nil
+	}
+	dst := &Placement{}
+	for _, c := range src.Clusters {
+		dst.Clusters = append(dst.Clusters, PlacementCluster(c))
+	}
+	return dst
+}
+
+func convertServiceTo(src *Service) v1.ElasticWebSpecSvc {
+	return v1.ElasticWebSpecSvc{
+		Type:                          string(src.Type),
+		ClusterIP:                     src.ClusterIP,
+		SessionAffinity:               string(src.SessionAffinity),
+		SessionAffinityTimeoutSeconds: src.SessionAffinityTimeoutSeconds,
+		ExternalTrafficPolicy:         string(src.ExternalTrafficPolicy),
+		InternalTrafficPolicy:         string(src.InternalTrafficPolicy),
+		LoadBalancerSourceRanges:      src.LoadBalancerSourceRanges,
+		IPFamilyPolicy:                string(src.IPFamilyPolicy),
+	}
+}
+
+func convertServiceFrom(src *v1.ElasticWebSpecSvc) Service {
+	return Service{
+		Type:                          corev1.ServiceType(src.Type),
+		ClusterIP:                     src.ClusterIP,
+		SessionAffinity:               corev1.ServiceAffinity(src.SessionAffinity),
+		SessionAffinityTimeoutSeconds: src.SessionAffinityTimeoutSeconds,
+		ExternalTrafficPolicy:         corev1.ServiceExternalTrafficPolicy(src.ExternalTrafficPolicy),
+		InternalTrafficPolicy:         corev1.ServiceInternalTrafficPolicy(src.InternalTrafficPolicy),
+		LoadBalancerSourceRanges:      src.LoadBalancerSourceRanges,
+		IPFamilyPolicy:                corev1.IPFamilyPolicy(src.IPFamilyPolicy),
+	}
+}
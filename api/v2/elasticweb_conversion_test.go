@@ -0,0 +1,152 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	fuzz "github.com/google/gofuzz"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+
+	v1 "elasticweb/api/v1"
+)
+
+// fuzzIterations is how many random objects each round trip is checked with.
+const fuzzIterations = 200
+
+// Lists and maps are never empty: conversion does not tell an empty list from a missing one.
+func newFuzzer(seed int64) *fuzz.Fuzzer {
+	return fuzz.NewWithSeed(seed).NilChance(0.2).NumElements(1, 3).MaxDepth(8).Funcs(
+		// TypeMeta is set by the apiserver from the requested version, conversion does not carry it.
+		func(t *metav1.TypeMeta, c fuzz.Continue) {},
+		// v1 objects have been defaulted and validated by the webhook before they are stored,
+		// so the QPS and port pointers are never nil.
+		func(s *v1.ElasticWebSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(s)
+			s.SinglePodQPS = ptr.To(c.Int31())
+			s.TotalQPS = ptr.To(c.Int31())
+		},
+		func(p *v1.ElasticWebSpecDeployPorts, c fuzz.Continue) {
+			c.FuzzNoCustom(p)
+			p.Port = ptr.To(c.Int31())
+		},
+		func(p *v1.ElasticWebSpecSvcPorts, c fuzz.Continue) {
+			c.FuzzNoCustom(p)
+			p.Port = ptr.To(c.Int31())
+			p.TargetPort = ptr.To(intstr.FromInt32(c.Int31()))
+			if c.RandBool() {
+				p.TargetPort = ptr.To(intstr.FromString(c.RandString()))
+			}
+		},
+	)
+}
+
+var _ = Describe("ElasticWeb conversion", func() {
+	It("Should convert v1 to v2 and back without losing fields", func() {
+		f := newFuzzer(GinkgoRandomSeed())
+		for range fuzzIterations {
+			original := &v1.ElasticWeb{}
+			f.Fuzz(original)
+
+			spoke := &ElasticWeb{}
+			Expect(spoke.ConvertFrom(original.DeepCopy())).To(Succeed())
+			hub := &v1.ElasticWeb{}
+			Expect(spoke.ConvertTo(hub)).To(Succeed())
+
+			Expect(hub).To(Equal(original))
+		}
+	})
+
+	It("Should convert v2 to v1 and back without losing fields", func() {
+		f := newFuzzer(GinkgoRandomSeed())
+		for range fuzzIterations {
+			original := &ElasticWeb{}
+			f.Fuzz(original)
+
+			hub := &v1.ElasticWeb{}
+			Expect(original.DeepCopy().ConvertTo(hub)).To(Succeed())
+			spoke := &ElasticWeb{}
+			Expect(spoke.ConvertFrom(hub)).To(Succeed())
+
+			Expect(spoke).To(Equal(original))
+		}
+	})
+
+	It("Should map the v1 fields to their v2 location", func() {
+		hub := &v1.ElasticWeb{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "demo"},
+			Spec: v1.ElasticWebSpec{
+				SinglePodQPS: ptr.To[int32](500),
+				TotalQPS:     ptr.To[int32](1200),
+				Deploy: []v1.ElasticWebSpecDeploy{{
+					Name:       "tomcat",
+					Image:      "tomcat:8.0.18-jre8",
+					PullPolicy: corev1.PullAlways,
+					Ports:      []v1.ElasticWebSpecDeployPorts{{Name: "http", Port: ptr.To[int32](8080)}},
+				}},
+				Service: v1.ElasticWebSpecSvc{
+					Type:                          "NodePort",
+					Ports:                         []v1.ElasticWebSpecSvcPorts{{Name: "http", Port: ptr.To[int32](80), TargetPort: ptr.To(intstr.FromInt32(8080))}},
+					SessionAffinity:               "ClientIP",
+					SessionAffinityTimeoutSeconds: ptr.To[int32](600),
+					ExternalTrafficPolicy:         "Local",
+					IPFamilyPolicy:                "PreferDualStack",
+				},
+				PinImageDigests: true,
+				Placement: &v1.ElasticWebSpecPlacement{Clusters: []v1.ElasticWebSpecPlacementCluster{
+					{Name: "east"}, {Name: "west", Share: ptr.To[int32](2)},
+				}},
+			},
+			Status: v1.ElasticWebStatus{
+				RealQPS:  ptr.To[int32](1500),
+				Clusters: []v1.ElasticWebClusterStatus{{Name: "east", TotalQPS: 400, Ready: true}},
+			},
+		}
+
+		spoke := &ElasticWeb{}
+		Expect(spoke.ConvertFrom(hub)).To(Succeed())
+		Expect(spoke.ObjectMeta).To(Equal(hub.ObjectMeta))
+		Expect(spoke.Spec).To(Equal(ElasticWebSpec{
+			Capacity: Capacity{TotalQPS: 1200, PerPodQPS: 500},
+			Workload: Workload{
+				Containers: []Container{{
+					Name:            "tomcat",
+					Image:           "tomcat:8.0.18-jre8",
+					ImagePullPolicy: corev1.PullAlways,
+					Ports:           []ContainerPort{{Name: "http", ContainerPort: 8080}},
+				}},
+			},
+			Networking: Networking{Service: Service{
+				Type:                          corev1.ServiceTypeNodePort,
+				Ports:                         []ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromInt32(8080)}},
+				SessionAffinity:               corev1.ServiceAffinityClientIP,
+				SessionAffinityTimeoutSeconds: ptr.To[int32](600),
+				ExternalTrafficPolicy:         corev1.ServiceExternalTrafficPolicyLocal,
+				IPFamilyPolicy:                corev1.IPFamilyPolicyPreferDualStack,
+			}},
+			Rollout: Rollout{PinImageDigests: true},
+			Placement: &Placement{Clusters: []PlacementCluster{
+				{Name: "east"}, {Name: "west", Share: ptr.To[int32](2)},
+			}},
+		}))
+		Expect(spoke.Status.RealQPS).To(Equal(ptr.To[int32](1500)))
+		Expect(spoke.Status.Clusters).To(Equal([]ClusterStatus{{Name: "east", TotalQPS: 400, Ready: true}}))
+	})
+})
@@ -0,0 +1,783 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ElasticWebSpec defines the desired state of ElasticWeb.
+// 与v1相比字段按用途分组：capacity决定副本数，workload描述pod，networking描述对外暴露，rollout描述发布方式
+// +kubebuilder:validation:XValidation:rule="self.networking.service.ports.all(p, self.workload.containers.exists(c, has(c.ports) && c.ports.exists(cp, (cp.containerPort == p.targetPort || (has(cp.name) && cp.name == p.targetPort)) && (has(cp.protocol) ? cp.protocol : 'TCP') == (has(p.protocol) ? p.protocol : 'TCP'))))",message="every service targetPort must match the containerPort or name of a port with the same protocol"
+// +kubebuilder:validation:XValidation:rule="!has(self.workload.mode) || self.workload.mode != 'Variants' || self.networking.service.ports.all(p, self.workload.containers.all(c, has(c.ports) && c.ports.exists(cp, (cp.containerPort == p.targetPort || (has(cp.name) && cp.name == p.targetPort)) && (has(cp.protocol) ? cp.protocol : 'TCP') == (has(p.protocol) ? p.protocol : 'TCP'))))",message="in Variants mode every service targetPort must match a containerPort of every variant"
+type ElasticWebSpec struct {
+	// 容量：总QPS和单个pod的QPS，副本数 = ceil(totalQPS / perPodQPS)
+	Capacity Capacity `json:"capacity"`
+
+	// pod模板
+	Workload Workload `json:"workload"`
+
+	// 对外暴露方式
+	Networking Networking `json:"networking"`
+
+	// 发布方式
+	// +optional
+	Rollout Rollout `json:"rollout,omitempty"`
+
+	// 暂停：为true时controller不再创建或修改deployment、service等下属资源，只更新status
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// 删除保护：为true时，如果还有ready的pod或totalQPS不为0，webhook拒绝删除，
+	// 除非设置了confirm-delete注解
+	// +optional
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+
+	// 多集群部署：设置后本集群（hub）不运行pod，而是在每个成员集群中创建一个派生的ElasticWeb，
+	// capacity.totalQPS（或capacity.replicas）按share分配；
+	// manager没有开启hub模式时无法清理成员集群，删除会被finalizer阻塞，设置orphan-placement注解后放行
+	// +optional
+	Placement *Placement `json:"placement,omitempty"`
+}
+
+// Placement 描述ElasticWeb分布在哪些成员集群中
+type Placement struct {
+	// 成员集群，每个集群运行一个同名的派生ElasticWeb
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:XValidation:rule="self.exists(c, !has(c.share) || c.share > 0)",message="at least one cluster must have a share greater than 0"
+	// +listType=map
+	// +listMapKey=name
+	Clusters []PlacementCluster `json:"clusters"`
+}
+
+type PlacementCluster struct {
+	// 成员集群的名称，也是manager所在namespace中保存该集群kubeconfig的Secret的名称
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// 分到的QPS份额，默认1，为0时不运行pod
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Share *int32 `json:"share,omitempty"`
+}
+
+// Capacity 描述ElasticWeb需要承载的QPS
+// +kubebuilder:validation:XValidation:rule="self.totalQPS == 0 || self.totalQPS >= self.perPodQPS",message="totalQPS must be 0 or at least perPodQPS"
+type Capacity struct {
+	// 期望的总QPS，为0时不运行pod
+	// +kubebuilder:validation:Minimum=0
+	TotalQPS int32 `json:"totalQPS"`
+
+	// 单个pod能承载的QPS
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000
+	PerPodQPS int32 `json:"perPodQPS"`
+
+	// 副本数，kubectl scale、HPA、KEDA通过scale子资源修改它；
+	// 设置后副本数不再按QPS计算，清空后恢复按QPS计算
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// 冗余容量：在totalQPS之外预留余量、备用pod和可用区冗余，副本数按它们一起计算；
+	// 设置了replicas时副本数不再计算，只有可用区分布仍然生效
+	// +optional
+	Policy *CapacityPolicy `json:"policy,omitempty"`
+
+	// 空闲缩容：连续一段时间没有流量后缩容到0，第一个请求到达时由activator唤醒
+	// +optional
+	Idle *Idle `json:"idle,omitempty"`
+
+	// 压测校准perPodQPS，每次容器镜像变化后执行一次
+	// +optional
+	Calibration *Calibration `json:"calibration,omitempty"`
+}
+
+// CapacityPolicy 描述副本数在totalQPS/perPodQPS之外预留的冗余，
+// 保证失去sparePods个pod，或者失去一个可用区后剩下的pod仍能承载totalQPS
+type CapacityPolicy struct {
+	// QPS余量百分比，例如20表示按totalQPS的120%计算副本数
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1000
+	// +optional
+	HeadroomPercent int32 `json:"headroomPercent,omitempty"`
+
+	// 额外的备用pod数量（N+k）
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	SparePods int32 `json:"sparePods,omitempty"`
+
+	// pod分布的可用区数量，大于1时副本数保证失去任意一个可用区后仍能承载totalQPS
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=16
+	// +optional
+	Zones int32 `json:"zones,omitempty"`
+
+	// 区分可用区的节点标签，默认topology.kubernetes.io/zone
+	// +optional
+	ZoneTopologyKey string `json:"zoneTopologyKey,omitempty"`
+}
+
+// Idle 是空闲缩容到0的配置
+// +kubebuilder:validation:XValidation:rule="duration(self.after) > duration('0s')",message="after must be greater than 0"
+type Idle struct {
+	// 连续多长时间没有观测到流量后缩容到0，例如15m
+	After metav1.Duration `json:"after"`
+}
+
+// Workload 描述ElasticWeb的pod
+// +kubebuilder:validation:XValidation:rule="(has(self.mode) && self.mode == 'Variants') || self.containers.all(c, !has(c.weight))",message="weight can only be set when mode is Variants"
+// +kubebuilder:validation:XValidation:rule="!has(self.mode) || self.mode != 'Variants' || self.containers.exists(c, !has(c.weight) || c.weight > 0)",message="in Variants mode at least one variant must have a weight greater than 0"
+type Workload struct {
+	// containers的部署方式，默认Containers：所有容器在同一个pod中；
+	// Variants：每个容器单独一个名为<ElasticWeb名称>-<容器名称>的deployment，按weight分配副本数
+	// +optional
+	Mode DeployMode `json:"mode,omitempty"`
+
+	// pod中的容器，mode为Variants时每一项是一个变体
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=32
+	// +listType=map
+	// +listMapKey=name
+	Containers []Container `json:"containers"`
+
+	// pod级别的存储卷，容器通过volumeMounts引用
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Volumes []Volume `json:"volumes,omitempty"`
+
+	// pod级别的安全配置，未设置的字段由webhook按加固策略填充
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// pod使用的ServiceAccount，不设置时：serviceAccount.create为true则使用controller创建的同名ServiceAccount，否则使用namespace的default
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// 是否由controller为该ElasticWeb创建专用的ServiceAccount
+	// +kubebuilder:validation:XValidation:rule="self.create || !has(self.annotations) || size(self.annotations) == 0",message="annotations can only be set when create is true"
+	// +optional
+	ServiceAccount *ServiceAccount `json:"serviceAccount,omitempty"`
+
+	// 拉取私有仓库镜像用的secret
+	// +listType=atomic
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// ServiceAccount 描述controller创建并持有的ServiceAccount
+type ServiceAccount struct {
+	// 为true时controller创建名为serviceAccountName（未设置时为ElasticWeb名称）的ServiceAccount
+	Create bool `json:"create"`
+
+	// ServiceAccount上的注解
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Container 描述pod中的一个容器
+type Container struct {
+	// 容器名称，DNS-1123 label
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// 镜像拉取策略，不设置时为IfNotPresent
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// 和corev1.Container一样按端口号区分，name可以不填
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=containerPort
+	// +optional
+	Ports []ContainerPort `json:"ports,omitempty"`
+
+	// 容器的资源申请和上限，不设置时由webhook按默认策略填充
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// +optional
+	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+	// +optional
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+
+	// 容器内的挂载点，name必须是workload.volumes中声明过的
+	// +optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// 容器级别的安全配置，未设置的字段由webhook按加固策略填充
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// mode为Variants时该变体的权重，未设置时为1，为0时不运行pod
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1000
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+}
+
+// DeployMode 决定workload.containers如何部署
+// +kubebuilder:validation:Enum=Containers;Variants
+type DeployMode string
+
+// ContainerPort 描述容器监听的端口
+type ContainerPort struct {
+	// IANA_SVC_NAME，可以为空
+	// +kubebuilder:validation:MaxLength=15
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?)?$`
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ContainerPort int32 `json:"containerPort"`
+
+	// 默认TCP
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	// +optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+
+	// 应用层协议，例如h2c、grpc、kubernetes.io/ws，转发到该端口的service端口没有设置appProtocol时使用它
+	// +kubebuilder:validation:MaxLength=316
+	// +optional
+	AppProtocol string `json:"appProtocol,omitempty"`
+}
+
+// Volume 描述一个pod级别的存储卷，以下来源只能指定一个
+// +kubebuilder:validation:XValidation:rule="[has(self.emptyDir), has(self.configMap), has(self.secret), has(self.projected), has(self.persistentVolumeClaim), has(self.claimTemplate)].filter(x, x).size() == 1",message="must specify exactly one of emptyDir, configMap, secret, projected, persistentVolumeClaim, claimTemplate"
+type Volume struct {
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// +optional
+	EmptyDir *corev1.EmptyDirVolumeSource `json:"emptyDir,omitempty"`
+	// +optional
+	ConfigMap *corev1.ConfigMapVolumeSource `json:"configMap,omitempty"`
+	// +optional
+	Secret *corev1.SecretVolumeSource `json:"secret,omitempty"`
+	// +optional
+	Projected *corev1.ProjectedVolumeSource `json:"projected,omitempty"`
+	// 引用一个已经存在的PVC
+	// +optional
+	PersistentVolumeClaim *corev1.PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim,omitempty"`
+	// 由controller按模板为每个ElasticWeb创建并持有的PVC，名称为<elasticweb名称>-<volume名称>
+	// +optional
+	ClaimTemplate *VolumeClaimTemplate `json:"claimTemplate,omitempty"`
+}
+
+// VolumeClaimTemplate 是controller创建PVC时使用的模板
+type VolumeClaimTemplate struct {
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	Spec corev1.PersistentVolumeClaimSpec `json:"spec"`
+}
+
+// Networking 描述ElasticWeb如何对外暴露
+type Networking struct {
+	Service Service `json:"service"`
+
+	// Gateway API：设置后controller创建一个同名的HTTPRoute，把请求转发到ElasticWeb的service
+	// +optional
+	Route *Route `json:"route,omitempty"`
+
+	// 设置后controller创建一个同名的NetworkPolicy，只允许指定的来源访问service的端口
+	// +optional
+	NetworkPolicy *NetworkPolicy `json:"networkPolicy,omitempty"`
+}
+
+// NetworkPolicy 描述controller生成的NetworkPolicy，它选中该ElasticWeb的所有pod（包括各个变体）：
+// 入口流量只放行from中的来源、Ingress controller和activator，且只能访问service的targetPort；没有任何来源时拒绝所有入口流量
+type NetworkPolicy struct {
+	// 允许访问的来源
+	// +kubebuilder:validation:MaxItems=32
+	// +optional
+	From []NetworkPolicyPeer `json:"from,omitempty"`
+
+	// 是否放行manager配置的Ingress controller（--ingress-controller-namespace），默认true
+	// +optional
+	AllowIngressController *bool `json:"allowIngressController,omitempty"`
+
+	// 设置后pod只能访问这些目的地址，以及任意地址的53端口（DNS）；不设置时不限制出口流量
+	// +kubebuilder:validation:MaxItems=32
+	// +listType=atomic
+	// +optional
+	Egress []networkingv1.NetworkPolicyEgressRule `json:"egress,omitempty"`
+}
+
+// NetworkPolicyPeer 描述一类来源：namespaces和namespaceSelector只能设置一个，都不设置时为ElasticWeb所在的namespace；
+// podSelector不设置时为这些namespace中的所有pod
+// +kubebuilder:validation:XValidation:rule="!(has(self.namespaces) && has(self.namespaceSelector))",message="namespaces and namespaceSelector are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="has(self.namespaces) || has(self.namespaceSelector) || has(self.podSelector)",message="at least one of namespaces, namespaceSelector and podSelector is required"
+type NetworkPolicyPeer struct {
+	// namespace名称
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:items:MaxLength=63
+	// +listType=set
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// 按标签选择namespace，空的selector表示所有namespace
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// 按标签选择pod
+	// +optional
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+}
+
+// Route 描述controller生成的HTTPRoute，只有一条规则：匹配matches的请求按权重转发到各个后端
+type Route struct {
+	// HTTPRoute挂载到的Gateway
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=32
+	ParentRefs []RouteParentRef `json:"parentRefs"`
+
+	// 匹配的Host，可以以*.开头
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=set
+	// +optional
+	Hostnames []RouteHostname `json:"hostnames,omitempty"`
+
+	// 匹配的路径，任意一个匹配即可，不设置时匹配所有请求
+	// +kubebuilder:validation:MaxItems=8
+	// +optional
+	Matches []RoutePathMatch `json:"matches,omitempty"`
+
+	// 转发到ElasticWeb的service的哪个端口，默认networking.service.ports中的第一个
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	ServicePort *int32 `json:"servicePort,omitempty"`
+
+	// ElasticWeb的service的权重，默认1，和backends一起按比例分配请求
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1000000
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+
+	// 同一namespace下的其他后端service
+	// +kubebuilder:validation:MaxItems=15
+	// +optional
+	Backends []RouteBackend `json:"backends,omitempty"`
+}
+
+// RouteHostname 是Gateway API的Hostname
+// +kubebuilder:validation:MinLength=1
+// +kubebuilder:validation:MaxLength=253
+// +kubebuilder:validation:Pattern=`^(\*\.)?[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+type RouteHostname string
+
+// RouteParentRef 指向一个Gateway
+type RouteParentRef struct {
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Name string `json:"name"`
+
+	// 默认和ElasticWeb相同
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// +kubebuilder:validation:MaxLength=253
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+}
+
+// RoutePathMatch 是一个路径匹配条件
+type RoutePathMatch struct {
+	// 匹配方式，默认PathPrefix
+	// +kubebuilder:validation:Enum=Exact;PathPrefix;RegularExpression
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// +kubebuilder:validation:MaxLength=1024
+	// +kubebuilder:validation:Pattern=`^/`
+	Value string `json:"value"`
+}
+
+// RouteBackend 是ElasticWeb的service之外的一个后端
+type RouteBackend struct {
+	// 同一namespace下的service名称
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// 权重，默认1
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1000000
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+}
+
+// Service 描述controller创建的Service
+type Service struct {
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +optional
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// 只有一个端口时name可以为空，多个端口时name必须唯一
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=name
+	Ports []ServicePort `json:"ports"`
+
+	// 只能为None，表示headless service，DNS直接返回pod的IP；不设置时自动分配clusterIP。
+	// clusterIP不能修改，切换时controller会删除并重建service
+	// +kubebuilder:validation:Enum=None
+	// +optional
+	ClusterIP string `json:"clusterIP,omitempty"`
+
+	// ClientIP时同一个客户端的请求转发到同一个pod，默认None
+	// +kubebuilder:validation:Enum=None;ClientIP
+	// +optional
+	SessionAffinity corev1.ServiceAffinity `json:"sessionAffinity,omitempty"`
+
+	// sessionAffinity为ClientIP时会话保持的秒数，默认10800
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	// +optional
+	SessionAffinityTimeoutSeconds *int32 `json:"sessionAffinityTimeoutSeconds,omitempty"`
+
+	// 只对NodePort和LoadBalancer有效，Local时保留客户端源IP，只转发到本节点的pod，默认Cluster
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// +optional
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicy `json:"externalTrafficPolicy,omitempty"`
+
+	// Local时集群内的请求只转发到本节点的pod，默认Cluster
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// +optional
+	InternalTrafficPolicy corev1.ServiceInternalTrafficPolicy `json:"internalTrafficPolicy,omitempty"`
+
+	// 只对LoadBalancer有效，允许访问的客户端网段
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=set
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// 双栈集群中service使用的IP协议族，默认SingleStack
+	// +kubebuilder:validation:Enum=SingleStack;PreferDualStack;RequireDualStack
+	// +optional
+	IPFamilyPolicy corev1.IPFamilyPolicy `json:"ipFamilyPolicy,omitempty"`
+}
+
+// ServicePort 描述Service的一个端口
+type ServicePort struct {
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?)?$`
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// 转发到的容器端口，必须是某个容器声明过的端口号或端口名称
+	// +kubebuilder:validation:XIntOrString
+	TargetPort intstr.IntOrString `json:"targetPort"`
+
+	// 默认TCP
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	// +optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+
+	// 应用层协议，例如h2c、grpc、kubernetes.io/ws，不设置时使用targetPort对应的容器端口的appProtocol
+	// +kubebuilder:validation:MaxLength=316
+	// +optional
+	AppProtocol string `json:"appProtocol,omitempty"`
+}
+
+// Rollout 描述新版本如何发布
+type Rollout struct {
+	// 为true时controller把镜像的tag解析成digest，pod使用image@digest，
+	// 只有tag指向的digest变化时才会滚动更新，解析结果记录在status.imageDigests
+	// +optional
+	PinImageDigests bool `json:"pinImageDigests,omitempty"`
+}
+
+// ElasticWebStatus defines the observed state of ElasticWeb.
+type ElasticWebStatus struct {
+	// 当前实际能承载的QPS：单个pod的QPS * available的pod数量，滚动更新和pod故障期间可能低于desiredQPS
+	// +optional
+	RealQPS *int32 `json:"realQPS,omitempty"`
+
+	// 期望的pod全部available时能承载的QPS：单个pod的QPS * desiredReplicas
+	// +optional
+	DesiredQPS int32 `json:"desiredQPS,omitempty"`
+
+	// rollout.pinImageDigests为true时，每个容器镜像tag解析出的digest
+	// +optional
+	ImageDigests []ImageDigest `json:"imageDigests,omitempty"`
+
+	// deployment当前的pod数量，scale子资源的status.replicas
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// 选择该ElasticWeb的pod的label selector，HPA按它统计pod的指标
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// 期望的pod数量，capacity.replicas或按QPS计算出的值
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas,omitempty"`
+
+	// ready的pod数量
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// ready并且持续了minReadySeconds的pod数量，realQPS按它计算
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+
+	// 按capacity.policy失去sparePods个pod或者一个可用区后，期望的pod仍能承载的QPS
+	// +optional
+	SurvivableQPS int32 `json:"survivableQPS,omitempty"`
+
+	// 开启capacity.idle时，最近一次观测到流量的时间，还没有观测到流量时为开启后第一次检查的时间
+	// +optional
+	LastRequestTime *metav1.Time `json:"lastRequestTime,omitempty"`
+
+	// workload.mode为Variants时每个变体的副本数
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Variants []VariantStatus `json:"variants,omitempty"`
+
+	// 设置了networking.route时，HTTPRoute在各个Gateway上的状态
+	// +optional
+	RouteParents []RouteParentStatus `json:"routeParents,omitempty"`
+
+	// 开启capacity.calibration时，最近一次校准的结果
+	// +optional
+	Calibration *CalibrationStatus `json:"calibration,omitempty"`
+
+	// 设置了placement时每个成员集群中派生ElasticWeb的状态
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Clusters []ClusterStatus `json:"clusters,omitempty"`
+
+	// controller最近一次处理的metadata.generation
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Calibration 描述singlePodQPS的压测校准：controller单独启动一个不接入service的pod，
+// 再启动一个运行内置压测工具的Job，逐步提高QPS，找出延迟仍满足SLO的最大QPS
+type Calibration struct {
+	// 压测请求的路径，默认/
+	// +kubebuilder:validation:Pattern=`^/`
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// 延迟SLO，percentile分位的延迟不能超过它，默认100ms
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="latencySLO must be greater than 0"
+	// +optional
+	LatencySLO *metav1.Duration `json:"latencySLO,omitempty"`
+
+	// 延迟的分位，默认99
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Percentile int32 `json:"percentile,omitempty"`
+
+	// 压测的最大QPS，默认1000
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000
+	// +optional
+	MaxQPS int32 `json:"maxQPS,omitempty"`
+
+	// 每一档增加的QPS，默认maxQPS的1/20
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	StepQPS int32 `json:"stepQPS,omitempty"`
+
+	// 每一档QPS持续的时间，默认30s
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="stepDuration must be greater than 0"
+	// +optional
+	StepDuration *metav1.Duration `json:"stepDuration,omitempty"`
+
+	// 为true时把推荐值写入capacity.perPodQPS，否则只记录在status.calibration中
+	// +optional
+	Apply bool `json:"apply,omitempty"`
+}
+
+// RouteParentStatus 是HTTPRoute在一个Gateway上的状态
+type RouteParentStatus struct {
+	ParentRef RouteParentRef `json:"parentRef"`
+
+	// +optional
+	ControllerName string `json:"controllerName,omitempty"`
+
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ClusterStatus 是一个成员集群中派生ElasticWeb的状态
+type ClusterStatus struct {
+	Name string `json:"name"`
+
+	// 按share分到的totalQPS
+	TotalQPS int32 `json:"totalQPS"`
+
+	// +optional
+	RealQPS int32 `json:"realQPS,omitempty"`
+
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas,omitempty"`
+
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// 同步失败的原因
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// VariantStatus 是一个变体的deployment的状态
+type VariantStatus struct {
+	// workload.containers中的name
+	Name string `json:"name"`
+
+	// 按权重分到的副本数
+	DesiredReplicas int32 `json:"desiredReplicas"`
+
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+}
+
+// CalibrationPhase 是perPodQPS校准的阶段
+// +kubebuilder:validation:Enum=Running;Succeeded;Failed
+type CalibrationPhase string
+
+// CalibrationStatus 是最近一次校准的结果，容器镜像变化后重新校准
+type CalibrationStatus struct {
+	// Running、Succeeded或Failed
+	Phase CalibrationPhase `json:"phase"`
+
+	// 校准时使用的容器镜像
+	Images string `json:"images"`
+
+	// 延迟满足SLO的最大QPS，即推荐的capacity.perPodQPS
+	// +optional
+	RecommendedSinglePodQPS int32 `json:"recommendedSinglePodQPS,omitempty"`
+
+	// 校准完成的时间
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// 失败原因等补充信息
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ImageDigest 记录某个容器的镜像被解析成的digest
+type ImageDigest struct {
+	// 容器名称，对应spec.workload.containers[].name
+	Name string `json:"name"`
+	// spec中的镜像，包括tag
+	Image string `json:"image"`
+	// tag当前指向的digest
+	Digest string `json:"digest"`
+	// 最近一次解析出该digest的时间
+	// +optional
+	ResolvedAt metav1.Time `json:"resolvedAt,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.capacity.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+// +kubebuilder:resource:shortName=ew,categories={all,elasticweb}
+// +kubebuilder:printcolumn:name="PerPodQPS",type=integer,JSONPath=`.spec.capacity.perPodQPS`
+// +kubebuilder:printcolumn:name="TotalQPS",type=integer,JSONPath=`.spec.capacity.totalQPS`
+// +kubebuilder:printcolumn:name="DesiredQPS",type=integer,JSONPath=`.status.desiredQPS`,priority=1
+// +kubebuilder:printcolumn:name="RealQPS",type=integer,JSONPath=`.status.realQPS`
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.status.desiredReplicas`
+// +kubebuilder:printcolumn:name="ReadyReplicas",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="SurvivableQPS",type=integer,JSONPath=`.status.survivableQPS`,priority=1
+// +kubebuilder:printcolumn:name="Images",type=string,JSONPath=`.spec.workload.containers[*].image`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ElasticWeb is the Schema for the elasticwebs API.
+// Service和Deployment与ElasticWeb同名，Service名称必须是DNS-1035 label
+// +kubebuilder:validation:XValidation:rule="size(self.metadata.name) <= 63 && self.metadata.name.matches('^[a-z]([-a-z0-9]*[a-z0-9])?$')",message="metadata.name must be a DNS-1035 label"
+type ElasticWeb struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ElasticWebSpec   `json:"spec,omitempty"`
+	Status ElasticWebStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ElasticWebList contains a list of ElasticWeb.
+type ElasticWebList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ElasticWeb `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ElasticWeb{}, &ElasticWebList{})
+}
@@ -17,18 +17,28 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
+	"fmt"
+	"net"
 	"os"
+	"strconv"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -36,7 +46,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	elasticwebv1 "elasticweb/api/v1"
+	elasticwebv2 "elasticweb/api/v2"
+	"elasticweb/internal/activator"
 	"elasticweb/internal/controller"
+	"elasticweb/internal/loadgen"
 	webhookelasticwebv1 "elasticweb/internal/webhook/v1"
 	// +kubebuilder:scaffold:imports
 )
@@ -50,15 +63,31 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(elasticwebv1.AddToScheme(scheme))
+	utilruntime.Must(elasticwebv2.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
+	// The calibration Job runs the manager image as the load generator.
+	if len(os.Args) > 1 && os.Args[1] == loadgen.Command {
+		os.Exit(loadgen.Main(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var defaultingPolicyName string
+	var defaultingPolicyNamespace string
+	var imagePolicyFile string
+	var activatorAddr string
+	var prometheusURL string
+	var idleTrafficQuery string
+	var calibrationImage string
+	var ingressControllerNamespace string
+	var ingressControllerPodSelector string
+	var memberClusterNamespace string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -70,6 +99,28 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&defaultingPolicyName, "defaulting-policy-configmap", "elasticweb-defaulting-policy",
+		"Name of the ConfigMaps holding the ElasticWeb defaulting policy. Leave empty to use only the built-in defaults.")
+	flag.StringVar(&defaultingPolicyNamespace, "defaulting-policy-namespace", os.Getenv("POD_NAMESPACE"),
+		"Namespace of the cluster-wide defaulting policy ConfigMap, defaults to the namespace the manager runs in.")
+	flag.StringVar(&imagePolicyFile, "image-policy", "",
+		"Path to a YAML file restricting the registries and tags ElasticWebs may use. Leave empty to allow any image.")
+	flag.StringVar(&activatorAddr, "activator-bind-address", ":8090",
+		fmt.Sprintf("The address of the first of the %d consecutive ports the activator, which receives requests for idle ElasticWebs, binds to. ", activator.MaxPorts)+
+			"Use 0 to disable scale to zero.")
+	flag.StringVar(&prometheusURL, "prometheus-url", "",
+		"URL of the Prometheus server queried for ElasticWeb traffic. Leave empty to disable scale to zero.")
+	flag.StringVar(&idleTrafficQuery, "idle-traffic-query", activator.DefaultTrafficQuery,
+		"PromQL query template returning the request rate of an ElasticWeb, with {{.Namespace}} and {{.Name}} placeholders.")
+	flag.StringVar(&calibrationImage, "calibration-image", "",
+		"Image running the load generator of calibration Jobs. Leave empty to use the image of the manager pod.")
+	flag.StringVar(&ingressControllerNamespace, "ingress-controller-namespace", "ingress-nginx",
+		"Namespace of the ingress controller that ElasticWeb NetworkPolicies admit. Leave empty to admit no ingress controller.")
+	flag.StringVar(&ingressControllerPodSelector, "ingress-controller-pod-selector", "",
+		"Label selector of the ingress controller pods, e.g. app.kubernetes.io/name=ingress-nginx. Leave empty to admit every pod of the namespace.")
+	flag.StringVar(&memberClusterNamespace, "member-cluster-namespace", "",
+		"Namespace of the Secrets holding member cluster kubeconfigs under the \"kubeconfig\" key, one Secret per cluster. "+
+			"Setting it runs the manager as a hub that places ElasticWebs with spec.placement on those clusters.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -119,8 +170,14 @@ func main() {
 		// this setup is not recommended for production.
 	}
 
+	cacheOptions := cache.Options{ByObject: map[client.Object]cache.ByObject{}}
+	// The activator looks up ready pods of idle ElasticWebs and calibration watches its pods and Jobs;
+	// only cache pods created for an ElasticWeb.
+	cacheOptions.ByObject[&corev1.Pod{}] = cache.ByObject{Label: labels.SelectorFromSet(labels.Set{"app": controller.APP_NAME})}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
+		Cache:                  cacheOptions,
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
@@ -143,16 +200,85 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controller.ElasticWebReconciler{
+	if calibrationImage == "" {
+		if calibrationImage, err = managerImage(mgr.GetAPIReader()); err != nil {
+			setupLog.Error(err, "unable to find the manager image, calibration is disabled")
+		}
+	}
+	elasticWebReconciler := &controller.ElasticWebReconciler{
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		CalibrationImage: calibrationImage,
+		APIReader:        mgr.GetAPIReader(),
+	}
+	if ingressControllerNamespace != "" {
+		peer, err := networkPolicyPeer(ingressControllerNamespace, ingressControllerPodSelector)
+		if err != nil {
+			setupLog.Error(err, "invalid ingress controller pod selector")
+			os.Exit(1)
+		}
+		elasticWebReconciler.IngressControllerPeer = peer
+	}
+	if memberClusterNamespace != "" {
+		elasticWebReconciler.MemberClusters = &controller.MemberClusters{
+			Reader:    mgr.GetAPIReader(),
+			Namespace: memberClusterNamespace,
+			Scheme:    mgr.GetScheme(),
+		}
+	}
+	// Scale to zero needs both the activator, reachable through the pod IP, and a traffic source.
+	if activatorAddr != "0" && prometheusURL != "" {
+		_, port, err := net.SplitHostPort(activatorAddr)
+		if err != nil {
+			setupLog.Error(err, "invalid activator bind address")
+			os.Exit(1)
+		}
+		activatorPort, err := strconv.ParseInt(port, 10, 32)
+		if err != nil {
+			setupLog.Error(err, "invalid activator bind address")
+			os.Exit(1)
+		}
+		trafficSource, err := activator.NewPrometheusSource(prometheusURL, idleTrafficQuery)
+		if err != nil {
+			setupLog.Error(err, "unable to create traffic source")
+			os.Exit(1)
+		}
+		elasticWebReconciler.Activator = activator.New(elasticWebReconciler, elasticWebReconciler)
+		elasticWebReconciler.TrafficSource = trafficSource
+		elasticWebReconciler.ActivatorAddress = os.Getenv("POD_IP")
+		elasticWebReconciler.ActivatorPort = int32(activatorPort)
+		// NetworkPolicies of idle ElasticWebs admit the requests the activator forwards.
+		elasticWebReconciler.ActivatorPeer, _ = networkPolicyPeer(os.Getenv("POD_NAMESPACE"), "control-plane=controller-manager")
+		if err = mgr.Add(&activator.Server{Addr: activatorAddr, Ports: activator.MaxPorts, Handler: elasticWebReconciler.Activator}); err != nil {
+			setupLog.Error(err, "unable to add activator")
+			os.Exit(1)
+		}
+	}
+	if err = elasticWebReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ElasticWeb")
+		os.Exit(1)
+	}
+	if err = (&controller.ElasticWebQuotaReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ElasticWeb")
+		setupLog.Error(err, "unable to create controller", "controller", "ElasticWebQuota")
 		os.Exit(1)
 	}
 	// nolint:goconst
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
-		if err = webhookelasticwebv1.SetupElasticWebWebhookWithManager(mgr); err != nil {
+		var imagePolicy *webhookelasticwebv1.ImagePolicy
+		if imagePolicyFile != "" {
+			if imagePolicy, err = webhookelasticwebv1.LoadImagePolicy(imagePolicyFile); err != nil {
+				setupLog.Error(err, "unable to load image policy")
+				os.Exit(1)
+			}
+		}
+		if err = webhookelasticwebv1.SetupElasticWebWebhookWithManager(mgr, webhookelasticwebv1.WebhookOptions{
+			DefaultingPolicyNamespace: defaultingPolicyNamespace,
+			DefaultingPolicyName:      defaultingPolicyName,
+			ImagePolicy:               imagePolicy,
+		}); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "ElasticWeb")
 			os.Exit(1)
 		}
@@ -174,3 +300,35 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// managerImage returns the image of the manager container from the pod the manager runs in,
+// identified by the POD_NAME and POD_NAMESPACE environment variables.
+func managerImage(reader client.Reader) (string, error) {
+	key := client.ObjectKey{Namespace: os.Getenv("POD_NAMESPACE"), Name: os.Getenv("POD_NAME")}
+	if key.Namespace == "" || key.Name == "" {
+		return "", fmt.Errorf("POD_NAME and POD_NAMESPACE are not set")
+	}
+	pod := &corev1.Pod{}
+	if err := reader.Get(context.Background(), key, pod); err != nil {
+		return "", err
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name == "manager" {
+			return c.Image, nil
+		}
+	}
+	return "", fmt.Errorf("pod %s has no manager container", key)
+}
+
+// networkPolicyPeer selects the pods matching podSelector, or every pod when it is empty,
+// in the given namespace.
+func networkPolicyPeer(namespace, podSelector string) (*networkingv1.NetworkPolicyPeer, error) {
+	selector, err := metav1.ParseToLabelSelector(podSelector)
+	if err != nil {
+		return nil, err
+	}
+	return &networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: namespace}},
+		PodSelector:       selector,
+	}, nil
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package activator wakes idle ElasticWebs. While an ElasticWeb is scaled to zero its
+// Service points at the activator, which holds incoming requests, asks the controller
+// to scale the ElasticWeb up and proxies the requests once a pod is ready.
+package activator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var activatorlog = logf.Log.WithName("activator")
+
+// MaxPorts is how many consecutive ports, starting at the bind address, the activator
+// listens on. The EndpointSlice of an idle ElasticWeb sends its n-th Service port to the
+// n-th activator port, which is how the activator knows which port a request came in on.
+const MaxPorts = 8
+
+// Waker scales an idle ElasticWeb back up.
+type Waker interface {
+	// Wake records a request for the ElasticWeb and brings it out of idle. It is called
+	// for every request the activator receives, so implementations should be cheap when
+	// the ElasticWeb is already awake.
+	Wake(ctx context.Context, key types.NamespacedName) error
+}
+
+// BackendResolver finds where requests for an ElasticWeb can be sent.
+type BackendResolver interface {
+	// Backend returns the URL of a ready pod for the port-th Service port, or nil when no
+	// pod is ready yet.
+	Backend(ctx context.Context, key types.NamespacedName, port int) (*url.URL, error)
+}
+
+// Activator is the http.Handler idle ElasticWeb Services point at.
+type Activator struct {
+	Waker    Waker
+	Backends BackendResolver
+
+	// Timeout is how long a request is held waiting for a ready pod before it fails
+	// with 503 Service Unavailable.
+	Timeout time.Duration
+	// PollInterval is how often Backends is asked for a ready pod while a request is held.
+	PollInterval time.Duration
+
+	mu      sync.RWMutex
+	targets map[types.NamespacedName][]string
+}
+
+// New returns an Activator with the default timeouts.
+func New(waker Waker, backends BackendResolver) *Activator {
+	return &Activator{
+		Waker:        waker,
+		Backends:     backends,
+		Timeout:      2 * time.Minute,
+		PollInterval: 500 * time.Millisecond,
+	}
+}
+
+// Register makes the activator accept requests for the ElasticWeb, addressed either by
+// its Service name or by one of hosts: hostnames, which may start with a "*." wildcard
+// label, or the IP addresses of its Service.
+func (a *Activator) Register(key types.NamespacedName, hosts ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.targets == nil {
+		a.targets = map[types.NamespacedName][]string{}
+	}
+	a.targets[key] = hosts
+}
+
+// Unregister stops accepting requests for the ElasticWeb.
+func (a *Activator) Unregister(key types.NamespacedName) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.targets, key)
+}
+
+// lookup finds the ElasticWeb a request is for from its Host header. Requests coming
+// through a Gateway or an Ingress carry a public hostname, and clients that reach the
+// Service by address carry its ClusterIP or external IP; both are matched against the
+// registered hosts. Otherwise the host is the Service name the client used: <name>,
+// <name>.<namespace>, <name>.<namespace>.svc and so on. A registered host or a bare
+// <name> only matches when exactly one registered ElasticWeb claims it.
+func (a *Activator) lookup(host string) (types.NamespacedName, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+	if ip := net.ParseIP(host); ip != nil {
+		// an IP address is never a Service name
+		return a.lookupHost(ip.String())
+	}
+	if key, ok := a.lookupHost(host); ok {
+		return key, true
+	}
+	labels := strings.Split(host, ".")
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if len(labels) > 1 {
+		key := types.NamespacedName{Namespace: labels[1], Name: labels[0]}
+		_, ok := a.targets[key]
+		return key, ok
+	}
+	var found []types.NamespacedName
+	for key := range a.targets {
+		if key.Name == labels[0] {
+			found = append(found, key)
+		}
+	}
+	if len(found) != 1 {
+		return types.NamespacedName{}, false
+	}
+	return found[0], true
+}
+
+// lookupHost finds the single ElasticWeb that registered a host matching host.
+func (a *Activator) lookupHost(host string) (types.NamespacedName, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var found []types.NamespacedName
+	for key, hosts := range a.targets {
+		for _, h := range hosts {
+			if matchHostname(h, host) {
+				found = append(found, key)
+				break
+			}
+		}
+	}
+	if len(found) != 1 {
+		return types.NamespacedName{}, false
+	}
+	return found[0], true
+}
+
+// matchHostname matches host against a Gateway API hostname: a "*." prefix matches one
+// or more labels, anything else must match exactly.
+func matchHostname(hostname, host string) bool {
+	hostname = strings.ToLower(hostname)
+	if suffix, ok := strings.CutPrefix(hostname, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return hostname == host
+}
+
+// portKey is the context key of the index of the activator port a request came in on.
+type portKey struct{}
+
+func withPort(ctx context.Context, port int) context.Context {
+	return context.WithValue(ctx, portKey{}, port)
+}
+
+// portFromContext returns the index of the activator port, 0 for requests not served by Server.
+func portFromContext(ctx context.Context) int {
+	port, _ := ctx.Value(portKey{}).(int)
+	return port
+}
+
+// ServeHTTP implements http.Handler.
+func (a *Activator) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	key, ok := a.lookup(req.Host)
+	if !ok {
+		http.Error(w, "no idle ElasticWeb for host "+req.Host, http.StatusNotFound)
+		return
+	}
+	log := activatorlog.WithValues("elasticweb", key)
+
+	if err := a.Waker.Wake(req.Context(), key); err != nil {
+		log.Error(err, "wake failed")
+		http.Error(w, "failed to wake "+key.String(), http.StatusBadGateway)
+		return
+	}
+
+	backend, err := a.waitForBackend(req.Context(), key, portFromContext(req.Context()))
+	if err != nil {
+		log.Info("no ready pod", "reason", err.Error())
+		http.Error(w, key.String()+" is not ready: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	httputil.NewSingleHostReverseProxy(backend).ServeHTTP(w, req)
+}
+
+// waitForBackend polls for a ready pod until one shows up, the timeout passes or the
+// client goes away.
+func (a *Activator) waitForBackend(ctx context.Context, key types.NamespacedName, port int) (*url.URL, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(a.PollInterval)
+	defer ticker.Stop()
+	for {
+		backend, err := a.Backends.Backend(ctx, key, port)
+		if err != nil {
+			return nil, err
+		}
+		if backend != nil {
+			return backend, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Server serves an Activator until its context is cancelled. It is a manager.Runnable
+// that runs on every replica, not only on the leader.
+type Server struct {
+	// Addr is the address of the first port, the server listens on Ports consecutive ports.
+	Addr    string
+	Ports   int
+	Handler http.Handler
+}
+
+// Start implements manager.Runnable.
+func (s *Server) Start(ctx context.Context) error {
+	host, port, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	first, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("invalid activator port %q: %w", port, err)
+	}
+
+	var servers []*http.Server
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}()
+	for i := range max(s.Ports, 1) {
+		l, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(first+i)))
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, l)
+		servers = append(servers, &http.Server{
+			Handler:           s.Handler,
+			ReadHeaderTimeout: 10 * time.Second,
+			BaseContext:       func(net.Listener) context.Context { return withPort(context.Background(), i) },
+		})
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, srv := range servers {
+			_ = srv.Shutdown(shutdownCtx)
+		}
+	}()
+
+	activatorlog.Info("starting activator", "addr", s.Addr, "ports", len(servers))
+	errs := make(chan error, len(servers))
+	for i, srv := range servers {
+		go func() { errs <- srv.Serve(listeners[i]) }()
+	}
+	for range servers {
+		if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
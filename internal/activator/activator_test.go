@@ -0,0 +1,198 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakeCluster plays the controller: Wake marks the ElasticWeb awake, and the backends
+// become ready readyAfter wakes later, one per Service port.
+type fakeCluster struct {
+	mu         sync.Mutex
+	wakes      int
+	readyAfter time.Duration
+	wokenAt    time.Time
+	backends   []*url.URL
+}
+
+func (c *fakeCluster) Wake(_ context.Context, _ types.NamespacedName) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wakes++
+	if c.wokenAt.IsZero() {
+		c.wokenAt = time.Now()
+	}
+	return nil
+}
+
+func (c *fakeCluster) Backend(_ context.Context, _ types.NamespacedName, port int) (*url.URL, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.wokenAt.IsZero() || time.Since(c.wokenAt) < c.readyAfter {
+		return nil, nil
+	}
+	return c.backends[port], nil
+}
+
+// newPod serves "<name> <path>" for every request.
+func newPod(name string) *url.URL {
+	pod := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, name+" "+r.URL.Path)
+	}))
+	DeferCleanup(pod.Close)
+	backend, err := url.Parse(pod.URL)
+	Expect(err).NotTo(HaveOccurred())
+	return backend
+}
+
+var _ = Describe("Activator", func() {
+	var (
+		cluster   *fakeCluster
+		activator *Activator
+		server    *httptest.Server
+		key       = types.NamespacedName{Namespace: "demo", Name: "web"}
+	)
+
+	BeforeEach(func() {
+		cluster = &fakeCluster{readyAfter: 200 * time.Millisecond, backends: []*url.URL{newPod("http"), newPod("admin")}}
+		activator = New(cluster, cluster)
+		activator.PollInterval = 20 * time.Millisecond
+		activator.Register(key)
+		server = httptest.NewServer(activator)
+		DeferCleanup(server.Close)
+	})
+
+	get := func(host, path string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Host = host
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode, string(body)
+	}
+
+	It("Should hold requests until the woken ElasticWeb has a ready pod", func() {
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				code, body := get("web.demo.svc.cluster.local:8080", "/index.html")
+				Expect(code).To(Equal(http.StatusOK))
+				Expect(body).To(Equal("http /index.html"))
+			}()
+		}
+		wg.Wait()
+
+		Expect(cluster.wakes).To(Equal(3))
+		Expect(time.Since(cluster.wokenAt)).To(BeNumerically(">=", cluster.readyAfter))
+	})
+
+	It("Should match a bare service name when it is unambiguous", func() {
+		code, _ := get("web", "/")
+		Expect(code).To(Equal(http.StatusOK))
+
+		activator.Register(types.NamespacedName{Namespace: "other", Name: "web"})
+		code, _ = get("web", "/")
+		Expect(code).To(Equal(http.StatusNotFound))
+		code, _ = get("web.other", "/")
+		Expect(code).To(Equal(http.StatusOK))
+	})
+
+	It("Should match the route hostnames of an ElasticWeb", func() {
+		activator.Register(key, "shop.example.com", "*.shop.example.com")
+		code, _ := get("SHOP.example.com:443", "/")
+		Expect(code).To(Equal(http.StatusOK))
+		code, _ = get("eu.shop.example.com", "/")
+		Expect(code).To(Equal(http.StatusOK))
+		code, _ = get("example.com", "/")
+		Expect(code).To(Equal(http.StatusNotFound))
+
+		activator.Register(types.NamespacedName{Namespace: "other", Name: "shop"}, "shop.example.com")
+		code, _ = get("shop.example.com", "/")
+		Expect(code).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should match the service addresses of an ElasticWeb", func() {
+		code, _ := get("10.96.0.10:80", "/")
+		Expect(code).To(Equal(http.StatusNotFound))
+
+		activator.Register(key, "10.96.0.10", "fd00::10")
+		code, _ = get("10.96.0.10:80", "/")
+		Expect(code).To(Equal(http.StatusOK))
+		code, _ = get("10.96.0.10", "/")
+		Expect(code).To(Equal(http.StatusOK))
+		code, _ = get("[fd00:0::10]:80", "/")
+		Expect(code).To(Equal(http.StatusOK))
+		code, _ = get("10.96.0.11:80", "/")
+		Expect(code).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should forward to the target of the port a request came in on", func() {
+		admin := httptest.NewUnstartedServer(activator)
+		admin.Config.BaseContext = func(net.Listener) context.Context { return withPort(context.Background(), 1) }
+		admin.Start()
+		DeferCleanup(admin.Close)
+
+		req, err := http.NewRequest(http.MethodGet, admin.URL+"/metrics", nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Host = "web.demo"
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal("admin /metrics"))
+
+		_, body2 := get("web.demo", "/metrics")
+		Expect(body2).To(Equal("http /metrics"))
+	})
+
+	It("Should reject hosts that are not registered", func() {
+		code, _ := get("api.demo", "/")
+		Expect(code).To(Equal(http.StatusNotFound))
+
+		activator.Unregister(key)
+		code, _ = get("web.demo", "/")
+		Expect(code).To(Equal(http.StatusNotFound))
+		Expect(cluster.wakes).To(BeZero())
+	})
+
+	It("Should fail with 503 when no pod becomes ready in time", func() {
+		cluster.readyAfter = time.Hour
+		activator.Timeout = 100 * time.Millisecond
+		code, body := get("web.demo", "/")
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(body).To(ContainSubstring("demo/web is not ready"))
+	})
+})
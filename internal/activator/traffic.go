@@ -0,0 +1,142 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"text/template"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TrafficSource reports how much traffic an ElasticWeb currently receives. The controller
+// uses it to decide when an awake ElasticWeb has been idle long enough to scale to zero.
+type TrafficSource interface {
+	// RequestRate returns the ElasticWeb's current requests per second, or ErrNoData
+	// when the source has no samples for it.
+	RequestRate(ctx context.Context, key types.NamespacedName) (float64, error)
+}
+
+// ErrNoData is returned by a TrafficSource that has no samples for an ElasticWeb, e.g.
+// because its metrics are not scraped. It must not be mistaken for zero traffic.
+var ErrNoData = errors.New("no traffic data")
+
+// DefaultTrafficQuery is the PromQL query used when none is configured. It is rendered
+// with the ElasticWeb's Namespace and Name.
+const DefaultTrafficQuery = `sum(rate(http_requests_total{namespace="{{.Namespace}}",service="{{.Name}}"}[5m]))`
+
+// PrometheusSource is a TrafficSource backed by the Prometheus HTTP API.
+type PrometheusSource struct {
+	// URL is the Prometheus base URL, e.g. http://prometheus.monitoring:9090.
+	URL string
+	// Query renders the PromQL instant query for an ElasticWeb from its NamespacedName.
+	Query *template.Template
+	// HTTPClient is used for all requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+var _ TrafficSource = &PrometheusSource{}
+
+// NewPrometheusSource returns a PrometheusSource for the given base URL and query template.
+func NewPrometheusSource(prometheusURL, query string) (*PrometheusSource, error) {
+	tmpl, err := template.New("query").Option("missingkey=error").Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("parse traffic query: %w", err)
+	}
+	return &PrometheusSource{
+		URL:        prometheusURL,
+		Query:      tmpl,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// prometheusResponse is the subset of an instant query response the source reads.
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			// Value is [<unix time>, "<value>"].
+			Value []interface{} `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// RequestRate implements TrafficSource. The values of all returned series are summed;
+// an empty result returns ErrNoData.
+func (p *PrometheusSource) RequestRate(ctx context.Context, key types.NamespacedName) (float64, error) {
+	var query bytes.Buffer
+	if err := p.Query.Execute(&query, key); err != nil {
+		return 0, fmt.Errorf("render traffic query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		p.URL+"/api/v1/query?"+url.Values{"query": {query.String()}}.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	httpClient := p.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var body prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("decode prometheus response (%s): %w", resp.Status, err)
+	}
+	if body.Status != "success" {
+		return 0, fmt.Errorf("prometheus query failed (%s): %s", resp.Status, body.Error)
+	}
+	if body.Data.ResultType != "vector" {
+		return 0, fmt.Errorf("prometheus query returned %s, want an instant vector", body.Data.ResultType)
+	}
+
+	if len(body.Data.Result) == 0 {
+		return 0, fmt.Errorf("prometheus query %q: %w", query.String(), ErrNoData)
+	}
+
+	var rate float64
+	for _, r := range body.Data.Result {
+		if len(r.Value) != 2 {
+			return 0, fmt.Errorf("unexpected prometheus sample %v", r.Value)
+		}
+		s, ok := r.Value[1].(string)
+		if !ok {
+			return 0, fmt.Errorf("unexpected prometheus sample %v", r.Value)
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected prometheus sample %v: %w", r.Value, err)
+		}
+		rate += v
+	}
+	return rate, nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("PrometheusSource", func() {
+	var (
+		response string
+		queries  []string
+		source   *PrometheusSource
+		key      = types.NamespacedName{Namespace: "demo", Name: "web"}
+	)
+
+	BeforeEach(func() {
+		queries = nil
+		prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/api/v1/query"))
+			queries = append(queries, r.URL.Query().Get("query"))
+			_, _ = io.WriteString(w, response)
+		}))
+		DeferCleanup(prometheus.Close)
+
+		var err error
+		source, err = NewPrometheusSource(prometheus.URL, DefaultTrafficQuery)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Should sum the returned series", func() {
+		response = `{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"pod":"a"},"value":[1700000000,"1.5"]},
+			{"metric":{"pod":"b"},"value":[1700000000,"0.25"]}]}}`
+		Expect(source.RequestRate(context.Background(), key)).To(Equal(1.75))
+		Expect(queries).To(ConsistOf(`sum(rate(http_requests_total{namespace="demo",service="web"}[5m]))`))
+	})
+
+	It("Should not treat an empty result as no traffic", func() {
+		response = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+		_, err := source.RequestRate(context.Background(), key)
+		Expect(err).To(MatchError(ErrNoData))
+
+		response = `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"0"]}]}}`
+		Expect(source.RequestRate(context.Background(), key)).To(BeZero())
+	})
+
+	It("Should return query errors", func() {
+		response = `{"status":"error","errorType":"bad_data","error":"parse error"}`
+		_, err := source.RequestRate(context.Background(), key)
+		Expect(err).To(MatchError(ContainSubstring("parse error")))
+	})
+})
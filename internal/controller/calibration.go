@@ -0,0 +1,399 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	elasticwebv1 "elasticweb/api/v1"
+	"elasticweb/internal/loadgen"
+)
+
+const (
+	// 校准进行中时，检查pod和Job状态的间隔
+	CALIBRATION_CHECK_INTERVAL = 10 * time.Second
+	// 校准用的pod和Job的名称后缀
+	CALIBRATION_SUFFIX = "-calibration"
+	// 压测容器的名称
+	CALIBRATION_CONTAINER = "loadgen"
+	// 校准的默认参数
+	DEFAULT_CALIBRATION_LATENCY_SLO   = 100 * time.Millisecond
+	DEFAULT_CALIBRATION_PERCENTILE    = 99
+	DEFAULT_CALIBRATION_MAX_QPS       = 1000
+	DEFAULT_CALIBRATION_STEP_DURATION = 30 * time.Second
+)
+
+// deployMode为Variants时所有变体共用singlePodQPS，只压测第一个权重大于0的变体，返回它在spec.deploy中的下标；
+// 其他情况返回-1，压测包含所有容器的pod
+func getCalibrationVariant(elasticWeb *elasticwebv1.ElasticWeb) int {
+	if !elasticWeb.IsVariantMode() {
+		return -1
+	}
+	for i := range elasticWeb.Spec.Deploy {
+		if elasticWeb.Spec.Deploy[i].GetWeight() > 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// 校准时使用的容器镜像，镜像变化后重新校准
+func getCalibrationImages(elasticWeb *elasticwebv1.ElasticWeb) string {
+	var images []string
+	variant := getCalibrationVariant(elasticWeb)
+	for i := range elasticWeb.Spec.Deploy {
+		if variant < 0 || variant == i {
+			images = append(images, elasticWeb.Spec.Deploy[i].Name+"="+getImage(elasticWeb, &elasticWeb.Spec.Deploy[i]))
+		}
+	}
+	return strings.Join(images, ",")
+}
+
+// 校准用的pod和Job的标签：没有NameLabel，service和status.selector都不会选中它们
+func getCalibrationLabels(elasticWeb *elasticwebv1.ElasticWeb) map[string]string {
+	return map[string]string{
+		"app":                         APP_NAME,
+		elasticwebv1.CalibrationLabel: elasticWeb.Name,
+	}
+}
+
+// 1.没有开启spec.calibration时，停止进行中的校准，清空status.calibration；
+// 2.容器镜像和上次校准时不同（或者从未校准过）时开始校准：先创建一个不接入service的pod；
+// 3.pod ready后创建Job，用manager镜像中的loadgen子命令对这个pod压测；
+// 4.Job结束后从它的pod的termination message读出结果，写入status.calibration，spec.calibration.apply为true时同时修改spec.singlePodQPS；
+// 5.结束后删除校准用的pod和Job。
+// 返回下一次检查校准状态的间隔，为0表示没有进行中的校准
+func reconcileCalibration(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) (time.Duration, error) {
+	status := elasticWeb.Status.Calibration
+	if elasticWeb.Spec.Calibration == nil {
+		if status == nil {
+			return 0, nil
+		}
+		if status.Phase == elasticwebv1.CalibrationRunning {
+			if err := deleteCalibrationObjects(ctx, r, elasticWeb); err != nil {
+				return 0, err
+			}
+		}
+		elasticWeb.Status.Calibration = nil
+		return 0, r.Status().Update(ctx, elasticWeb)
+	}
+
+	images := getCalibrationImages(elasticWeb)
+	if status != nil && status.Images == images && status.Phase != elasticwebv1.CalibrationRunning {
+		return 0, nil
+	}
+
+	// 镜像变化了，进行中的校准作废，重新开始
+	if status == nil || status.Images != images {
+		if status != nil && status.Phase == elasticwebv1.CalibrationRunning {
+			if err := deleteCalibrationObjects(ctx, r, elasticWeb); err != nil {
+				return 0, err
+			}
+		}
+		if r.CalibrationImage == "" {
+			return 0, finishCalibration(ctx, r, elasticWeb, images, 0, "the manager has no load generator image configured")
+		}
+		log.Info("start calibration for images " + images)
+		elasticWeb.Status.Calibration = &elasticwebv1.ElasticWebCalibrationStatus{
+			Phase:  elasticwebv1.CalibrationRunning,
+			Images: images,
+		}
+		if err := r.Status().Update(ctx, elasticWeb); err != nil {
+			log.Error(err, "update calibration status error")
+			return 0, err
+		}
+	}
+
+	ports := elasticWeb.Spec.Service.Ports
+	if len(ports) == 0 || ports[0].TargetPort == nil {
+		return 0, finishCalibration(ctx, r, elasticWeb, images, 0, "no service port to send requests to")
+	}
+
+	// 被压测的pod
+	key := types.NamespacedName{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name + CALIBRATION_SUFFIX}
+	pod := &corev1.Pod{}
+	if err := r.Get(ctx, key, pod); err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error(err, "query calibration pod error")
+			return 0, err
+		}
+		return CALIBRATION_CHECK_INTERVAL, createCalibrationPod(ctx, r, elasticWeb)
+	}
+	if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+		return 0, finishCalibration(ctx, r, elasticWeb, images, 0, "calibration pod exited: "+pod.Status.Reason)
+	}
+	if pod.Status.PodIP == "" || !isPodReady(pod) {
+		log.Info("waiting for calibration pod to be ready")
+		return CALIBRATION_CHECK_INTERVAL, nil
+	}
+
+	// 压测的Job
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, key, job); err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error(err, "query calibration job error")
+			return 0, err
+		}
+		port, ok := getPodPort(pod, &ports[0])
+		if !ok {
+			return 0, finishCalibration(ctx, r, elasticWeb, images, 0, fmt.Sprintf("calibration pod does not declare targetport %s", ports[0].TargetPort))
+		}
+		target := fmt.Sprintf("http://%s%s", net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(port))), getCalibrationPath(elasticWeb))
+		return CALIBRATION_CHECK_INTERVAL, createCalibrationJob(ctx, r, elasticWeb, target)
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return 0, finishCalibration(ctx, r, elasticWeb, images, 0, "load generator job failed: "+c.Message)
+		}
+	}
+	if job.Status.Succeeded == 0 {
+		log.Info("waiting for calibration job to complete")
+		return CALIBRATION_CHECK_INTERVAL, nil
+	}
+
+	result, err := getCalibrationResult(ctx, r, job)
+	if err != nil {
+		return 0, finishCalibration(ctx, r, elasticWeb, images, 0, err.Error())
+	}
+	if result.SustainableQPS == 0 {
+		return 0, finishCalibration(ctx, r, elasticWeb, images, 0, "no tested QPS met the latency SLO")
+	}
+	return 0, finishCalibration(ctx, r, elasticWeb, images, int32(result.SustainableQPS), "")
+}
+
+// 压测请求的路径，默认/
+func getCalibrationPath(elasticWeb *elasticwebv1.ElasticWeb) string {
+	if elasticWeb.Spec.Calibration.Path == "" {
+		return "/"
+	}
+	return elasticWeb.Spec.Calibration.Path
+}
+
+// 校准用的pod和deployment（或者被压测的变体的deployment）的pod完全相同，只是标签不同，不接收service的流量
+func createCalibrationPod(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: elasticWeb.Namespace,
+			Name:      elasticWeb.Name + CALIBRATION_SUFFIX,
+			Labels:    getCalibrationLabels(elasticWeb),
+		},
+		Spec: getPodSpec(elasticWeb),
+	}
+	if variant := getCalibrationVariant(elasticWeb); variant >= 0 {
+		pod.Spec = getVariantPodSpec(elasticWeb, variant)
+	}
+	// 只有一个pod，不需要可用区分布
+	pod.Spec.TopologySpreadConstraints = nil
+
+	if err := controllerutil.SetControllerReference(elasticWeb, pod, r.Scheme); err != nil {
+		log.Error(err, "SetControllerReference error")
+		return err
+	}
+	log.Info("start create calibration pod")
+	if err := r.Create(ctx, pod); err != nil {
+		log.Error(err, "create calibration pod error")
+		return err
+	}
+	return nil
+}
+
+// 压测Job，参数来自spec.calibration，未设置的使用默认值
+func createCalibrationJob(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb, target string) error {
+	spec := elasticWeb.Spec.Calibration
+	latencySLO := DEFAULT_CALIBRATION_LATENCY_SLO
+	if spec.LatencySLO != nil {
+		latencySLO = spec.LatencySLO.Duration
+	}
+	percentile := int32(DEFAULT_CALIBRATION_PERCENTILE)
+	if spec.Percentile > 0 {
+		percentile = spec.Percentile
+	}
+	maxQPS := int32(DEFAULT_CALIBRATION_MAX_QPS)
+	if spec.MaxQPS > 0 {
+		maxQPS = spec.MaxQPS
+	}
+	stepQPS := max(maxQPS/20, 1)
+	if spec.StepQPS > 0 {
+		stepQPS = spec.StepQPS
+	}
+	stepDuration := DEFAULT_CALIBRATION_STEP_DURATION
+	if spec.StepDuration != nil {
+		stepDuration = spec.StepDuration.Duration
+	}
+	// 所有档位跑完的时间再加5分钟拉取镜像、启动的余量
+	steps := int64((maxQPS + stepQPS - 1) / stepQPS)
+	deadline := int64((time.Duration(steps)*stepDuration + 5*time.Minute).Seconds())
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: elasticWeb.Namespace,
+			Name:      elasticWeb.Name + CALIBRATION_SUFFIX,
+			Labels:    getCalibrationLabels(elasticWeb),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          ptr.To[int32](0),
+			ActiveDeadlineSeconds: ptr.To(deadline),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: getCalibrationLabels(elasticWeb),
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot:   ptr.To(true),
+						SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+					},
+					Containers: []corev1.Container{{
+						Name:    CALIBRATION_CONTAINER,
+						Image:   r.CalibrationImage,
+						Command: []string{"/manager", loadgen.Command},
+						Args: []string{
+							"--url=" + target,
+							fmt.Sprintf("--start-qps=%d", stepQPS),
+							fmt.Sprintf("--step-qps=%d", stepQPS),
+							fmt.Sprintf("--max-qps=%d", maxQPS),
+							"--step-duration=" + stepDuration.String(),
+							"--latency-slo=" + latencySLO.String(),
+							fmt.Sprintf("--percentile=%d", percentile),
+						},
+						SecurityContext: &corev1.SecurityContext{
+							AllowPrivilegeEscalation: ptr.To(false),
+							ReadOnlyRootFilesystem:   ptr.To(true),
+							Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(elasticWeb, job, r.Scheme); err != nil {
+		log.Error(err, "SetControllerReference error")
+		return err
+	}
+	log.Info("start create calibration job, target " + target)
+	if err := r.Create(ctx, job); err != nil {
+		log.Error(err, "create calibration job error")
+		return err
+	}
+	return nil
+}
+
+// 从Job的pod的termination message中读出压测结果
+func getCalibrationResult(ctx context.Context, r *ElasticWebReconciler, job *batchv1.Job) (*loadgen.Result, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{batchv1.JobNameLabel: job.Name}); err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Name != CALIBRATION_CONTAINER || cs.State.Terminated == nil || cs.State.Terminated.ExitCode != 0 {
+				continue
+			}
+			result := &loadgen.Result{}
+			if err := json.Unmarshal([]byte(cs.State.Terminated.Message), result); err != nil {
+				return nil, fmt.Errorf("invalid load generator result in pod %s: %w", pod.Name, err)
+			}
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("no load generator result found in the pods of job %s", job.Name)
+}
+
+// 删除校准用的pod和Job，Job的pod随Job一起删除
+func deleteCalibrationObjects(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	meta := metav1.ObjectMeta{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name + CALIBRATION_SUFFIX}
+	if err := r.Delete(ctx, &batchv1.Job{ObjectMeta: meta}, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "delete calibration job error")
+		return err
+	}
+	if err := r.Delete(ctx, &corev1.Pod{ObjectMeta: meta}); err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "delete calibration pod error")
+		return err
+	}
+	return nil
+}
+
+// 结束校准：清理pod和Job，记录结果和事件，recommended为0表示失败，message是失败原因
+func finishCalibration(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb, images string, recommended int32, message string) error {
+	if err := deleteCalibrationObjects(ctx, r, elasticWeb); err != nil {
+		return err
+	}
+
+	status := &elasticwebv1.ElasticWebCalibrationStatus{
+		Phase:                   elasticwebv1.CalibrationSucceeded,
+		Images:                  images,
+		RecommendedSinglePodQPS: recommended,
+		CompletionTime:          ptr.To(metav1.Now()),
+		Message:                 message,
+	}
+	if recommended == 0 {
+		status.Phase = elasticwebv1.CalibrationFailed
+	}
+	elasticWeb.Status.Calibration = status
+	if err := r.Status().Update(ctx, elasticWeb); err != nil {
+		log.Error(err, "update calibration status error")
+		return err
+	}
+
+	if recommended == 0 {
+		log.Info("calibration failed: " + message)
+		if r.Recorder != nil {
+			r.Recorder.Event(elasticWeb, corev1.EventTypeWarning, "CalibrationFailed", message)
+		}
+		return nil
+	}
+	log.Info(fmt.Sprintf("calibration succeeded, recommended singlePodQPS [%d]", recommended))
+	if r.Recorder != nil {
+		r.Recorder.Event(elasticWeb, corev1.EventTypeNormal, "Calibrated",
+			fmt.Sprintf("recommended singlePodQPS is %d", recommended))
+	}
+
+	// 推荐值不能超过singlePodQPS的上限，也不能超过totalQPS（totalQPS为0或者不小于singlePodQPS）
+	if !elasticWeb.Spec.Calibration.Apply {
+		return nil
+	}
+	singlePodQPS := min(recommended, DEFAULT_CALIBRATION_MAX_QPS)
+	if elasticWeb.Spec.TotalQPS != nil && *elasticWeb.Spec.TotalQPS > 0 {
+		singlePodQPS = min(singlePodQPS, *elasticWeb.Spec.TotalQPS)
+	}
+	if elasticWeb.Spec.SinglePodQPS != nil && *elasticWeb.Spec.SinglePodQPS == singlePodQPS {
+		return nil
+	}
+	elasticWeb.Spec.SinglePodQPS = ptr.To(singlePodQPS)
+	log.Info(fmt.Sprintf("apply calibrated singlePodQPS [%d]", singlePodQPS))
+	if err := r.Update(ctx, elasticWeb); err != nil {
+		log.Error(err, "apply calibrated singlePodQPS error")
+		return err
+	}
+	return nil
+}
@@ -19,13 +19,19 @@ package controller
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -33,9 +39,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	elasticwebv1 "elasticweb/api/v1"
+	"elasticweb/internal/activator"
+	"elasticweb/internal/registry"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -50,6 +60,8 @@ const (
 	MEM_REQUEST = "512Mi"
 	// 单个POD的内存资源上限
 	MEM_LIMIT = "512Mi"
+	// 实际QPS还没有达到期望QPS时，重新检查deployment状态的间隔
+	CONVERGE_CHECK_INTERVAL = 10 * time.Second
 )
 
 var (
@@ -61,6 +73,34 @@ type ElasticWebReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	// ImageResolver 在spec.pinImageDigests为true时把镜像tag解析成digest
+	ImageResolver registry.Resolver
+
+	// Recorder 记录暂停、恢复等事件
+	Recorder record.EventRecorder
+
+	// Activator 在elasticWeb空闲时接收请求，为nil时不会缩容到0
+	Activator *activator.Activator
+	// TrafficSource 查询elasticWeb的流量，判断是否空闲
+	TrafficSource activator.TrafficSource
+	// ActivatorAddress 和ActivatorPort 是空闲elasticWeb的service指向的activator地址，通常是manager的pod IP
+	ActivatorAddress string
+	ActivatorPort    int32
+
+	// CalibrationImage 是运行压测Job的镜像（manager镜像，带有loadgen子命令），为空时校准直接失败
+	CalibrationImage string
+
+	// IngressControllerPeer 选中Ingress controller的pod，spec.networkPolicy生成的NetworkPolicy默认放行它，为nil时不放行
+	IngressControllerPeer *networkingv1.NetworkPolicyPeer
+	// ActivatorPeer 选中manager的pod，开启缩容到0时NetworkPolicy要放行activator转发的请求
+	ActivatorPeer *networkingv1.NetworkPolicyPeer
+
+	// APIReader 直接读取apiserver，用于读取Secret，避免manager缓存所有Secret；为nil时使用Client
+	APIReader client.Reader
+
+	// MemberClusters 是hub模式下的成员集群，设置了spec.placement的ElasticWeb分发到这些集群中，为nil时不支持spec.placement
+	MemberClusters *MemberClusters
 }
 
 // +kubebuilder:rbac:groups=elasticweb.com.bolingcavalry,resources=elasticwebs,verbs=get;list;watch;create;update;patch;delete
@@ -68,6 +108,15 @@ type ElasticWebReconciler struct {
 // +kubebuilder:rbac:groups=elasticweb.com.bolingcavalry,resources=elasticwebs/finalizers,verbs=update
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=networking,resources=ingresss,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -104,6 +153,124 @@ func (r *ElasticWebReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	log.Info("3. instance: " + instance.String())
 
+	// 删除时先清理成员集群中派生的ElasticWeb，不管hub模式是否开启，否则finalizer会一直阻塞删除
+	if !instance.DeletionTimestamp.IsZero() && controllerutil.ContainsFinalizer(instance, elasticwebv1.PlacementFinalizer) {
+		log.Info("3.0 remove placement on delete")
+		return ctrl.Result{}, removePlacement(ctx, r, instance)
+	}
+
+	// 设置了spec.placement时工作负载运行在成员集群中，本集群只同步派生的ElasticWeb和汇总状态
+	if instance.Spec.Placement != nil {
+		log.Info("3.0 reconcile placement")
+		return reconcilePlacement(ctx, r, instance)
+	}
+
+	// 去掉了spec.placement，先删除成员集群中派生的ElasticWeb，再在本集群部署
+	if controllerutil.ContainsFinalizer(instance, elasticwebv1.PlacementFinalizer) {
+		if err = removePlacement(ctx, r, instance); err != nil {
+			log.Error(err, "3.0 remove placement error")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// 暂停时不创建、不修改任何下属资源，只根据现有的deployment更新状态
+	if instance.Spec.Paused {
+		log.Info("3.0 reconcile paused")
+		var deployments []*appsv1.Deployment
+		if instance.IsVariantMode() {
+			if deployments, err = listVariantDeployments(ctx, r, instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		} else {
+			deployment := &appsv1.Deployment{}
+			if err = r.Get(ctx, req.NamespacedName, deployment); err != nil {
+				if !errors.IsNotFound(err) {
+					return ctrl.Result{}, err
+				}
+				deployment = nil
+			} else if !metav1.IsControlledBy(deployment, instance) {
+				deployment = nil
+			}
+			deployments = append(deployments, deployment)
+		}
+		if err = updateStatus(ctx, r, instance, deployments...); err != nil {
+			log.Error(err, "3.0 update status error")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// 按模板创建该实例持有的PVC，deployment中的pod会挂载它们
+	if err = createPVCsIfNotExists(ctx, r, instance); err != nil {
+		log.Error(err, "3.1 create pvc error")
+		return ctrl.Result{}, err
+	}
+
+	// pod要用到的ServiceAccount也要先于deployment准备好
+	if err = createOrUpdateServiceAccount(ctx, r, instance); err != nil {
+		log.Error(err, "3.2 create serviceaccount error")
+		return ctrl.Result{}, err
+	}
+
+	// 开启了digest固定时，先解析镜像digest，deployment中使用image@digest
+	if err = resolveImageDigests(ctx, r, instance); err != nil {
+		log.Error(err, "3.3 resolve image digests error")
+		return ctrl.Result{}, err
+	}
+
+	// tag随时可能指向新的digest，需要定期重新解析
+	result := ctrl.Result{}
+	if instance.Spec.PinImageDigests {
+		result.RequeueAfter = IMAGE_RESOLVE_INTERVAL
+	}
+
+	// 开启了空闲缩容时，根据流量判断是否进入空闲状态，空闲时期望的副本数为0
+	idleRequeueAfter, err := reconcileIdle(ctx, r, instance)
+	if err != nil {
+		log.Error(err, "3.4 reconcile idle error")
+		return ctrl.Result{}, err
+	}
+	requeueAfter(&result, idleRequeueAfter)
+
+	// 开启了校准时，镜像变化后用压测Job测出单个pod能承受的QPS
+	calibrationRequeueAfter, err := reconcileCalibration(ctx, r, instance)
+	if err != nil {
+		log.Error(err, "3.5 reconcile calibration error")
+		return ctrl.Result{}, err
+	}
+	requeueAfter(&result, calibrationRequeueAfter)
+
+	// 设置了spec.route时，通过Gateway API的HTTPRoute对外暴露service
+	if err = reconcileRoute(ctx, r, instance); err != nil {
+		log.Error(err, "3.6 reconcile route error")
+		return ctrl.Result{}, err
+	}
+
+	// 设置了spec.networkPolicy时，只允许指定的来源访问pod
+	if err = reconcileNetworkPolicy(ctx, r, instance); err != nil {
+		log.Error(err, "3.7 reconcile networkpolicy error")
+		return ctrl.Result{}, err
+	}
+
+	// deployMode为Variants时每个变体单独一个deployment，走另外的流程
+	if instance.IsVariantMode() {
+		converged, err := reconcileVariants(ctx, r, instance)
+		if err != nil {
+			log.Error(err, "3.8 reconcile variants error")
+			return ctrl.Result{}, err
+		}
+		if !converged {
+			requeueAfter(&result, CONVERGE_CHECK_INTERVAL)
+		}
+		return result, nil
+	}
+
+	// 从Variants切换回Containers时，删除各个变体的deployment
+	if err = deleteStaleVariantDeployments(ctx, r, instance, nil); err != nil {
+		log.Error(err, "3.8 delete variant deployments error")
+		return ctrl.Result{}, err
+	}
+
 	// 查找deployment
 	deployment := &appsv1.Deployment{}
 
@@ -117,13 +284,17 @@ func (r *ElasticWebReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			log.Info("4. deployment not exists")
 
 			// 如果对QPS没有需求，此时又没有deployment，就啥事都不做了
-			if *(instance.Spec.TotalQPS) < 1 {
+			if getExpectReplicas(instance) < 1 {
 				log.Info("5.1 not need deployment")
-				return ctrl.Result{}, nil
+				if err = updateStatus(ctx, r, instance, nil); err != nil {
+					log.Error(err, "5.1 update status error")
+					return ctrl.Result{}, err
+				}
+				return result, nil
 			}
 
 			// 先要创建service
-			if err = createServiceIfNotExists(ctx, r, instance, req); err != nil {
+			if err = createOrUpdateService(ctx, r, instance); err != nil {
 				log.Error(err, "5.2 error")
 				return ctrl.Result{}, err
 			}
@@ -135,21 +306,27 @@ func (r *ElasticWebReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			}
 
 			// 如果创建成功就更新状态
-			if err = updateStatus(ctx, r, instance); err != nil {
+			if err = updateStatus(ctx, r, instance, nil); err != nil {
 				log.Error(err, "5.4 update status error")
 				return ctrl.Result{}, nil
 			}
 
-			// 创建成功就可以返回了
-			return ctrl.Result{}, nil
+			// 创建成功就可以返回了，pod启动期间定期检查实际QPS
+			requeueAfter(&result, CONVERGE_CHECK_INTERVAL)
+			return result, nil
 		} else {
 			log.Error(err, "7. error")
 			return ctrl.Result{}, err
 		}
 	}
 
+	// 同名的deployment不是该ElasticWeb创建的，不能接管和修改它
+	if !metav1.IsControlledBy(deployment, instance) {
+		return ctrl.Result{}, fmt.Errorf("deployment %s already exists and is not owned by the ElasticWeb", req.NamespacedName)
+	}
+
 	// 如果查到了deployment，并且没有返回错误，就走下面的逻辑
-	// 根据单QPS和总QPS计算期望的副本数
+	// 期望的副本数，spec.replicas（scale子资源）优先，否则根据单QPS和总QPS计算
 	expectReplicas := getExpectReplicas(instance)
 
 	// 当前deployment的期望副本数
@@ -174,14 +351,6 @@ func (r *ElasticWebReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			log.Error(err, "12. update deployment replicas error")
 			return ctrl.Result{}, err
 		}
-
-		log.Info("13. update status")
-
-		// 如果更新deployment的Replicas成功，就更新状态
-		if err = updateStatus(ctx, r, instance); err != nil {
-			log.Error(err, "14. update status error")
-			return ctrl.Result{}, err
-		}
 	}
 	var needUpdate bool
 	deployment, needUpdate = getDiffDeployment(ctx, instance, deployment)
@@ -193,60 +362,131 @@ func (r *ElasticWebReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 	}
 
-	return ctrl.Result{}, nil
-}
+	// spec.service可能修改过，同步到service
+	if err = createOrUpdateService(ctx, r, instance); err != nil {
+		log.Error(err, "16. create or update service error")
+		return ctrl.Result{}, err
+	}
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *ElasticWebReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&elasticwebv1.ElasticWeb{}).
-		Named("elasticweb").
-		Complete(r)
-}
+	// 空闲或者还没有ready的pod时，service指向activator
+	if err = syncServiceEndpoints(ctx, r, instance, deployment.Status.ReadyReplicas); err != nil {
+		log.Error(err, "16. sync service endpoints error")
+		return ctrl.Result{}, err
+	}
 
-func getExpectReplicas(elasticWeb *elasticwebv1.ElasticWeb) int32 {
-	// 单POD的QPS
-	singlePodQPS := *(elasticWeb.Spec.SinglePodQPS)
+	// deployment的副本数变化也会触发Reconcile，每次都同步状态，scale子资源和HPA依赖它
+	log.Info("13. update status")
+	if err = updateStatus(ctx, r, instance, deployment); err != nil {
+		log.Error(err, "14. update status error")
+		return ctrl.Result{}, err
+	}
 
-	// 期望的总QPS
-	totalQPS := *(elasticWeb.Spec.TotalQPS)
+	// 滚动更新、扩缩容、pod故障期间实际QPS和期望QPS不一致，定期重新检查直到一致
+	if !isConverged(instance, deployment) {
+		log.Info(fmt.Sprintf("17. realQPS [%d] not converged to desiredQPS [%d], requeue", *instance.Status.RealQPS, instance.Status.DesiredQPS))
+		requeueAfter(&result, CONVERGE_CHECK_INTERVAL)
+	}
 
-	replicas := totalQPS / singlePodQPS
+	return result, nil
+}
 
-	if totalQPS%singlePodQPS > 0 {
-		replicas++
+// 设置下一次Reconcile的时间，已经设置过时取更早的一次
+func requeueAfter(result *ctrl.Result, after time.Duration) {
+	if after > 0 && (result.RequeueAfter == 0 || after < result.RequeueAfter) {
+		result.RequeueAfter = after
 	}
-	return replicas
 }
 
-// 1.先查看service是否存在，不存在才创建；
-// 2.将service和CRD实例elasticWeb建立关联(controllerutil.SetControllerReference方法)，这样当elasticWeb被删除的时候，service会被自动删除而无需我们干预；
-// 3.创建service的时候用到了client-go工具，推荐您阅读《client-go实战系列》,工具越熟练，编码越尽兴；
-func createServiceIfNotExists(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb, req ctrl.Request) error {
-	service := &corev1.Service{}
-	err := r.Get(ctx, req.NamespacedName, service)
+// deployment已经按最新的spec完成滚动更新，并且available的pod数量和期望一致；
+// deployMode为Variants时每个变体的deployment都要完成，nil表示deployment不存在
+func isConverged(elasticWeb *elasticwebv1.ElasticWeb, deployments ...*appsv1.Deployment) bool {
+	var replicas int32
+	for _, deployment := range deployments {
+		if deployment == nil {
+			continue
+		}
+		desired := *deployment.Spec.Replicas
+		status := &deployment.Status
+		if status.ObservedGeneration < deployment.Generation ||
+			status.UpdatedReplicas != desired ||
+			status.Replicas != desired ||
+			status.AvailableReplicas != desired {
+			return false
+		}
+		replicas += desired
+	}
+	return replicas == elasticWeb.Status.DesiredReplicas
+}
 
-	// 如果查询结果没有错误，证明service正常，就不做任何操作
-	if err == nil {
-		log.Info("[%s] service exists", service)
+// SetupWithManager sets up the controller with the Manager.
+func (r *ElasticWebReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.ImageResolver == nil {
+		r.ImageResolver = registry.NewClient()
+	}
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("elasticweb-controller")
+	}
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&elasticwebv1.ElasticWeb{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Owns(&batchv1.Job{}).
+		Owns(&corev1.Pod{})
+
+	// 集群安装了Gateway API时才监听HTTPRoute，Gateway controller更新它的status后同步到ElasticWeb
+	if _, err := mgr.GetRESTMapper().RESTMapping(httpRouteGVK.GroupKind(), httpRouteGVK.Version); err == nil {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		builder = builder.Owns(route)
+	} else {
+		mgr.GetLogger().Info("Gateway API is not installed, HTTPRoutes are not watched", "error", err.Error())
 	}
 
+	return builder.Named("elasticweb").Complete(r)
+}
+
+// 1.service不存在就创建，并和elasticWeb建立关联(controllerutil.SetControllerReference方法)，这样当elasticWeb被删除的时候，service会被自动删除而无需我们干预；
+// 2.已存在时按spec.service更新type、端口、会话保持、流量策略等，selector由syncServiceEndpoints维护；
+// 3.clusterIP不能修改，headless和普通service之间切换时先删除，service的删除事件触发下一次Reconcile再创建。
+func createOrUpdateService(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	service := &corev1.Service{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name}, service)
+
 	// 如果错误不是NotFound，就返回错误
-	if !errors.IsNotFound(err) {
+	if err != nil && !errors.IsNotFound(err) {
 		log.Error(err, "query service error")
 		return err
 	}
 
-	// 实例化service ports
-	var SvcPorts []corev1.ServicePort
-	for _, v := range elasticWeb.Spec.Service.Ports {
-		tmp := corev1.ServicePort{
-			Name:       v.Name,
-			Protocol:   corev1.ProtocolTCP,
-			Port:       *v.Port,
-			TargetPort: intstr.FromInt(int(*v.TargetPort)),
+	if err == nil {
+		if !metav1.IsControlledBy(service, elasticWeb) {
+			return fmt.Errorf("service %s/%s already exists and is not owned by the ElasticWeb", service.Namespace, service.Name)
+		}
+		// 正在删除，等删除完成后再创建
+		if service.DeletionTimestamp != nil {
+			return nil
+		}
+		if (service.Spec.ClusterIP == corev1.ClusterIPNone) != elasticWeb.Spec.Service.IsHeadless() {
+			log.Info("clusterIP changed, delete service to recreate it")
+			if err := r.Delete(ctx, service); err != nil && !errors.IsNotFound(err) {
+				log.Error(err, "delete service error")
+				return err
+			}
+			return nil
+		}
+
+		old := service.Spec.DeepCopy()
+		setServiceSpec(elasticWeb, &service.Spec)
+		if equality.Semantic.DeepEqual(old, &service.Spec) {
+			return nil
 		}
-		SvcPorts = append(SvcPorts, tmp)
+		log.Info("update service")
+		if err := r.Update(ctx, service); err != nil {
+			log.Error(err, "update service error")
+			return err
+		}
+		return nil
 	}
 
 	// 实例化一个数据结构
@@ -256,16 +496,16 @@ func createServiceIfNotExists(ctx context.Context, r *ElasticWebReconciler, elas
 			Name:      elasticWeb.Name,
 		},
 		Spec: corev1.ServiceSpec{
-			Ports: SvcPorts,
-			Type:  corev1.ServiceTypeClusterIP,
-			Selector: map[string]string{
-				"app": elasticWeb.Name,
-			},
+			Selector: getServiceSelector(elasticWeb),
 		},
 	}
+	if elasticWeb.Spec.Service.IsHeadless() {
+		service.Spec.ClusterIP = corev1.ClusterIPNone
+	}
+	setServiceSpec(elasticWeb, &service.Spec)
 
 	// 这一步非常关键
-	// 建立关联后，删除elasticweb资源时，就会将deployment也删除掉
+	// 建立关联后，删除elasticweb资源时，就会将service也删除掉
 	log.Info("set reference")
 	if err := controllerutil.SetControllerReference(elasticWeb, service, r.Scheme); err != nil {
 		log.Error(err, "SetControllerReference error")
@@ -282,6 +522,97 @@ func createServiceIfNotExists(ctx context.Context, r *ElasticWebReconciler, elas
 	return nil
 }
 
+// service端口的appProtocol，没有设置时使用targetport对应的容器端口的appProtocol
+func getAppProtocol(elasticWeb *elasticwebv1.ElasticWeb, svcPort *elasticwebv1.ElasticWebSpecSvcPorts) *string {
+	if svcPort.AppProtocol != "" {
+		return pointer.String(svcPort.AppProtocol)
+	}
+	for i := range elasticWeb.Spec.Deploy {
+		if p := elasticWeb.Spec.Deploy[i].FindPort(svcPort); p != nil && p.AppProtocol != "" {
+			return pointer.String(p.AppProtocol)
+		}
+	}
+	return nil
+}
+
+// 按spec.service设置service，没有设置的字段使用apiserver的默认值，避免每次Reconcile都更新
+func setServiceSpec(elasticWeb *elasticwebv1.ElasticWeb, spec *corev1.ServiceSpec) {
+	svc := &elasticWeb.Spec.Service
+
+	spec.Type = corev1.ServiceType(svc.Type)
+	if spec.Type == "" {
+		spec.Type = corev1.ServiceTypeClusterIP
+	}
+	external := spec.Type == corev1.ServiceTypeNodePort || spec.Type == corev1.ServiceTypeLoadBalancer
+
+	// 实例化service ports，已分配的nodePort保持不变
+	var ports []corev1.ServicePort
+	for i, v := range svc.Ports {
+		port := corev1.ServicePort{
+			Name:        v.Name,
+			Protocol:    v.GetProtocol(),
+			AppProtocol: getAppProtocol(elasticWeb, &svc.Ports[i]),
+			Port:        *v.Port,
+			TargetPort:  *v.TargetPort,
+		}
+		if external {
+			for _, existing := range spec.Ports {
+				if existing.Port == port.Port && existing.Protocol == port.Protocol {
+					port.NodePort = existing.NodePort
+				}
+			}
+		}
+		ports = append(ports, port)
+	}
+	spec.Ports = ports
+
+	spec.SessionAffinity = corev1.ServiceAffinity(svc.SessionAffinity)
+	spec.SessionAffinityConfig = nil
+	if spec.SessionAffinity == corev1.ServiceAffinityClientIP {
+		spec.SessionAffinityConfig = &corev1.SessionAffinityConfig{
+			ClientIP: &corev1.ClientIPConfig{
+				TimeoutSeconds: pointer.Int32(pointer.Int32Deref(svc.SessionAffinityTimeoutSeconds, corev1.DefaultClientIPServiceAffinitySeconds)),
+			},
+		}
+	} else {
+		spec.SessionAffinity = corev1.ServiceAffinityNone
+	}
+
+	spec.ExternalTrafficPolicy = ""
+	if external {
+		spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicy(svc.ExternalTrafficPolicy)
+		if spec.ExternalTrafficPolicy == "" {
+			spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyCluster
+		}
+	}
+
+	internal := corev1.ServiceInternalTrafficPolicy(svc.InternalTrafficPolicy)
+	if internal == "" {
+		internal = corev1.ServiceInternalTrafficPolicyCluster
+	}
+	spec.InternalTrafficPolicy = &internal
+
+	spec.LoadBalancerSourceRanges = nil
+	if spec.Type == corev1.ServiceTypeLoadBalancer {
+		spec.LoadBalancerSourceRanges = svc.LoadBalancerSourceRanges
+	}
+
+	policy := corev1.IPFamilyPolicy(svc.IPFamilyPolicy)
+	if policy == "" {
+		policy = corev1.IPFamilyPolicySingleStack
+	}
+	spec.IPFamilyPolicy = &policy
+	// 从双栈改为单栈时只保留第一个协议族
+	if policy == corev1.IPFamilyPolicySingleStack {
+		if len(spec.IPFamilies) > 1 {
+			spec.IPFamilies = spec.IPFamilies[:1]
+		}
+		if len(spec.ClusterIPs) > 1 {
+			spec.ClusterIPs = spec.ClusterIPs[:1]
+		}
+	}
+}
+
 // 新建deployment
 func createDeployment(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
 
@@ -290,42 +621,6 @@ func createDeployment(ctx context.Context, r *ElasticWebReconciler, elasticWeb *
 
 	log.Info(fmt.Sprintf("expectReplicas [%d]", expectReplicas))
 
-	// 实例化containers
-
-	var containers []corev1.Container
-	for _, cv := range elasticWeb.Spec.Deploy {
-		var tmpPorts []corev1.ContainerPort
-		for _, cv1 := range cv.Ports {
-			tmpPorts = append(tmpPorts, corev1.ContainerPort{
-				Name:          cv1.Name,
-				Protocol:      corev1.ProtocolTCP,
-				ContainerPort: *cv1.Port,
-			})
-
-		}
-		tmp := corev1.Container{
-			Name:            cv.Name,
-			Image:           cv.Image,
-			ImagePullPolicy: "IfNotPresent",
-			Ports:           tmpPorts,
-			Resources: corev1.ResourceRequirements{
-				Requests: corev1.ResourceList{
-					"cpu":    resource.MustParse(CPU_REQUEST),
-					"memory": resource.MustParse(MEM_REQUEST),
-				},
-				Limits: corev1.ResourceList{
-					"cpu":    resource.MustParse(CPU_LIMIT),
-					"memory": resource.MustParse(MEM_LIMIT),
-				},
-			},
-			// ReadinessProbe: &corev1.Probe{
-			// 	InitialDelaySeconds: 10,
-				
-			// },
-		}
-		containers = append(containers, tmp)
-	}
-
 	// 实例化一个数据结构
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -335,19 +630,13 @@ func createDeployment(ctx context.Context, r *ElasticWebReconciler, elasticWeb *
 		Spec: appsv1.DeploymentSpec{
 			Replicas: pointer.Int32Ptr(expectReplicas),
 			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": APP_NAME,
-				},
+				MatchLabels: getPodLabels(elasticWeb),
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": APP_NAME,
-					},
-				},
-				Spec: corev1.PodSpec{
-					Containers: containers,
+					Labels: getPodLabels(elasticWeb),
 				},
+				Spec: getPodSpec(elasticWeb),
 			},
 		},
 	}
@@ -371,25 +660,213 @@ func createDeployment(ctx context.Context, r *ElasticWebReconciler, elasticWeb *
 	return nil
 }
 
-// 完成pod的处理后，更新最新状态
-func updateStatus(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+// deployment的pod模板，校准用的pod也使用它
+func getPodSpec(elasticWeb *elasticwebv1.ElasticWeb) corev1.PodSpec {
+	// 实例化containers
+
+	var containers []corev1.Container
+	for i := range elasticWeb.Spec.Deploy {
+		containers = append(containers, getContainer(elasticWeb, i))
+	}
+
+	return corev1.PodSpec{
+		Containers:                containers,
+		TopologySpreadConstraints: getTopologySpreadConstraints(elasticWeb),
+		Volumes:                   getVolumes(elasticWeb),
+		SecurityContext:           elasticWeb.Spec.SecurityContext,
+		ServiceAccountName:        getServiceAccountName(elasticWeb),
+		ImagePullSecrets:          elasticWeb.Spec.ImagePullSecrets,
+	}
+}
+
+// spec.deploy中第index项对应的容器
+func getContainer(elasticWeb *elasticwebv1.ElasticWeb, index int) corev1.Container {
+	cv := &elasticWeb.Spec.Deploy[index]
+	return corev1.Container{
+		Name:            cv.Name,
+		Image:           getImage(elasticWeb, cv),
+		ImagePullPolicy: getPullPolicy(cv),
+		Ports:           getContainerPorts(cv),
+		VolumeMounts:    cv.VolumeMounts,
+		SecurityContext: cv.SecurityContext,
+		Resources:       getResources(cv),
+		ReadinessProbe:  cv.ReadinessProbe,
+		LivenessProbe:   cv.LivenessProbe,
+	}
+}
+
+// pod使用的ServiceAccount，没有指定时写上apiserver会填充的default，和deployment中的值比较时不会有差异
+func getServiceAccountName(elasticWeb *elasticwebv1.ElasticWeb) string {
+	if name := elasticWeb.GetServiceAccountName(); name != "" {
+		return name
+	}
+	return DEFAULT_SERVICE_ACCOUNT
+}
+
+// 容器的端口，协议默认TCP
+func getContainerPorts(deploy *elasticwebv1.ElasticWebSpecDeploy) []corev1.ContainerPort {
+	var ports []corev1.ContainerPort
+	for _, p := range deploy.Ports {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          p.Name,
+			Protocol:      p.GetProtocol(),
+			ContainerPort: *p.Port,
+		})
+	}
+	return ports
+}
+
+// pod的标签，NameLabel区分同一namespace下不同ElasticWeb的pod
+func getPodLabels(elasticWeb *elasticwebv1.ElasticWeb) map[string]string {
+	return map[string]string{
+		"app":                  APP_NAME,
+		elasticwebv1.NameLabel: elasticWeb.Name,
+	}
+}
+
+// spec.capacityPolicy.zones大于1时，pod均匀分布到各可用区；
+// minDomains保证可用区不够时pod处于pending，而不是挤在少数可用区里，失去一个可用区时承载不了totalQPS
+func getTopologySpreadConstraints(elasticWeb *elasticwebv1.ElasticWeb) []corev1.TopologySpreadConstraint {
+	policy := elasticWeb.Spec.CapacityPolicy
+	if policy == nil || policy.Zones <= 1 {
+		return nil
+	}
+	return []corev1.TopologySpreadConstraint{{
+		MaxSkew:           1,
+		MinDomains:        pointer.Int32(policy.Zones),
+		TopologyKey:       elasticWeb.GetZoneTopologyKey(),
+		WhenUnsatisfiable: corev1.DoNotSchedule,
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: getServiceSelector(elasticWeb),
+		},
+	}}
+}
+
+// service的selector，选中该elasticWeb的pod
+func getServiceSelector(elasticWeb *elasticwebv1.ElasticWeb) map[string]string {
+	return map[string]string{
+		elasticwebv1.NameLabel: elasticWeb.Name,
+	}
+}
+
+// Ready condition：所有deployment的最新版本pod中，available的数量达到期望值时为True，期望0个pod时也是True；
+// 滚动更新过程中旧版本的pod不算，否则改了镜像后Ready会一直是True，直到旧pod被删除
+func getReadyCondition(elasticWeb *elasticwebv1.ElasticWeb, deployments ...*appsv1.Deployment) metav1.Condition {
+	status := &elasticWeb.Status
+	condition := metav1.Condition{
+		Type:               elasticwebv1.ConditionReady,
+		ObservedGeneration: elasticWeb.Generation,
+	}
+	var available int32
+	for _, deployment := range deployments {
+		available += getUpdatedAvailableReplicas(deployment)
+	}
+	switch {
+	case status.DesiredReplicas == 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = elasticwebv1.ReasonScaledToZero
+		condition.Message = "no pods are needed"
+	case available >= status.DesiredReplicas:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = elasticwebv1.ReasonPodsReady
+		condition.Message = fmt.Sprintf("%d/%d updated pods available", available, status.DesiredReplicas)
+	default:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = elasticwebv1.ReasonPodsNotReady
+		condition.Message = fmt.Sprintf("%d/%d updated pods available", available, status.DesiredReplicas)
+	}
+	return condition
+}
+
+// deployment中最新版本且available的pod数量：deployment还没有处理最新的spec时为0；
+// 还有旧版本的pod时，假设旧pod都是available的，得到的是下限
+func getUpdatedAvailableReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment == nil || deployment.Status.ObservedGeneration < deployment.Generation {
+		return 0
+	}
+	status := &deployment.Status
+	available := status.AvailableReplicas - (status.Replicas - status.UpdatedReplicas)
+	return max(0, min(available, status.UpdatedReplicas))
+}
+
+// Paused condition：spec.paused为true时为True
+func getPausedCondition(elasticWeb *elasticwebv1.ElasticWeb) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               elasticwebv1.ConditionPaused,
+		Status:             metav1.ConditionFalse,
+		Reason:             elasticwebv1.ReasonNotPaused,
+		ObservedGeneration: elasticWeb.Generation,
+	}
+	if elasticWeb.Spec.Paused {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = elasticwebv1.ReasonPaused
+		condition.Message = "spec.paused is true, the deployment and service are not reconciled"
+	}
+	return condition
+}
+
+// 完成pod的处理后，更新最新状态，deployment刚创建时为nil；deployMode为Variants时传入所有变体的deployment
+func updateStatus(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb, deployments ...*appsv1.Deployment) error {
+	oldStatus := elasticWeb.Status.DeepCopy()
 
-	// 单个pod的QPS
-	singlePodQPS := *(elasticWeb.Spec.SinglePodQPS)
+	// 单个pod的QPS，没有经过webhook的对象可能没有设置
+	var singlePodQPS int32
+	if elasticWeb.Spec.SinglePodQPS != nil {
+		singlePodQPS = *(elasticWeb.Spec.SinglePodQPS)
+	}
 
 	// pod总数
 
 	replicas := getExpectReplicas(elasticWeb)
 
-	// 当pod创建完毕后，当前系统实际的QPS：单个pod的QPS * pod总数
+	// 期望的pod全部available时能承载的QPS
+	elasticWeb.Status.DesiredQPS = singlePodQPS * replicas
+
+	// 按冗余策略失去若干pod或者一个可用区后仍能承载的QPS
+	elasticWeb.Status.SurvivableQPS = singlePodQPS * elasticWeb.SurvivableReplicas(replicas)
+
+	// scale子资源读取的副本数和pod selector
+	elasticWeb.Status.Replicas = 0
+	elasticWeb.Status.ReadyReplicas = 0
+	elasticWeb.Status.AvailableReplicas = 0
+	for _, deployment := range deployments {
+		if deployment != nil {
+			elasticWeb.Status.Replicas += deployment.Status.Replicas
+			elasticWeb.Status.ReadyReplicas += deployment.Status.ReadyReplicas
+			elasticWeb.Status.AvailableReplicas += deployment.Status.AvailableReplicas
+		}
+	}
+	elasticWeb.Status.Variants = getVariantStatus(elasticWeb, replicas, deployments)
+
+	// 当前系统实际的QPS：单个pod的QPS * available的pod数量
 	// 如果该字段还没有初始化，就先做初始化
 	if nil == elasticWeb.Status.RealQPS {
 		elasticWeb.Status.RealQPS = new(int32)
 	}
-	*(elasticWeb.Status.RealQPS) = singlePodQPS * replicas
-	log.Info(fmt.Sprintf("singlePodQPS [%d],replicas [%d],realQPS [%d]", singlePodQPS, replicas, *(&elasticWeb.Status.RealQPS)))
+	*(elasticWeb.Status.RealQPS) = singlePodQPS * elasticWeb.Status.AvailableReplicas
+	log.Info(fmt.Sprintf("singlePodQPS [%d],replicas [%d],availableReplicas [%d],realQPS [%d]",
+		singlePodQPS, replicas, elasticWeb.Status.AvailableReplicas, *(elasticWeb.Status.RealQPS)))
+	elasticWeb.Status.Selector = labels.SelectorFromSet(getServiceSelector(elasticWeb)).String()
+	elasticWeb.Status.DesiredReplicas = replicas
+	elasticWeb.Status.ObservedGeneration = elasticWeb.Generation
+	meta.SetStatusCondition(&elasticWeb.Status.Conditions, getReadyCondition(elasticWeb, deployments...))
+
+	// 进入和退出暂停状态时各记录一个事件
+	wasPaused := meta.IsStatusConditionTrue(elasticWeb.Status.Conditions, elasticwebv1.ConditionPaused)
+	meta.SetStatusCondition(&elasticWeb.Status.Conditions, getPausedCondition(elasticWeb))
+	if r.Recorder != nil && wasPaused != elasticWeb.Spec.Paused {
+		if elasticWeb.Spec.Paused {
+			r.Recorder.Event(elasticWeb, corev1.EventTypeNormal, "Paused", "reconciliation paused, the deployment and service are no longer updated")
+		} else {
+			r.Recorder.Event(elasticWeb, corev1.EventTypeNormal, "Resumed", "reconciliation resumed")
+		}
+	}
+
+	if equality.Semantic.DeepEqual(oldStatus, &elasticWeb.Status) {
+		return nil
+	}
 
-	if err := r.Update(ctx, elasticWeb); err != nil {
+	if err := r.Status().Update(ctx, elasticWeb); err != nil {
 		log.Error(err, "update instance error")
 		return err
 	}
@@ -397,18 +874,225 @@ func updateStatus(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elas
 	return nil
 }
 
+// deployment中的容器和spec.deploy的名称、顺序是否一致
+func isSameContainers(elasticWeb *elasticwebv1.ElasticWeb, containers []corev1.Container) bool {
+	if len(containers) != len(elasticWeb.Spec.Deploy) {
+		return false
+	}
+	for i := range containers {
+		if containers[i].Name != elasticWeb.Spec.Deploy[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
 func getDiffDeployment(ctx context.Context, elasticWeb *elasticwebv1.ElasticWeb, oldDeployment *appsv1.Deployment) (newDeployment *appsv1.Deployment, needUpdate bool) {
+	needUpdate = false
+
+	// 增加、删除或者改名了容器时，按spec.deploy的顺序重建容器列表，已有的容器保留apiserver填充的默认值，
+	// 后面再逐项比较；变体的deployment只有自己的容器，变体增减时由reconcileVariants创建或删除deployment
+	if !elasticWeb.IsVariantMode() && !isSameContainers(elasticWeb, oldDeployment.Spec.Template.Spec.Containers) {
+		var containers []corev1.Container
+		for i := range elasticWeb.Spec.Deploy {
+			container := getContainer(elasticWeb, i)
+			for _, old := range oldDeployment.Spec.Template.Spec.Containers {
+				if old.Name == container.Name {
+					container = old
+				}
+			}
+			containers = append(containers, container)
+		}
+		oldDeployment.Spec.Template.Spec.Containers = containers
+		log.Info("15. set deployment containers")
+		needUpdate = true
+	}
+
 	// 当前deployment容器信息
 	containers := oldDeployment.Spec.Template.Spec.Containers
-	needUpdate = false
 	for i1, v1 := range containers {
-		for _, v2 := range elasticWeb.Spec.Deploy {
-			if v1.Name == v2.Name && v1.Image != v2.Image {
-				oldDeployment.Spec.Template.Spec.Containers[i1].Image = v2.Image
+		for i2, v2 := range elasticWeb.Spec.Deploy {
+			if image := getImage(elasticWeb, &elasticWeb.Spec.Deploy[i2]); v1.Name == v2.Name && v1.Image != image {
+				oldDeployment.Spec.Template.Spec.Containers[i1].Image = image
 				log.Info("15. set deployment image")
 				needUpdate = true
 			}
+			if pullPolicy := getPullPolicy(&elasticWeb.Spec.Deploy[i2]); v1.Name == v2.Name && v1.ImagePullPolicy != pullPolicy {
+				oldDeployment.Spec.Template.Spec.Containers[i1].ImagePullPolicy = pullPolicy
+				log.Info("15. set deployment imagePullPolicy")
+				needUpdate = true
+			}
+			if ports := getContainerPorts(&elasticWeb.Spec.Deploy[i2]); v1.Name == v2.Name && isDiff(ports, v1.Ports, len(ports) != len(v1.Ports)) {
+				oldDeployment.Spec.Template.Spec.Containers[i1].Ports = ports
+				log.Info("15. set deployment ports")
+				needUpdate = true
+			}
+			if v1.Name == v2.Name && isDiff(v2.VolumeMounts, v1.VolumeMounts, len(v2.VolumeMounts) != len(v1.VolumeMounts)) {
+				oldDeployment.Spec.Template.Spec.Containers[i1].VolumeMounts = v2.VolumeMounts
+				log.Info("15. set deployment volumeMounts")
+				needUpdate = true
+			}
+			if resources := getResources(&elasticWeb.Spec.Deploy[i2]); v1.Name == v2.Name && isDiff(resources, v1.Resources, len(resources.Limits) != len(v1.Resources.Limits)) {
+				oldDeployment.Spec.Template.Spec.Containers[i1].Resources = resources
+				log.Info("15. set deployment resources")
+				needUpdate = true
+			}
+			if v1.Name == v2.Name && isDiff(v2.ReadinessProbe, v1.ReadinessProbe, (v2.ReadinessProbe == nil) != (v1.ReadinessProbe == nil)) {
+				oldDeployment.Spec.Template.Spec.Containers[i1].ReadinessProbe = v2.ReadinessProbe
+				log.Info("15. set deployment readinessProbe")
+				needUpdate = true
+			}
+			if v1.Name == v2.Name && isDiff(v2.LivenessProbe, v1.LivenessProbe, (v2.LivenessProbe == nil) != (v1.LivenessProbe == nil)) {
+				oldDeployment.Spec.Template.Spec.Containers[i1].LivenessProbe = v2.LivenessProbe
+				log.Info("15. set deployment livenessProbe")
+				needUpdate = true
+			}
+			if v1.Name == v2.Name && v2.SecurityContext != nil && isDiff(v2.SecurityContext, v1.SecurityContext, v1.SecurityContext == nil) {
+				oldDeployment.Spec.Template.Spec.Containers[i1].SecurityContext = v2.SecurityContext
+				log.Info("15. set deployment container securityContext")
+				needUpdate = true
+			}
+		}
+	}
+
+	// 早期创建的deployment的pod没有NameLabel，补上后status.selector才能选中它们
+	if oldDeployment.Spec.Template.Labels[elasticwebv1.NameLabel] != elasticWeb.Name {
+		if oldDeployment.Spec.Template.Labels == nil {
+			oldDeployment.Spec.Template.Labels = map[string]string{}
 		}
+		oldDeployment.Spec.Template.Labels[elasticwebv1.NameLabel] = elasticWeb.Name
+		log.Info("15. set deployment pod labels")
+		needUpdate = true
+	}
+
+	// 存储卷
+	volumes := getVolumes(elasticWeb)
+	if isDiff(volumes, oldDeployment.Spec.Template.Spec.Volumes, len(volumes) != len(oldDeployment.Spec.Template.Spec.Volumes)) {
+		oldDeployment.Spec.Template.Spec.Volumes = volumes
+		log.Info("15. set deployment volumes")
+		needUpdate = true
+	}
+
+	// pod安全配置，apiserver会把nil填充成空结构体，所以只在期望值非空时比较
+	podSecurityContext := elasticWeb.Spec.SecurityContext
+	if podSecurityContext != nil && isDiff(podSecurityContext, oldDeployment.Spec.Template.Spec.SecurityContext, oldDeployment.Spec.Template.Spec.SecurityContext == nil) {
+		oldDeployment.Spec.Template.Spec.SecurityContext = podSecurityContext
+		log.Info("15. set deployment pod securityContext")
+		needUpdate = true
+	}
+
+	// 可用区分布
+	podSpec := &oldDeployment.Spec.Template.Spec
+	topologySpreadConstraints := getTopologySpreadConstraints(elasticWeb)
+	if isDiff(topologySpreadConstraints, podSpec.TopologySpreadConstraints, len(topologySpreadConstraints) != len(podSpec.TopologySpreadConstraints)) {
+		podSpec.TopologySpreadConstraints = topologySpreadConstraints
+		log.Info("15. set deployment topologySpreadConstraints")
+		needUpdate = true
+	}
+
+	// ServiceAccount和镜像拉取secret，去掉serviceAccountName后也能改回default
+	if serviceAccountName := getServiceAccountName(elasticWeb); serviceAccountName != podSpec.ServiceAccountName {
+		podSpec.ServiceAccountName = serviceAccountName
+		log.Info("15. set deployment serviceAccountName")
+		needUpdate = true
+	}
+	if isDiff(elasticWeb.Spec.ImagePullSecrets, podSpec.ImagePullSecrets, len(elasticWeb.Spec.ImagePullSecrets) != len(podSpec.ImagePullSecrets)) {
+		podSpec.ImagePullSecrets = elasticWeb.Spec.ImagePullSecrets
+		log.Info("15. set deployment imagePullSecrets")
+		needUpdate = true
 	}
 	return oldDeployment, needUpdate
 }
+
+// 容器的资源配置，spec中没有设置时使用内置的默认值
+func getResources(deploy *elasticwebv1.ElasticWebSpecDeploy) corev1.ResourceRequirements {
+	if deploy.Resources != nil {
+		return *deploy.Resources
+	}
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			"cpu":    resource.MustParse(CPU_REQUEST),
+			"memory": resource.MustParse(MEM_REQUEST),
+		},
+		Limits: corev1.ResourceList{
+			"cpu":    resource.MustParse(CPU_LIMIT),
+			"memory": resource.MustParse(MEM_LIMIT),
+		},
+	}
+}
+
+// 判断期望值和集群中的实际值是否不同，apiserver填充的默认值不算差异；
+// DeepDerivative会把空slice当作"未设置"，所以长度变化需要调用方单独传进来
+func isDiff(desired, actual interface{}, lenChanged bool) bool {
+	return lenChanged || !equality.Semantic.DeepDerivative(desired, actual)
+}
+
+// 把spec.volumes转换成pod的volumes，claimTemplate类型的volume指向controller创建的PVC
+func getVolumes(elasticWeb *elasticwebv1.ElasticWeb) []corev1.Volume {
+	var volumes []corev1.Volume
+	for i := range elasticWeb.Spec.Volumes {
+		v := &elasticWeb.Spec.Volumes[i]
+		volume := corev1.Volume{Name: v.Name}
+		switch {
+		case v.EmptyDir != nil:
+			volume.EmptyDir = v.EmptyDir
+		case v.ConfigMap != nil:
+			volume.ConfigMap = v.ConfigMap
+		case v.Secret != nil:
+			volume.Secret = v.Secret
+		case v.Projected != nil:
+			volume.Projected = v.Projected
+		case v.PersistentVolumeClaim != nil:
+			volume.PersistentVolumeClaim = v.PersistentVolumeClaim
+		case v.ClaimTemplate != nil:
+			volume.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: elasticWeb.ClaimName(v),
+			}
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes
+}
+
+// 为spec.volumes中每个claimTemplate创建PVC，已存在的PVC不做修改（PVC的大部分字段创建后不可变）
+func createPVCsIfNotExists(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	for i := range elasticWeb.Spec.Volumes {
+		v := &elasticWeb.Spec.Volumes[i]
+		if v.ClaimTemplate == nil {
+			continue
+		}
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: elasticWeb.Namespace, Name: elasticWeb.ClaimName(v)}, pvc)
+		if err == nil {
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			log.Error(err, "query pvc error")
+			return err
+		}
+
+		pvc = &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   elasticWeb.Namespace,
+				Name:        elasticWeb.ClaimName(v),
+				Labels:      v.ClaimTemplate.Labels,
+				Annotations: v.ClaimTemplate.Annotations,
+			},
+			Spec: *v.ClaimTemplate.Spec.DeepCopy(),
+		}
+
+		// 和service、deployment一样，elasticweb删除时PVC也随之删除
+		if err := controllerutil.SetControllerReference(elasticWeb, pvc, r.Scheme); err != nil {
+			log.Error(err, "SetControllerReference error")
+			return err
+		}
+
+		log.Info("start create pvc " + pvc.Name)
+		if err := r.Create(ctx, pvc); err != nil {
+			log.Error(err, "create pvc error")
+			return err
+		}
+	}
+	return nil
+}
@@ -18,18 +18,67 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	elasticwebv1 "elasticweb/api/v1"
+	"elasticweb/internal/activator"
 )
 
+// newTestElasticWeb returns an ElasticWeb in the default namespace running one tomcat
+// container behind a ClusterIP Service at totalQPS 1000, mutate sets the fields a test is about.
+func newTestElasticWeb(name string, mutate func(*elasticwebv1.ElasticWeb)) *elasticwebv1.ElasticWeb {
+	elasticWeb := &elasticwebv1.ElasticWeb{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: elasticwebv1.ElasticWebSpec{
+			SinglePodQPS: ptr.To[int32](500),
+			TotalQPS:     ptr.To[int32](1000),
+			Deploy: []elasticwebv1.ElasticWebSpecDeploy{{
+				Name:  "tomcat",
+				Image: "tomcat:8.0.18-jre8",
+				Ports: []elasticwebv1.ElasticWebSpecDeployPorts{{Name: "http", Port: ptr.To[int32](8080)}},
+			}},
+			Service: elasticwebv1.ElasticWebSpecSvc{
+				Type: "ClusterIP",
+				Ports: []elasticwebv1.ElasticWebSpecSvcPorts{{
+					Name: "http", Port: ptr.To[int32](8080), TargetPort: ptr.To(intstr.FromInt32(8080)),
+				}},
+			},
+		},
+	}
+	if mutate != nil {
+		mutate(elasticWeb)
+	}
+	return elasticWeb
+}
+
+// createTestElasticWeb creates newTestElasticWeb(name, mutate) and deletes it when the spec ends.
+func createTestElasticWeb(ctx context.Context, name string, mutate func(*elasticwebv1.ElasticWeb)) *elasticwebv1.ElasticWeb {
+	elasticWeb := newTestElasticWeb(name, mutate)
+	Expect(k8sClient.Create(ctx, elasticWeb)).To(Succeed())
+	DeferCleanup(func() {
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, elasticWeb))).To(Succeed())
+	})
+	return elasticWeb
+}
+
 var _ = Describe("ElasticWeb Controller", func() {
 	Context("When reconciling a resource", func() {
 		const resourceName = "test-resource"
@@ -51,7 +100,21 @@ var _ = Describe("ElasticWeb Controller", func() {
 						Name:      resourceName,
 						Namespace: "default",
 					},
-					// TODO(user): Specify other spec details if needed.
+					Spec: elasticwebv1.ElasticWebSpec{
+						SinglePodQPS: ptr.To[int32](500),
+						TotalQPS:     ptr.To[int32](0),
+						Deploy: []elasticwebv1.ElasticWebSpecDeploy{{
+							Name:  "tomcat",
+							Image: "tomcat:8.0.18-jre8",
+							Ports: []elasticwebv1.ElasticWebSpecDeployPorts{{Name: "http", Port: ptr.To[int32](8080)}},
+						}},
+						Service: elasticwebv1.ElasticWebSpecSvc{
+							Type: "ClusterIP",
+							Ports: []elasticwebv1.ElasticWebSpecSvcPorts{{
+								Name: "http", Port: ptr.To[int32](8080), TargetPort: ptr.To(intstr.FromInt32(8080)),
+							}},
+						},
+					},
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 			}
@@ -77,8 +140,891 @@ var _ = Describe("ElasticWeb Controller", func() {
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+
+			By("Checking the status reports the scaled-to-zero ElasticWeb as ready")
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.DesiredReplicas).To(BeZero())
+			Expect(resource.Status.Selector).To(Equal(elasticwebv1.NameLabel + "=" + resourceName))
+			condition := meta.FindStatusCondition(resource.Status.Conditions, elasticwebv1.ConditionReady)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal(elasticwebv1.ReasonScaledToZero))
+		})
+	})
+
+	Context("When reconciling a paused resource", func() {
+		const resourceName = "paused-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			createTestElasticWeb(ctx, resourceName, func(ew *elasticwebv1.ElasticWeb) {
+				ew.Spec.Paused = true
+			})
+		})
+
+		It("should only update the status and record an event", func() {
+			recorder := record.NewFakeRecorder(10)
+			controllerReconciler := &ElasticWebReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking no deployment was created")
+			err = k8sClient.Get(ctx, typeNamespacedName, &appsv1.Deployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("Checking the Paused condition and event")
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, elasticwebv1.ConditionPaused)).To(BeTrue())
+			Expect(recorder.Events).To(Receive(ContainSubstring("Paused")))
+		})
+	})
+
+	Context("When rolling out a new version", func() {
+		const resourceName = "rollout-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			createTestElasticWeb(ctx, resourceName, nil)
+		})
+
+		It("should not report ready until the new pods are available", func() {
+			controllerReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Pretending both pods of the first version are available")
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			deployment.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: deployment.Generation,
+				Replicas:           2,
+				UpdatedReplicas:    2,
+				ReadyReplicas:      2,
+				AvailableReplicas:  2,
+			}
+			Expect(k8sClient.Status().Update(ctx, deployment)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, elasticwebv1.ConditionReady)).To(BeTrue())
+
+			By("Changing the image")
+			resource.Spec.Deploy[0].Image = "tomcat:9"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, elasticwebv1.ConditionReady)).To(BeFalse())
+
+			By("Pretending one new pod started while both old pods still serve")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			deployment.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: deployment.Generation,
+				Replicas:           3,
+				UpdatedReplicas:    1,
+				ReadyReplicas:      3,
+				AvailableReplicas:  3,
+			}
+			Expect(k8sClient.Status().Update(ctx, deployment)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			condition := meta.FindStatusCondition(resource.Status.Conditions, elasticwebv1.ConditionReady)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).To(Equal("1/2 updated pods available"))
+		})
+	})
+
+	Context("When reconciling an idle resource", func() {
+		const resourceName = "idle-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			resource := createTestElasticWeb(ctx, resourceName, func(ew *elasticwebv1.ElasticWeb) {
+				ew.Spec.Idle = &elasticwebv1.ElasticWebSpecIdle{After: metav1.Duration{Duration: time.Minute}}
+			})
+
+			By("Pretending the last request was seen two minutes ago")
+			resource.Status.LastRequestTime = &metav1.Time{Time: time.Now().Add(-2 * time.Minute)}
+			Expect(k8sClient.Status().Update(ctx, resource)).To(Succeed())
+		})
+
+		It("should scale to zero without traffic and scale up when woken", func() {
+			recorder := record.NewFakeRecorder(10)
+			controllerReconciler := &ElasticWebReconciler{
+				Client:           k8sClient,
+				Scheme:           k8sClient.Scheme(),
+				Recorder:         recorder,
+				TrafficSource:    idleTraffic{},
+				ActivatorAddress: "10.0.0.1",
+				ActivatorPort:    8090,
+			}
+			controllerReconciler.Activator = activator.New(controllerReconciler, controllerReconciler)
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the resource went idle without a deployment")
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, elasticwebv1.ConditionIdle)).To(BeTrue())
+			Expect(resource.Status.DesiredReplicas).To(BeZero())
+			err = k8sClient.Get(ctx, typeNamespacedName, &appsv1.Deployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(recorder.Events).To(Receive(ContainSubstring("Idle")))
+
+			By("Waking it through the activator")
+			Expect(controllerReconciler.Wake(ctx, typeNamespacedName)).To(Succeed())
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the realized QPS waits for available pods")
+			Expect(result.RequeueAfter).To(Equal(CONVERGE_CHECK_INTERVAL))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			idle := meta.FindStatusCondition(resource.Status.Conditions, elasticwebv1.ConditionIdle)
+			Expect(idle).NotTo(BeNil())
+			Expect(idle.Reason).To(Equal(elasticwebv1.ReasonWoken))
+			Expect(resource.Status.DesiredQPS).To(Equal(int32(1000)))
+			Expect(resource.Status.RealQPS).To(Equal(ptr.To[int32](0)))
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(2)))
+			Expect(recorder.Events).To(Receive(ContainSubstring("Woken")))
+		})
+
+		It("should wait a full idle period after idle is turned on", func() {
+			By("Pretending no request was ever seen")
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Status.LastRequestTime = nil
+			Expect(k8sClient.Status().Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &ElasticWebReconciler{
+				Client:           k8sClient,
+				Scheme:           k8sClient.Scheme(),
+				TrafficSource:    idleTraffic{},
+				ActivatorAddress: "10.0.0.1",
+				ActivatorPort:    8090,
+			}
+			controllerReconciler.Activator = activator.New(controllerReconciler, controllerReconciler)
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.IsIdle()).To(BeFalse())
+			Expect(resource.Status.LastRequestTime).NotTo(BeNil())
+			Expect(resource.Status.DesiredReplicas).To(Equal(int32(2)))
+		})
+	})
+
+	Context("When reconciling variants", func() {
+		const resourceName = "variant-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			createTestElasticWeb(ctx, resourceName, func(ew *elasticwebv1.ElasticWeb) {
+				ew.Spec.TotalQPS = ptr.To[int32](5000)
+				ew.Spec.DeployMode = elasticwebv1.DeployModeVariants
+				ew.Spec.Deploy[0].Name = "stable"
+				ew.Spec.Deploy[0].Weight = ptr.To[int32](90)
+				canary := *ew.Spec.Deploy[0].DeepCopy()
+				canary.Name = "canary"
+				canary.Image = "tomcat:9.0"
+				canary.Weight = ptr.To[int32](10)
+				ew.Spec.Deploy = append(ew.Spec.Deploy, canary)
+			})
+		})
+
+		It("should give every variant its own deployment sized by weight", func() {
+			controllerReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the capacity is split by weight")
+			for name, replicas := range map[string]int32{"stable": 9, "canary": 1} {
+				deployment := &appsv1.Deployment{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-" + name, Namespace: "default"}, deployment)).To(Succeed())
+				Expect(*deployment.Spec.Replicas).To(Equal(replicas))
+				Expect(deployment.Spec.Template.Spec.Containers).To(HaveLen(1))
+				Expect(deployment.Spec.Template.Labels).To(HaveKeyWithValue(elasticwebv1.VariantLabel, name))
+			}
+			err = k8sClient.Get(ctx, typeNamespacedName, &appsv1.Deployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("Checking the single service selects the pods of every variant")
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, service)).To(Succeed())
+			Expect(service.Spec.Selector).To(Equal(map[string]string{elasticwebv1.NameLabel: resourceName}))
+
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.DesiredReplicas).To(Equal(int32(10)))
+			Expect(resource.Status.Variants).To(HaveLen(2))
+		})
+
+		It("should leave alone a deployment with its name that it does not own", func() {
+			labels := map[string]string{"app": "unrelated"}
+			unowned := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "nginx"}}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, unowned)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, unowned)
+
+			controllerReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			Expect(deployment.UID).To(Equal(unowned.UID))
+			Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx"))
+		})
+	})
+
+	Context("When calibrating singlePodQPS", func() {
+		const resourceName = "calibrated-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+		calibrationName := types.NamespacedName{
+			Name:      resourceName + CALIBRATION_SUFFIX,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			createTestElasticWeb(ctx, resourceName, func(ew *elasticwebv1.ElasticWeb) {
+				ew.Spec.Calibration = &elasticwebv1.ElasticWebSpecCalibration{Path: "/ping", MaxQPS: 400}
+			})
+		})
+
+		It("should load test an isolated pod with a Job", func() {
+			controllerReconciler := &ElasticWebReconciler{
+				Client:           k8sClient,
+				Scheme:           k8sClient.Scheme(),
+				CalibrationImage: "controller:latest",
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the calibration pod is not selected by the service")
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.Calibration).NotTo(BeNil())
+			Expect(resource.Status.Calibration.Phase).To(Equal(elasticwebv1.CalibrationRunning))
+			pod := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, calibrationName, pod)).To(Succeed())
+			Expect(pod.Labels).NotTo(HaveKey(elasticwebv1.NameLabel))
+			Expect(pod.Labels).To(HaveKeyWithValue(elasticwebv1.CalibrationLabel, resourceName))
+
+			By("Pretending the calibration pod became ready")
+			pod.Status.PodIP = "10.0.0.5"
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the load generator targets the pod")
+			job := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, calibrationName, job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("controller:latest"))
+			Expect(job.Spec.Template.Spec.Containers[0].Args).To(ContainElements(
+				"--url=http://10.0.0.5:8080/ping", "--max-qps=400", "--step-qps=20"))
+		})
+	})
+
+	Context("When changing the containers", func() {
+		const resourceName = "containers-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			createTestElasticWeb(ctx, resourceName, nil)
+		})
+
+		It("should add, remove and rename containers in the deployment", func() {
+			controllerReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Adding a sidecar")
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Deploy = append(resource.Spec.Deploy, elasticwebv1.ElasticWebSpecDeploy{
+				Name:  "log-agent",
+				Image: "fluent-bit:3",
+			})
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			containers := deployment.Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(2))
+			Expect(containers[1].Name).To(Equal("log-agent"))
+			Expect(containers[1].Image).To(Equal("fluent-bit:3"))
+
+			By("Renaming the first container and removing the sidecar")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Deploy = resource.Spec.Deploy[:1]
+			resource.Spec.Deploy[0].Name = "web"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			containers = deployment.Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(1))
+			Expect(containers[0].Name).To(Equal("web"))
+			Expect(containers[0].Image).To(Equal(resource.Spec.Deploy[0].Image))
+		})
+	})
+
+	Context("When creating a ServiceAccount", func() {
+		const resourceName = "serviceaccount-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			createTestElasticWeb(ctx, resourceName, func(ew *elasticwebv1.ElasticWeb) {
+				ew.Spec.ServiceAccount = &elasticwebv1.ElasticWebSpecServiceAccount{
+					Create: true,
+					Annotations: map[string]string{
+						"iam.gke.io/gcp-service-account": "web@project.iam.gserviceaccount.com",
+						"example.com/team":               "web",
+					},
+				}
+			})
+		})
+
+		It("should sync the annotations from the spec and keep the ones added by others", func() {
+			controllerReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			sa := &corev1.ServiceAccount{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, sa)).To(Succeed())
+			Expect(sa.Annotations).To(HaveKeyWithValue("iam.gke.io/gcp-service-account", "web@project.iam.gserviceaccount.com"))
+			Expect(sa.Annotations).To(HaveKeyWithValue("example.com/team", "web"))
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.ServiceAccountName).To(Equal(resourceName))
+
+			By("Changing and dropping annotations in the spec")
+			sa.Annotations["example.com/owner"] = "someone"
+			Expect(k8sClient.Update(ctx, sa)).To(Succeed())
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.ServiceAccount.Annotations = map[string]string{
+				"iam.gke.io/gcp-service-account": "api@project.iam.gserviceaccount.com",
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, sa)).To(Succeed())
+			Expect(sa.Annotations).To(HaveKeyWithValue("iam.gke.io/gcp-service-account", "api@project.iam.gserviceaccount.com"))
+			Expect(sa.Annotations).NotTo(HaveKey("example.com/team"))
+			Expect(sa.Annotations).To(HaveKeyWithValue("example.com/owner", "someone"))
+
+			By("Removing all annotations from the spec")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.ServiceAccount.Annotations = nil
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, sa)).To(Succeed())
+			Expect(sa.Annotations).To(Equal(map[string]string{"example.com/owner": "someone"}))
+
+			By("Going back to the default ServiceAccount")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.ServiceAccount = nil
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.ServiceAccountName).To(Equal(DEFAULT_SERVICE_ACCOUNT))
+		})
+	})
+
+	Context("When configuring the service", func() {
+		const resourceName = "service-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			createTestElasticWeb(ctx, resourceName, func(ew *elasticwebv1.ElasticWeb) {
+				ew.Spec.Service.Type = "NodePort"
+				ew.Spec.Service.SessionAffinity = "ClientIP"
+				ew.Spec.Service.SessionAffinityTimeoutSeconds = ptr.To[int32](600)
+				ew.Spec.Service.ExternalTrafficPolicy = "Local"
+			})
+		})
+
+		It("should reconcile the service options and keep the node port", func() {
+			controllerReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, service)).To(Succeed())
+			Expect(service.Spec.Type).To(Equal(corev1.ServiceTypeNodePort))
+			Expect(service.Spec.SessionAffinity).To(Equal(corev1.ServiceAffinityClientIP))
+			Expect(*service.Spec.SessionAffinityConfig.ClientIP.TimeoutSeconds).To(Equal(int32(600)))
+			Expect(service.Spec.ExternalTrafficPolicy).To(Equal(corev1.ServiceExternalTrafficPolicyLocal))
+			nodePort := service.Spec.Ports[0].NodePort
+
+			By("Changing the options of an existing service")
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Service.SessionAffinity = ""
+			resource.Spec.Service.SessionAffinityTimeoutSeconds = nil
+			resource.Spec.Service.ExternalTrafficPolicy = ""
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, service)).To(Succeed())
+			Expect(service.Spec.SessionAffinity).To(Equal(corev1.ServiceAffinityNone))
+			Expect(service.Spec.ExternalTrafficPolicy).To(Equal(corev1.ServiceExternalTrafficPolicyCluster))
+			Expect(service.Spec.Ports[0].NodePort).To(Equal(nodePort))
+		})
+
+		It("should pass protocols and named targetports through to the pods and the service", func() {
+			controllerReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Changing the ports of an existing deployment and service")
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Deploy[0].Ports = []elasticwebv1.ElasticWebSpecDeployPorts{
+				{Name: "http", Port: ptr.To[int32](8080), AppProtocol: "kubernetes.io/h2c"},
+				{Name: "dns", Port: ptr.To[int32](53), Protocol: "UDP"},
+			}
+			resource.Spec.Service.Ports = []elasticwebv1.ElasticWebSpecSvcPorts{
+				{Name: "http", Port: ptr.To[int32](8080), TargetPort: ptr.To(intstr.FromString("http"))},
+				{Name: "dns", Port: ptr.To[int32](53), TargetPort: ptr.To(intstr.FromString("dns")), Protocol: "UDP"},
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Ports).To(HaveLen(2))
+			Expect(deployment.Spec.Template.Spec.Containers[0].Ports[1].Protocol).To(Equal(corev1.ProtocolUDP))
+
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, service)).To(Succeed())
+			Expect(service.Spec.Ports[0].TargetPort).To(Equal(intstr.FromString("http")))
+			Expect(service.Spec.Ports[0].AppProtocol).To(Equal(ptr.To("kubernetes.io/h2c")))
+			Expect(service.Spec.Ports[1].Protocol).To(Equal(corev1.ProtocolUDP))
+			Expect(service.Spec.Ports[1].AppProtocol).To(BeNil())
+		})
+	})
+
+	Context("When exposing through a Gateway without the Gateway API installed", func() {
+		const resourceName = "routed-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			createTestElasticWeb(ctx, resourceName, func(ew *elasticwebv1.ElasticWeb) {
+				ew.Spec.Route = &elasticwebv1.ElasticWebSpecRoute{
+					ParentRefs: []elasticwebv1.ElasticWebSpecRouteParentRef{{Name: "gateway"}},
+				}
+			})
+		})
+
+		It("should keep serving through the Service and report the missing API", func() {
+			controllerReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &corev1.Service{})).To(Succeed())
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			condition := meta.FindStatusCondition(resource.Status.Conditions, elasticwebv1.ConditionRouteAccepted)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(elasticwebv1.ReasonGatewayAPIUnavailable))
+		})
+	})
+
+	Context("When restricting ingress with a NetworkPolicy", func() {
+		const resourceName = "isolated-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			createTestElasticWeb(ctx, resourceName, func(ew *elasticwebv1.ElasticWeb) {
+				ew.Spec.Service.Ports[0].Port = ptr.To[int32](80)
+				ew.Spec.Service.Ports[0].TargetPort = ptr.To(intstr.FromString("http"))
+				ew.Spec.NetworkPolicy = &elasticwebv1.ElasticWebSpecNetworkPolicy{
+					From: []elasticwebv1.ElasticWebSpecNetworkPolicyPeer{{Namespaces: []string{"frontend"}}},
+				}
+			})
+		})
+
+		It("should admit only the listed peers and the ingress controller on the target ports", func() {
+			controllerReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				IngressControllerPeer: &networkingv1.NetworkPolicyPeer{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: "ingress-nginx"}},
+				},
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			policy := &networkingv1.NetworkPolicy{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, policy)).To(Succeed())
+			Expect(policy.Spec.PodSelector.MatchLabels).To(HaveKeyWithValue(elasticwebv1.NameLabel, resourceName))
+			Expect(policy.Spec.PolicyTypes).To(Equal([]networkingv1.PolicyType{networkingv1.PolicyTypeIngress}))
+			Expect(policy.Spec.Ingress).To(HaveLen(1))
+			Expect(policy.Spec.Ingress[0].From).To(HaveLen(2))
+			Expect(policy.Spec.Ingress[0].From[0].NamespaceSelector.MatchExpressions[0].Values).To(Equal([]string{"frontend"}))
+			Expect(*policy.Spec.Ingress[0].Ports[0].Port).To(Equal(intstr.FromString("http")))
+
+			By("Removing the network policy from the spec")
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.NetworkPolicy = nil
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, policy))).To(BeTrue())
+		})
+	})
+
+	Context("When placing an ElasticWeb on a member cluster", func() {
+		const resourceName = "placed-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+		secretName := types.NamespacedName{Name: "member", Namespace: "default"}
+
+		var memberEnv *envtest.Environment
+		var memberClient client.Client
+
+		BeforeEach(func() {
+			By("starting a second API server as the member cluster")
+			memberEnv = newTestEnv()
+			memberCfg, err := memberEnv.Start()
+			Expect(err).NotTo(HaveOccurred())
+			memberClient, err = client.New(memberCfg, client.Options{Scheme: k8sClient.Scheme()})
+			Expect(err).NotTo(HaveOccurred())
+			user, err := memberEnv.AddUser(envtest.User{Name: "hub", Groups: []string{"system:masters"}}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			kubeconfig, err := user.KubeConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretName.Name, Namespace: secretName.Namespace},
+				Data:       map[string][]byte{KUBECONFIG_KEY: kubeconfig},
+			})).To(Succeed())
+
+			createTestElasticWeb(ctx, resourceName, func(ew *elasticwebv1.ElasticWeb) {
+				ew.Spec.TotalQPS = ptr.To[int32](3000)
+				ew.Spec.Placement = &elasticwebv1.ElasticWebSpecPlacement{
+					Clusters: []elasticwebv1.ElasticWebSpecPlacementCluster{
+						{Name: "member", Share: ptr.To[int32](2)},
+						{Name: "missing"},
+					},
+				}
+			})
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretName.Name, Namespace: secretName.Namespace},
+			})).To(Succeed())
+			Expect(memberEnv.Stop()).To(Succeed())
+		})
+
+		It("should propagate a share of totalQPS and clean up when deleted", func() {
+			controllerReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				MemberClusters: &MemberClusters{
+					Reader:    k8sClient,
+					Namespace: "default",
+					Scheme:    k8sClient.Scheme(),
+				},
+			}
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(PLACEMENT_SYNC_PERIOD))
+
+			By("Checking nothing runs on the hub")
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, &appsv1.Deployment{}))).To(BeTrue())
+
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Finalizers).To(ContainElement(elasticwebv1.PlacementFinalizer))
+
+			member := &elasticwebv1.ElasticWeb{}
+			Expect(memberClient.Get(ctx, typeNamespacedName, member)).To(Succeed())
+			Expect(member.Labels).To(HaveKeyWithValue(elasticwebv1.PlacementLabel, string(resource.UID)))
+			Expect(member.Spec.Placement).To(BeNil())
+			Expect(*member.Spec.TotalQPS).To(Equal(int32(2000)))
+
+			Expect(resource.Status.Clusters).To(HaveLen(2))
+			Expect(resource.Status.Clusters[0].TotalQPS).To(Equal(int32(2000)))
+			Expect(resource.Status.Clusters[1].Message).To(ContainSubstring("not found"))
+			condition := meta.FindStatusCondition(resource.Status.Conditions, elasticwebv1.ConditionPlaced)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Reason).To(Equal(elasticwebv1.ReasonPlacementFailed))
+
+			By("Aggregating the status of the member cluster")
+			member.Status.RealQPS = ptr.To[int32](1500)
+			member.Status.AvailableReplicas = 3
+			member.Status.ObservedGeneration = member.Generation
+			meta.SetStatusCondition(&member.Status.Conditions, metav1.Condition{
+				Type: elasticwebv1.ConditionReady, Status: metav1.ConditionTrue, Reason: elasticwebv1.ReasonPodsReady,
+			})
+			Expect(memberClient.Status().Update(ctx, member)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(*resource.Status.RealQPS).To(Equal(int32(1500)))
+			Expect(resource.Status.AvailableReplicas).To(Equal(int32(3)))
+			Expect(resource.Status.Clusters[0].Ready).To(BeTrue())
+
+			By("Deleting the hub ElasticWeb")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(memberClient.Get(ctx, typeNamespacedName, member))).To(BeTrue())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, resource))).To(BeTrue())
+		})
+
+		It("should not leave a deletion hanging after hub mode is turned off", func() {
+			hubReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				MemberClusters: &MemberClusters{
+					Reader:    k8sClient,
+					Namespace: "default",
+					Scheme:    k8sClient.Scheme(),
+				},
+			}
+			_, err := hubReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Deleting the hub ElasticWeb after a restart without hub mode")
+			controllerReconciler := &ElasticWebReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			resource := &elasticwebv1.ElasticWeb{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).To(MatchError(ContainSubstring(elasticwebv1.OrphanPlacementAnnotation)))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+
+			By("Orphaning the member ElasticWebs")
+			resource.Annotations = map[string]string{elasticwebv1.OrphanPlacementAnnotation: "true"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, resource))).To(BeTrue())
+			Expect(memberClient.Get(ctx, typeNamespacedName, &elasticwebv1.ElasticWeb{})).To(Succeed())
 		})
 	})
 })
+
+// idleTraffic reports no requests for every ElasticWeb.
+type idleTraffic struct{}
+
+func (idleTraffic) RequestRate(context.Context, types.NamespacedName) (float64, error) {
+	return 0, nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	elasticwebv1 "elasticweb/api/v1"
+)
+
+// ElasticWebQuotaReconciler reconciles a ElasticWebQuota object
+type ElasticWebQuotaReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=elasticweb.com.bolingcavalry,resources=elasticwebquotas,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=elasticweb.com.bolingcavalry,resources=elasticwebquotas/status,verbs=get;update;patch
+
+// Reconcile 统计quota所在namespace中所有ElasticWeb的用量，写入quota的status。
+// 超额的拦截由webhook负责，这里只负责把用量反馈给用户。
+func (r *ElasticWebQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	quota := &elasticwebv1.ElasticWebQuota{}
+	if err := r.Get(ctx, req.NamespacedName, quota); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "query elasticwebquota error")
+		return ctrl.Result{}, err
+	}
+
+	list := &elasticwebv1.ElasticWebList{}
+	if err := r.List(ctx, list, client.InNamespace(req.Namespace)); err != nil {
+		log.Error(err, "list elasticweb error")
+		return ctrl.Result{}, err
+	}
+
+	usage := elasticwebv1.ElasticWebQuotaStatus{}
+	for i := range list.Items {
+		if !list.Items[i].DeletionTimestamp.IsZero() {
+			continue
+		}
+		totalQPS, replicas := list.Items[i].QuotaUsage()
+		usage.UsedTotalQPS += totalQPS
+		usage.UsedReplicas += replicas
+		usage.ElasticWebs++
+	}
+
+	if usage == quota.Status {
+		return ctrl.Result{}, nil
+	}
+
+	log.Info(fmt.Sprintf("quota [%s] usage: totalQPS [%d], replicas [%d]", req.NamespacedName, usage.UsedTotalQPS, usage.UsedReplicas))
+	quota.Status = usage
+	if err := r.Status().Update(ctx, quota); err != nil {
+		log.Error(err, "update elasticwebquota status error")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// ElasticWeb的任何变化都会触发同一namespace中所有quota重新统计。
+func (r *ElasticWebQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&elasticwebv1.ElasticWebQuota{}).
+		Watches(&elasticwebv1.ElasticWeb{}, handler.EnqueueRequestsFromMapFunc(r.quotasInNamespace)).
+		Named("elasticwebquota").
+		Complete(r)
+}
+
+func (r *ElasticWebQuotaReconciler) quotasInNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := &elasticwebv1.ElasticWebQuotaList{}
+	if err := r.List(ctx, list, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.Error(err, "list elasticwebquota error")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, quota := range list.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: quota.Namespace, Name: quota.Name},
+		})
+	}
+	return requests
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	elasticwebv1 "elasticweb/api/v1"
+)
+
+var _ = Describe("ElasticWebQuota Controller", func() {
+	Context("When reconciling a resource", func() {
+		ctx := context.Background()
+
+		quotaName := types.NamespacedName{Name: "test-quota", Namespace: "default"}
+
+		BeforeEach(func() {
+			By("creating a quota and two ElasticWebs in the namespace")
+			Expect(k8sClient.Create(ctx, &elasticwebv1.ElasticWebQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: quotaName.Name, Namespace: quotaName.Namespace},
+				Spec:       elasticwebv1.ElasticWebQuotaSpec{MaxTotalQPS: ptr.To[int32](10000)},
+			})).To(Succeed())
+
+			for name, totalQPS := range map[string]int32{"quota-web-a": 1200, "quota-web-b": 300} {
+				Expect(k8sClient.Create(ctx, &elasticwebv1.ElasticWeb{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+					Spec: elasticwebv1.ElasticWebSpec{
+						SinglePodQPS: ptr.To[int32](500),
+						TotalQPS:     ptr.To(totalQPS),
+						Deploy: []elasticwebv1.ElasticWebSpecDeploy{{
+							Name:  "tomcat",
+							Image: "tomcat:8.0.18-jre8",
+							Ports: []elasticwebv1.ElasticWebSpecDeployPorts{{Name: "http", Port: ptr.To[int32](8080)}},
+						}},
+						Service: elasticwebv1.ElasticWebSpecSvc{
+							Type:  "ClusterIP",
+							Ports: []elasticwebv1.ElasticWebSpecSvcPorts{{Name: "http", Port: ptr.To[int32](8080), TargetPort: ptr.To(intstr.FromInt32(8080))}},
+						},
+					},
+				})).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.DeleteAllOf(ctx, &elasticwebv1.ElasticWeb{}, client.InNamespace("default"))).To(Succeed())
+			Expect(k8sClient.DeleteAllOf(ctx, &elasticwebv1.ElasticWebQuota{}, client.InNamespace("default"))).To(Succeed())
+		})
+
+		It("should report the namespace usage in status", func() {
+			controllerReconciler := &ElasticWebQuotaReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: quotaName})
+			Expect(err).NotTo(HaveOccurred())
+
+			quota := &elasticwebv1.ElasticWebQuota{}
+			Expect(k8sClient.Get(ctx, quotaName, quota)).To(Succeed())
+			Expect(quota.Status.UsedTotalQPS).To(Equal(int32(1500)))
+			Expect(quota.Status.UsedReplicas).To(Equal(int32(4)))
+			Expect(quota.Status.ElasticWebs).To(Equal(int32(2)))
+		})
+	})
+})
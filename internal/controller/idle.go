@@ -0,0 +1,389 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	elasticwebv1 "elasticweb/api/v1"
+	"elasticweb/internal/activator"
+)
+
+const (
+	// 开启spec.idle时，查询流量的间隔
+	IDLE_CHECK_INTERVAL = time.Minute
+	// activator每个请求都会调用Wake，这个间隔内已经记录过请求时间的不再更新status
+	WAKE_THROTTLE = 10 * time.Second
+	// service指向activator时使用的EndpointSlice名称后缀
+	ACTIVATOR_ENDPOINTSLICE_SUFFIX = "-activator"
+)
+
+// 是否具备空闲缩容的条件：需要activator接收请求，也需要流量数据判断是否空闲
+func (r *ElasticWebReconciler) idleAvailable() bool {
+	return r.Activator != nil && r.TrafficSource != nil && r.ActivatorAddress != ""
+}
+
+// 期望的副本数，空闲时为0，否则和GetExpectReplicas相同
+func getExpectReplicas(elasticWeb *elasticwebv1.ElasticWeb) int32 {
+	if elasticWeb.IsIdle() {
+		return 0
+	}
+	return elasticWeb.GetExpectReplicas()
+}
+
+// Idle condition
+func getIdleCondition(elasticWeb *elasticwebv1.ElasticWeb, idle bool, reason, message string) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               elasticwebv1.ConditionIdle,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: elasticWeb.Generation,
+	}
+	if idle {
+		condition.Status = metav1.ConditionTrue
+	}
+	return condition
+}
+
+// 1.没有开启spec.idle时，去掉Idle condition，activator不再接收该elasticWeb的请求；
+// 2.开启后先在activator注册，缩容到0以及扩容后pod还没有ready期间，请求都由activator接收；
+// 3.没有流量超过spec.idle.after就进入空闲状态，之后只有activator收到请求（Wake）才会退出；
+// 4.查询流量失败时不做判断，宁可多跑一会儿也不能把有流量的服务缩容到0。
+// 返回下一次检查流量的间隔，为0表示不需要定期检查
+func reconcileIdle(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) (time.Duration, error) {
+	key := types.NamespacedName{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name}
+	oldStatus := elasticWeb.Status.DeepCopy()
+	var requeueAfter time.Duration
+
+	switch {
+	case elasticWeb.Spec.Idle == nil:
+		if r.Activator != nil {
+			r.Activator.Unregister(key)
+		}
+		meta.RemoveStatusCondition(&elasticWeb.Status.Conditions, elasticwebv1.ConditionIdle)
+		elasticWeb.Status.LastRequestTime = nil
+
+	case !r.idleAvailable():
+		meta.SetStatusCondition(&elasticWeb.Status.Conditions, getIdleCondition(elasticWeb, false,
+			elasticwebv1.ReasonIdleUnavailable, "the manager runs without an activator or a traffic source, never scaling to zero"))
+
+	case elasticWeb.IsIdle():
+		// 空闲时应用pod的流量数据没有意义，等activator唤醒
+		if err := registerActivator(ctx, r, elasticWeb); err != nil {
+			return 0, err
+		}
+
+	default:
+		if err := registerActivator(ctx, r, elasticWeb); err != nil {
+			return 0, err
+		}
+
+		now := time.Now()
+		// 查不到流量数据（比如指标没有采集）时不能当作没有流量，和查询出错一样不缩容
+		rate, err := r.TrafficSource.RequestRate(ctx, key)
+		if stderrors.Is(err, activator.ErrNoData) {
+			log.Info("no request rate data, keep awake")
+			return IDLE_CHECK_INTERVAL, nil
+		}
+		if err != nil {
+			log.Error(err, "query request rate error")
+			return IDLE_CHECK_INTERVAL, nil
+		}
+		// 第一次看到spec.idle时开始计时，对早就创建的elasticWeb开启空闲缩容也要等满spec.idle.after
+		if rate > 0 || elasticWeb.Status.LastRequestTime == nil {
+			elasticWeb.Status.LastRequestTime = &metav1.Time{Time: now}
+		}
+
+		idleFor := now.Sub(elasticWeb.Status.LastRequestTime.Time)
+		if idleFor >= elasticWeb.Spec.Idle.After.Duration {
+			log.Info(fmt.Sprintf("no traffic for %s, scale to zero", idleFor.Round(time.Second)))
+			meta.SetStatusCondition(&elasticWeb.Status.Conditions, getIdleCondition(elasticWeb, true,
+				elasticwebv1.ReasonNoTraffic, fmt.Sprintf("no traffic for %s", elasticWeb.Spec.Idle.After.Duration)))
+			if r.Recorder != nil {
+				r.Recorder.Event(elasticWeb, corev1.EventTypeNormal, "Idle",
+					fmt.Sprintf("no traffic for %s, scaled to zero", elasticWeb.Spec.Idle.After.Duration))
+			}
+		} else {
+			// 被唤醒后保留Woken，直到应用pod自己上报了流量
+			if condition := meta.FindStatusCondition(elasticWeb.Status.Conditions, elasticwebv1.ConditionIdle); rate > 0 || condition == nil || condition.Reason != elasticwebv1.ReasonWoken {
+				meta.SetStatusCondition(&elasticWeb.Status.Conditions, getIdleCondition(elasticWeb, false,
+					elasticwebv1.ReasonReceivingTraffic, fmt.Sprintf("%.2f requests per second", rate)))
+			}
+			requeueAfter = min(IDLE_CHECK_INTERVAL, elasticWeb.Spec.Idle.After.Duration-idleFor)
+		}
+	}
+
+	if equality.Semantic.DeepEqual(oldStatus, &elasticWeb.Status) {
+		return requeueAfter, nil
+	}
+	if err := r.Status().Update(ctx, elasticWeb); err != nil {
+		log.Error(err, "update idle status error")
+		return 0, err
+	}
+	return requeueAfter, nil
+}
+
+// Wake 实现activator.Waker：记录请求时间，空闲时退出空闲状态，status的变化会触发Reconcile扩容
+func (r *ElasticWebReconciler) Wake(ctx context.Context, key types.NamespacedName) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		elasticWeb := &elasticwebv1.ElasticWeb{}
+		if err := r.Get(ctx, key, elasticWeb); err != nil {
+			return err
+		}
+		if elasticWeb.Spec.Idle == nil {
+			return nil
+		}
+
+		now := metav1.Now()
+		idle := elasticWeb.IsIdle()
+		lastRequest := elasticWeb.Status.LastRequestTime
+		if !idle && lastRequest != nil && now.Sub(lastRequest.Time) < WAKE_THROTTLE {
+			return nil
+		}
+
+		elasticWeb.Status.LastRequestTime = &now
+		if idle {
+			meta.SetStatusCondition(&elasticWeb.Status.Conditions, getIdleCondition(elasticWeb, false,
+				elasticwebv1.ReasonWoken, "woken by a request through the activator"))
+		}
+		if err := r.Status().Update(ctx, elasticWeb); err != nil {
+			return err
+		}
+		if idle && r.Recorder != nil {
+			r.Recorder.Event(elasticWeb, corev1.EventTypeNormal, "Woken", "woken by a request, scaling up")
+		}
+		return nil
+	})
+}
+
+// 在activator注册elasticWeb，除了service名称，activator还要能按以下Host找到elasticWeb：
+// 1.经过Gateway的请求带的是spec.route.hostnames中的公网域名；
+// 2.直接访问service的clusterIP、externalIP或者负载均衡地址时，Host是这些地址。
+func registerActivator(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	var hosts []string
+	if elasticWeb.Spec.Route != nil {
+		for _, h := range elasticWeb.Spec.Route.Hostnames {
+			hosts = append(hosts, string(h))
+		}
+	}
+
+	service := &corev1.Service{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name}, service)
+	switch {
+	case err == nil:
+		for _, ip := range service.Spec.ClusterIPs {
+			if ip != corev1.ClusterIPNone {
+				hosts = append(hosts, ip)
+			}
+		}
+		hosts = append(hosts, service.Spec.ExternalIPs...)
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				hosts = append(hosts, ingress.IP)
+			}
+			if ingress.Hostname != "" {
+				hosts = append(hosts, ingress.Hostname)
+			}
+		}
+	case !errors.IsNotFound(err):
+		// service还没有创建时，创建后的Reconcile会再注册一次
+		log.Error(err, "query service error")
+		return err
+	}
+
+	r.Activator.Register(types.NamespacedName{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name}, hosts...)
+	return nil
+}
+
+// Backend 实现activator.BackendResolver：返回一个ready的pod上service第port个端口对应的地址，没有ready的pod时返回nil
+func (r *ElasticWebReconciler) Backend(ctx context.Context, key types.NamespacedName, port int) (*url.URL, error) {
+	elasticWeb := &elasticwebv1.ElasticWeb{}
+	if err := r.Get(ctx, key, elasticWeb); err != nil {
+		return nil, err
+	}
+	ports := elasticWeb.Spec.Service.Ports
+	if port < 0 || port >= len(ports) || ports[port].TargetPort == nil {
+		return nil, fmt.Errorf("elasticweb %s has no service port %d", key, port)
+	}
+	svcPort := &ports[port]
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(key.Namespace), client.MatchingLabels{elasticwebv1.NameLabel: key.Name}); err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || pod.Status.PodIP == "" || !isPodReady(pod) {
+			continue
+		}
+		podPort, ok := getPodPort(pod, svcPort)
+		if !ok {
+			continue
+		}
+		return &url.URL{
+			Scheme: "http",
+			Host:   net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(podPort))),
+		}, nil
+	}
+	return nil, nil
+}
+
+// service端口在pod上对应的端口号，targetport是端口名称时在pod的容器中查找，各个变体中同名端口的端口号可以不同
+func getPodPort(pod *corev1.Pod, svcPort *elasticwebv1.ElasticWebSpecSvcPorts) (int32, bool) {
+	if svcPort.TargetPort.Type == intstr.Int {
+		return svcPort.TargetPort.IntVal, true
+	}
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == svcPort.TargetPort.StrVal && p.Protocol == svcPort.GetProtocol() {
+				return p.ContainerPort, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// 1.空闲时，以及开启spec.idle后还没有ready的pod时，service去掉selector，由controller维护的EndpointSlice指向activator；
+// 2.其他情况下service按NameLabel选择pod，删除指向activator的EndpointSlice；
+// 3.service不存在时什么都不做。
+func syncServiceEndpoints(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb, readyReplicas int32) error {
+	service := &corev1.Service{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name}, service); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		log.Error(err, "query service error")
+		return err
+	}
+
+	useActivator := elasticWeb.Spec.Idle != nil && r.idleAvailable() &&
+		(elasticWeb.IsIdle() || readyReplicas == 0)
+
+	// 先准备好EndpointSlice再切换selector，避免service短暂没有任何endpoint
+	if useActivator {
+		if err := createOrUpdateActivatorEndpointSlice(ctx, r, elasticWeb, service); err != nil {
+			return err
+		}
+	}
+
+	var selector map[string]string
+	if !useActivator {
+		selector = getServiceSelector(elasticWeb)
+	}
+	if !equality.Semantic.DeepEqual(selector, service.Spec.Selector) {
+		service.Spec.Selector = selector
+		log.Info(fmt.Sprintf("16. set service selector %v", selector))
+		if err := r.Update(ctx, service); err != nil {
+			log.Error(err, "update service selector error")
+			return err
+		}
+	}
+
+	if !useActivator {
+		slice := &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name + ACTIVATOR_ENDPOINTSLICE_SUFFIX},
+		}
+		if err := r.Delete(ctx, slice); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "delete activator endpointslice error")
+			return err
+		}
+	}
+	return nil
+}
+
+// activator是HTTP代理，只接收TCP上的HTTP和websocket，其他端口空闲期间没有endpoint
+func isActivatorPort(port *corev1.ServicePort) bool {
+	if port.Protocol != "" && port.Protocol != corev1.ProtocolTCP {
+		return false
+	}
+	return port.AppProtocol == nil || *port.AppProtocol == "http" || *port.AppProtocol == "kubernetes.io/ws"
+}
+
+// 指向activator的EndpointSlice，service的第i个端口转发到activator的第i个端口，
+// activator根据端口找到要转发的targetport，根据Host区分elasticWeb
+func createOrUpdateActivatorEndpointSlice(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb, service *corev1.Service) error {
+	addressType := discoveryv1.AddressTypeIPv4
+	if ip := net.ParseIP(r.ActivatorAddress); ip != nil && ip.To4() == nil {
+		addressType = discoveryv1.AddressTypeIPv6
+	}
+
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name + ACTIVATOR_ENDPOINTSLICE_SUFFIX},
+	}
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, slice, func() error {
+		if slice.Labels == nil {
+			slice.Labels = map[string]string{}
+		}
+		slice.Labels[discoveryv1.LabelServiceName] = service.Name
+		slice.Labels[discoveryv1.LabelManagedBy] = "elasticweb-controller"
+		slice.AddressType = addressType
+		slice.Endpoints = []discoveryv1.Endpoint{{
+			Addresses:  []string{r.ActivatorAddress},
+			Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(true)},
+		}}
+		slice.Ports = nil
+		for i := range service.Spec.Ports {
+			p := &service.Spec.Ports[i]
+			if i >= activator.MaxPorts || !isActivatorPort(p) {
+				continue
+			}
+			slice.Ports = append(slice.Ports, discoveryv1.EndpointPort{
+				Name:     ptr.To(p.Name),
+				Protocol: ptr.To(corev1.ProtocolTCP),
+				Port:     ptr.To(r.ActivatorPort + int32(i)),
+			})
+		}
+		return controllerutil.SetControllerReference(elasticWeb, slice, r.Scheme)
+	})
+	if err != nil {
+		log.Error(err, "create or update activator endpointslice error")
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info(fmt.Sprintf("activator endpointslice %s", op))
+	}
+	return nil
+}
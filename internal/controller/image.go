@@ -0,0 +1,154 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	elasticwebv1 "elasticweb/api/v1"
+	"elasticweb/internal/registry"
+)
+
+const (
+	// 开启digest固定时，重新解析tag的间隔
+	IMAGE_RESOLVE_INTERVAL = 5 * time.Minute
+)
+
+// 容器实际使用的镜像：开启digest固定且已经解析出digest时使用image@digest，否则使用spec中的镜像
+func getImage(elasticWeb *elasticwebv1.ElasticWeb, deploy *elasticwebv1.ElasticWebSpecDeploy) string {
+	if !elasticWeb.Spec.PinImageDigests {
+		return deploy.Image
+	}
+	digest := elasticWeb.GetImageDigest(deploy)
+	if digest == "" {
+		return deploy.Image
+	}
+	ref, err := registry.ParseReference(deploy.Image)
+	if err != nil {
+		return deploy.Image
+	}
+	return ref.WithDigest(digest)
+}
+
+// 镜像拉取策略，默认IfNotPresent
+func getPullPolicy(deploy *elasticwebv1.ElasticWebSpecDeploy) corev1.PullPolicy {
+	if deploy.PullPolicy == "" {
+		return corev1.PullIfNotPresent
+	}
+	return deploy.PullPolicy
+}
+
+// 1.把每个容器镜像的tag解析成digest，结果写入status.imageDigests；
+// 2.解析失败时如果之前解析过同一个镜像，继续使用旧的digest，不影响正在运行的pod；
+// 3.从来没有解析成功过的镜像返回错误，避免先用tag创建pod、之后又因为digest再滚动一次。
+func resolveImageDigests(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	if !elasticWeb.Spec.PinImageDigests {
+		if len(elasticWeb.Status.ImageDigests) == 0 {
+			return nil
+		}
+		elasticWeb.Status.ImageDigests = nil
+		return r.Status().Update(ctx, elasticWeb)
+	}
+
+	keychain, err := getRegistryKeychain(ctx, r, elasticWeb)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	var digests []elasticwebv1.ElasticWebImageDigest
+	for i := range elasticWeb.Spec.Deploy {
+		deploy := &elasticWeb.Spec.Deploy[i]
+		old := elasticWeb.GetImageDigest(deploy)
+
+		digest, err := r.ImageResolver.Resolve(ctx, deploy.Image, keychain)
+		if err != nil {
+			if old == "" {
+				return fmt.Errorf("resolve image %s of container %s: %w", deploy.Image, deploy.Name, err)
+			}
+			log.Error(err, "resolve image error, keep digest "+old)
+			digest = old
+		}
+
+		entry := elasticwebv1.ElasticWebImageDigest{
+			Name:       deploy.Name,
+			Image:      deploy.Image,
+			Digest:     digest,
+			ResolvedAt: metav1.Now(),
+		}
+		if digest == old {
+			// digest没变就保留原来的解析时间，避免每次调谐都写一次status
+			for _, d := range elasticWeb.Status.ImageDigests {
+				if d.Name == deploy.Name {
+					entry.ResolvedAt = d.ResolvedAt
+				}
+			}
+		} else {
+			log.Info(fmt.Sprintf("image [%s] of container [%s] resolved to [%s]", deploy.Image, deploy.Name, digest))
+			changed = true
+		}
+		digests = append(digests, entry)
+	}
+	if len(digests) != len(elasticWeb.Status.ImageDigests) {
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	elasticWeb.Status.ImageDigests = digests
+	if err := r.Status().Update(ctx, elasticWeb); err != nil {
+		log.Error(err, "update image digests error")
+		return err
+	}
+	return nil
+}
+
+// 用spec.imagePullSecrets中的凭证访问私有仓库，secret不存在或格式不对时跳过，和kubelet的行为一致
+func getRegistryKeychain(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) (registry.Keychain, error) {
+	keychain := registry.Keychain{}
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	for _, ref := range elasticWeb.Spec.ImagePullSecrets {
+		secret := &corev1.Secret{}
+		err := reader.Get(ctx, types.NamespacedName{Namespace: elasticWeb.Namespace, Name: ref.Name}, secret)
+		if errors.IsNotFound(err) {
+			log.Info("image pull secret " + ref.Name + " not found")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if secret.Type != corev1.SecretTypeDockerConfigJson {
+			continue
+		}
+		if err := keychain.AddDockerConfigJSON(secret.Data[corev1.DockerConfigJsonKey]); err != nil {
+			log.Error(err, "parse image pull secret "+ref.Name+" error")
+		}
+	}
+	return keychain, nil
+}
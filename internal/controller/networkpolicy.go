@@ -0,0 +1,176 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	elasticwebv1 "elasticweb/api/v1"
+)
+
+const (
+	// 限制了出口流量时总是放行的DNS端口
+	DNS_PORT = 53
+)
+
+// 1.设置了spec.networkPolicy时，创建或更新同名的NetworkPolicy，并和elasticWeb建立关联；
+// 2.没有设置时，删除该elasticWeb持有的NetworkPolicy；
+// 3.同名NetworkPolicy已存在但不是该elasticWeb创建的，返回错误，不修改它。
+func reconcileNetworkPolicy(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	key := types.NamespacedName{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name}
+	policy := &networkingv1.NetworkPolicy{}
+	err := r.Get(ctx, key, policy)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "query networkpolicy error")
+		return err
+	}
+	exists := err == nil
+
+	if elasticWeb.Spec.NetworkPolicy == nil {
+		if exists && metav1.IsControlledBy(policy, elasticWeb) {
+			log.Info("delete networkpolicy")
+			if err := r.Delete(ctx, policy); err != nil && !errors.IsNotFound(err) {
+				log.Error(err, "delete networkpolicy error")
+				return err
+			}
+		}
+		return nil
+	}
+
+	desired := getNetworkPolicySpec(r, elasticWeb)
+	if !exists {
+		policy = &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: elasticWeb.Namespace,
+				Name:      elasticWeb.Name,
+			},
+			Spec: desired,
+		}
+		if err := controllerutil.SetControllerReference(elasticWeb, policy, r.Scheme); err != nil {
+			log.Error(err, "SetControllerReference error")
+			return err
+		}
+		log.Info("start create networkpolicy")
+		if err := r.Create(ctx, policy); err != nil {
+			log.Error(err, "create networkpolicy error")
+			return err
+		}
+		return nil
+	}
+
+	if !metav1.IsControlledBy(policy, elasticWeb) {
+		return fmt.Errorf("networkpolicy %s already exists and is not owned by the ElasticWeb", key)
+	}
+	if equality.Semantic.DeepEqual(desired, policy.Spec) {
+		return nil
+	}
+	policy.Spec = desired
+	log.Info("update networkpolicy")
+	if err := r.Update(ctx, policy); err != nil {
+		log.Error(err, "update networkpolicy error")
+		return err
+	}
+	return nil
+}
+
+// NetworkPolicy选中该elasticWeb的所有pod（包括各个变体），只放行spec中的来源、Ingress controller和activator访问service的targetport；
+// 没有任何来源时不生成入口规则，即拒绝所有入口流量（只有from为空的规则才会放行所有来源）
+func getNetworkPolicySpec(r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) networkingv1.NetworkPolicySpec {
+	spec := elasticWeb.Spec.NetworkPolicy
+
+	var from []networkingv1.NetworkPolicyPeer
+	for i := range spec.From {
+		from = append(from, getNetworkPolicyPeer(&spec.From[i]))
+	}
+	if spec.IsIngressControllerAllowed() && r.IngressControllerPeer != nil {
+		from = append(from, *r.IngressControllerPeer.DeepCopy())
+	}
+	// 空闲时请求先到activator，pod启动后由activator转发
+	if elasticWeb.Spec.Idle != nil && r.idleAvailable() && r.ActivatorPeer != nil {
+		from = append(from, *r.ActivatorPeer.DeepCopy())
+	}
+
+	var ports []networkingv1.NetworkPolicyPort
+	for _, p := range elasticWeb.Spec.Service.Ports {
+		if p.TargetPort == nil {
+			continue
+		}
+		ports = append(ports, networkingv1.NetworkPolicyPort{
+			Protocol: ptr.To(p.GetProtocol()),
+			Port:     ptr.To(*p.TargetPort),
+		})
+	}
+
+	result := networkingv1.NetworkPolicySpec{
+		PodSelector: metav1.LabelSelector{MatchLabels: getServiceSelector(elasticWeb)},
+		PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+	}
+	if len(from) > 0 {
+		result.Ingress = []networkingv1.NetworkPolicyIngressRule{{From: from, Ports: ports}}
+	}
+
+	if len(spec.Egress) > 0 {
+		result.PolicyTypes = append(result.PolicyTypes, networkingv1.PolicyTypeEgress)
+		result.Egress = append(result.Egress, networkingv1.NetworkPolicyEgressRule{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: ptr.To(corev1.ProtocolUDP), Port: ptr.To(intstr.FromInt32(DNS_PORT))},
+				{Protocol: ptr.To(corev1.ProtocolTCP), Port: ptr.To(intstr.FromInt32(DNS_PORT))},
+			},
+		})
+		for i := range spec.Egress {
+			rule := spec.Egress[i].DeepCopy()
+			// 和apiserver的默认值一致，避免每次Reconcile都更新
+			for j := range rule.Ports {
+				if rule.Ports[j].Protocol == nil {
+					rule.Ports[j].Protocol = ptr.To(corev1.ProtocolTCP)
+				}
+			}
+			result.Egress = append(result.Egress, *rule)
+		}
+	}
+
+	return result
+}
+
+// namespaces转换成按kubernetes.io/metadata.name选择namespace；都没有设置时只有podSelector，即ElasticWeb所在的namespace
+func getNetworkPolicyPeer(peer *elasticwebv1.ElasticWebSpecNetworkPolicyPeer) networkingv1.NetworkPolicyPeer {
+	result := networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: peer.NamespaceSelector.DeepCopy(),
+		PodSelector:       peer.PodSelector.DeepCopy(),
+	}
+	if len(peer.Namespaces) > 0 {
+		result.NamespaceSelector = &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      corev1.LabelMetadataName,
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   peer.Namespaces,
+			}},
+		}
+	}
+	return result
+}
@@ -0,0 +1,424 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	elasticwebv1 "elasticweb/api/v1"
+)
+
+const (
+	// 成员集群的Secret中保存kubeconfig的key
+	KUBECONFIG_KEY = "kubeconfig"
+
+	// 成员集群中的变化不会触发hub调谐，按这个周期重新同步和汇总状态
+	PLACEMENT_SYNC_PERIOD = 30 * time.Second
+
+	// 访问成员集群的单个请求的超时时间，避免一个不可达的集群卡住所有ElasticWeb的调谐
+	MEMBER_CLUSTER_TIMEOUT = 10 * time.Second
+
+	// 复制到派生ElasticWeb上的注解前缀，webhook按这些注解放行特权容器、破坏性更新等
+	ANNOTATION_PREFIX = "elasticweb.com.bolingcavalry/"
+)
+
+// MemberClusters 通过Secret中的kubeconfig访问成员集群，Secret的名称就是集群名称；
+// client按Secret的resourceVersion缓存，Secret更新后重新创建
+type MemberClusters struct {
+	// Reader 读取Secret，使用不经过缓存的APIReader，避免manager缓存所有Secret
+	Reader client.Reader
+	// Namespace 是保存kubeconfig的Secret所在的namespace
+	Namespace string
+	// Scheme 是成员集群client使用的scheme，需要注册ElasticWeb
+	Scheme *runtime.Scheme
+
+	mu      sync.Mutex
+	clients map[string]memberCluster
+}
+
+type memberCluster struct {
+	resourceVersion string
+	client          client.Client
+}
+
+// Client 返回访问成员集群的client，Secret不存在时返回的error满足errors.IsNotFound
+func (m *MemberClusters) Client(ctx context.Context, name string) (client.Client, error) {
+	secret := &corev1.Secret{}
+	if err := m.Reader.Get(ctx, types.NamespacedName{Namespace: m.Namespace, Name: name}, secret); err != nil {
+		return nil, fmt.Errorf("read kubeconfig of cluster %s: %w", name, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cached, ok := m.clients[name]; ok && cached.resourceVersion == secret.ResourceVersion {
+		return cached.client, nil
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(secret.Data[KUBECONFIG_KEY])
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig of cluster %s: %w", name, err)
+	}
+	if config.Timeout == 0 || config.Timeout > MEMBER_CLUSTER_TIMEOUT {
+		config.Timeout = MEMBER_CLUSTER_TIMEOUT
+	}
+	c, err := client.New(config, client.Options{Scheme: m.Scheme})
+	if err != nil {
+		return nil, fmt.Errorf("connect to cluster %s: %w", name, err)
+	}
+	if m.clients == nil {
+		m.clients = map[string]memberCluster{}
+	}
+	m.clients[name] = memberCluster{resourceVersion: secret.ResourceVersion, client: c}
+	return c, nil
+}
+
+// 设置了spec.placement的ElasticWeb在hub上只是模板，本集群不创建deployment、service等资源：
+// 1.在每个成员集群中创建或更新同名的派生ElasticWeb，totalQPS和replicas按share分配；
+// 2.删除从spec.placement中去掉的集群里的派生ElasticWeb；
+// 3.把所有成员集群的状态汇总到hub的status，成员集群的变化按PLACEMENT_SYNC_PERIOD周期同步
+func reconcilePlacement(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) (ctrl.Result, error) {
+	if r.MemberClusters == nil {
+		log.Info("hub mode disabled, placement ignored")
+		condition := metav1.Condition{
+			Type:    elasticwebv1.ConditionPlaced,
+			Status:  metav1.ConditionFalse,
+			Reason:  elasticwebv1.ReasonHubDisabled,
+			Message: "the manager is not configured with member clusters",
+		}
+		return ctrl.Result{}, updatePlacementStatus(ctx, r, elasticWeb, elasticWeb.Status.Clusters, condition)
+	}
+
+	if !elasticWeb.DeletionTimestamp.IsZero() {
+		// 没有finalizer，成员集群已经清理过了
+		return ctrl.Result{}, nil
+	}
+
+	// 先加上finalizer，保证删除hub上的ElasticWeb时能清理成员集群
+	if controllerutil.AddFinalizer(elasticWeb, elasticwebv1.PlacementFinalizer) {
+		if err := r.Update(ctx, elasticWeb); err != nil {
+			log.Error(err, "add placement finalizer error")
+			return ctrl.Result{}, err
+		}
+	}
+
+	qps := elasticWeb.PlacementQPS()
+	replicas := elasticWeb.PlacementReplicas()
+	var clusters []elasticwebv1.ElasticWebClusterStatus
+	var failed []string
+	placed := sets.New[string]()
+	for i, cluster := range elasticWeb.Spec.Placement.Clusters {
+		placed.Insert(cluster.Name)
+		var clusterReplicas *int32
+		if replicas != nil {
+			clusterReplicas = ptr.To(replicas[i])
+		}
+		status := syncMemberCluster(ctx, r, cluster.Name, getMemberElasticWeb(elasticWeb, qps[i], clusterReplicas))
+		if status.Message != "" {
+			failed = append(failed, cluster.Name)
+		}
+		clusters = append(clusters, status)
+	}
+
+	// 从spec.placement中去掉的集群，删除失败时留在status中，下次同步时重试
+	for _, status := range elasticWeb.Status.Clusters {
+		if placed.Has(status.Name) {
+			continue
+		}
+		if err := deleteMemberElasticWeb(ctx, r, elasticWeb, status.Name); err != nil {
+			log.Error(err, "delete member elasticweb error", "cluster", status.Name)
+			status.Message = err.Error()
+			clusters = append(clusters, status)
+			failed = append(failed, status.Name)
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:    elasticwebv1.ConditionPlaced,
+		Status:  metav1.ConditionTrue,
+		Reason:  elasticwebv1.ReasonPlaced,
+		Message: fmt.Sprintf("placed on %d clusters", len(elasticWeb.Spec.Placement.Clusters)),
+	}
+	if len(failed) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = elasticwebv1.ReasonPlacementFailed
+		condition.Message = "failed to sync clusters: " + strings.Join(failed, ", ")
+	}
+	if err := updatePlacementStatus(ctx, r, elasticWeb, clusters, condition); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: PLACEMENT_SYNC_PERIOD}, nil
+}
+
+// 删除hub上的ElasticWeb或去掉spec.placement时，删除所有成员集群中派生的ElasticWeb，然后去掉finalizer；
+// 没有开启hub模式时无法访问成员集群，只有设置了orphan-placement注解才直接去掉finalizer
+func removePlacement(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	switch {
+	case r.MemberClusters != nil:
+		if err := deleteMemberElasticWebs(ctx, r, elasticWeb); err != nil {
+			return err
+		}
+	case elasticWeb.Annotations[elasticwebv1.OrphanPlacementAnnotation] == "true":
+		log.Info("hub mode disabled, orphan the ElasticWebs placed on member clusters")
+	default:
+		// 不能悄悄卡住删除，用事件告诉用户怎么处理
+		err := fmt.Errorf("hub mode disabled, cannot remove the ElasticWebs placed on member clusters; "+
+			"enable hub mode, or set the %s annotation to \"true\" to leave them behind", elasticwebv1.OrphanPlacementAnnotation)
+		if r.Recorder != nil {
+			r.Recorder.Event(elasticWeb, corev1.EventTypeWarning, "PlacementCleanupBlocked", err.Error())
+		}
+		return err
+	}
+
+	log.Info("remove placement finalizer")
+	controllerutil.RemoveFinalizer(elasticWeb, elasticwebv1.PlacementFinalizer)
+	if err := r.Update(ctx, elasticWeb); err != nil {
+		log.Error(err, "remove placement finalizer error")
+		return err
+	}
+	if !elasticWeb.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	// 去掉spec.placement后改为在本集群部署，成员集群的状态不再有意义
+	elasticWeb.Status.Clusters = nil
+	meta.RemoveStatusCondition(&elasticWeb.Status.Conditions, elasticwebv1.ConditionPlaced)
+	return r.Status().Update(ctx, elasticWeb)
+}
+
+// 删除spec.placement和status中记录的所有成员集群里派生的ElasticWeb
+func deleteMemberElasticWebs(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	clusters := sets.New[string]()
+	for _, status := range elasticWeb.Status.Clusters {
+		clusters.Insert(status.Name)
+	}
+	if elasticWeb.Spec.Placement != nil {
+		for _, cluster := range elasticWeb.Spec.Placement.Clusters {
+			clusters.Insert(cluster.Name)
+		}
+	}
+	for _, name := range sets.List(clusters) {
+		if err := deleteMemberElasticWeb(ctx, r, elasticWeb, name); err != nil {
+			log.Error(err, "delete member elasticweb error", "cluster", name)
+			return err
+		}
+	}
+	return nil
+}
+
+// 成员集群中的派生ElasticWeb：和hub上的同名，复制labels、本项目的注解和spec，
+// 去掉spec.placement，totalQPS和replicas换成该集群分到的部分
+func getMemberElasticWeb(elasticWeb *elasticwebv1.ElasticWeb, totalQPS int32, replicas *int32) *elasticwebv1.ElasticWeb {
+	member := &elasticwebv1.ElasticWeb{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   elasticWeb.Namespace,
+			Name:        elasticWeb.Name,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+		Spec: *elasticWeb.Spec.DeepCopy(),
+	}
+	for k, v := range elasticWeb.Labels {
+		member.Labels[k] = v
+	}
+	member.Labels[elasticwebv1.PlacementLabel] = string(elasticWeb.UID)
+	for k, v := range elasticWeb.Annotations {
+		if strings.HasPrefix(k, ANNOTATION_PREFIX) {
+			member.Annotations[k] = v
+		}
+	}
+	member.Spec.Placement = nil
+	member.Spec.TotalQPS = ptr.To(totalQPS)
+	member.Spec.Replicas = replicas
+
+	// 摘要覆盖所有hub决定的内容，成员集群的webhook填充的默认值不会引起反复更新
+	data, _ := json.Marshal(member)
+	member.Annotations[elasticwebv1.PlacementHashAnnotation] = fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+	return member
+}
+
+// 在成员集群中创建或更新派生的ElasticWeb，并读取它的状态；失败的原因记录在返回值的message中
+func syncMemberCluster(ctx context.Context, r *ElasticWebReconciler, name string, desired *elasticwebv1.ElasticWeb) elasticwebv1.ElasticWebClusterStatus {
+	status := elasticwebv1.ElasticWebClusterStatus{Name: name, TotalQPS: *desired.Spec.TotalQPS}
+
+	c, err := r.MemberClusters.Client(ctx, name)
+	if err != nil {
+		log.Error(err, "member cluster unavailable", "cluster", name)
+		status.Message = err.Error()
+		return status
+	}
+
+	member := &elasticwebv1.ElasticWeb{}
+	err = c.Get(ctx, client.ObjectKeyFromObject(desired), member)
+	if errors.IsNotFound(err) {
+		log.Info("create member elasticweb", "cluster", name)
+		if err = c.Create(ctx, desired); err != nil {
+			log.Error(err, "create member elasticweb error", "cluster", name)
+			status.Message = err.Error()
+		}
+		return status
+	}
+	if err != nil {
+		log.Error(err, "query member elasticweb error", "cluster", name)
+		status.Message = err.Error()
+		return status
+	}
+
+	// 同名的ElasticWeb不是这个hub创建的，不能覆盖
+	if member.Labels[elasticwebv1.PlacementLabel] != desired.Labels[elasticwebv1.PlacementLabel] {
+		status.Message = fmt.Sprintf("ElasticWeb %s already exists and is not placed by this hub", client.ObjectKeyFromObject(member))
+		return status
+	}
+
+	if member.Annotations[elasticwebv1.PlacementHashAnnotation] != desired.Annotations[elasticwebv1.PlacementHashAnnotation] {
+		log.Info("update member elasticweb", "cluster", name)
+		if member.Labels == nil {
+			member.Labels = map[string]string{}
+		}
+		if member.Annotations == nil {
+			member.Annotations = map[string]string{}
+		}
+		for k, v := range desired.Labels {
+			member.Labels[k] = v
+		}
+		for k, v := range desired.Annotations {
+			member.Annotations[k] = v
+		}
+		member.Spec = desired.Spec
+		if err = c.Update(ctx, member); err != nil {
+			log.Error(err, "update member elasticweb error", "cluster", name)
+			status.Message = err.Error()
+		}
+		return status
+	}
+
+	status.RealQPS = ptr.Deref(member.Status.RealQPS, 0)
+	status.Replicas = member.Status.Replicas
+	status.DesiredReplicas = member.Status.DesiredReplicas
+	status.ReadyReplicas = member.Status.ReadyReplicas
+	status.AvailableReplicas = member.Status.AvailableReplicas
+	status.Ready = member.Status.ObservedGeneration == member.Generation &&
+		meta.IsStatusConditionTrue(member.Status.Conditions, elasticwebv1.ConditionReady)
+	return status
+}
+
+// 删除成员集群中派生的ElasticWeb，只删除带有该hub标签的对象；
+// 集群的Secret已经删除时认为集群已注销，跳过清理
+func deleteMemberElasticWeb(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb, name string) error {
+	c, err := r.MemberClusters.Client(ctx, name)
+	if errors.IsNotFound(err) {
+		log.Info("member cluster unregistered, skip cleanup", "cluster", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	member := &elasticwebv1.ElasticWeb{}
+	if err = c.Get(ctx, client.ObjectKeyFromObject(elasticWeb), member); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if member.Labels[elasticwebv1.PlacementLabel] != string(elasticWeb.UID) {
+		return nil
+	}
+
+	// 删除是hub决定的，派生对象开启了删除保护时先确认删除
+	if member.Spec.DeletionProtection && member.Annotations[elasticwebv1.ConfirmDeleteAnnotation] != "true" {
+		if member.Annotations == nil {
+			member.Annotations = map[string]string{}
+		}
+		member.Annotations[elasticwebv1.ConfirmDeleteAnnotation] = "true"
+		if err = c.Update(ctx, member); err != nil {
+			return err
+		}
+	}
+	log.Info("delete member elasticweb", "cluster", name)
+	return client.IgnoreNotFound(c.Delete(ctx, member))
+}
+
+// hub的status是所有成员集群的合计：QPS和副本数相加，所有集群都Ready时Ready
+func updatePlacementStatus(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb,
+	clusters []elasticwebv1.ElasticWebClusterStatus, placed metav1.Condition) error {
+	oldStatus := elasticWeb.Status.DeepCopy()
+
+	var singlePodQPS int32
+	if elasticWeb.Spec.SinglePodQPS != nil {
+		singlePodQPS = *elasticWeb.Spec.SinglePodQPS
+	}
+
+	status := &elasticWeb.Status
+	status.Clusters = clusters
+	status.RealQPS = ptr.To[int32](0)
+	status.Replicas = 0
+	status.DesiredReplicas = 0
+	status.ReadyReplicas = 0
+	status.AvailableReplicas = 0
+	var notReady []string
+	for _, c := range clusters {
+		*status.RealQPS += c.RealQPS
+		status.Replicas += c.Replicas
+		status.DesiredReplicas += c.DesiredReplicas
+		status.ReadyReplicas += c.ReadyReplicas
+		status.AvailableReplicas += c.AvailableReplicas
+		if !c.Ready {
+			notReady = append(notReady, c.Name)
+		}
+	}
+	status.DesiredQPS = singlePodQPS * status.DesiredReplicas
+	status.ObservedGeneration = elasticWeb.Generation
+
+	ready := metav1.Condition{
+		Type:    elasticwebv1.ConditionReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  elasticwebv1.ReasonPodsReady,
+		Message: fmt.Sprintf("%d clusters ready", len(clusters)),
+	}
+	if len(notReady) > 0 || len(clusters) == 0 {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = elasticwebv1.ReasonPodsNotReady
+		ready.Message = "clusters not ready: " + strings.Join(notReady, ", ")
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+	meta.SetStatusCondition(&status.Conditions, placed)
+
+	if equality.Semantic.DeepEqual(oldStatus, status) {
+		return nil
+	}
+	if err := r.Status().Update(ctx, elasticWeb); err != nil {
+		log.Error(err, "update placement status error")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	elasticwebv1 "elasticweb/api/v1"
+)
+
+// Gateway API的HTTPRoute，没有引入gateway-api模块，通过unstructured读写，集群没有安装Gateway API时也能正常运行
+var httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+// 以下是HTTPRoute中controller用到的字段，json tag和Gateway API一致
+type httpRouteSpec struct {
+	ParentRefs []httpRouteParentRef `json:"parentRefs,omitempty"`
+	Hostnames  []string             `json:"hostnames,omitempty"`
+	Rules      []httpRouteRule      `json:"rules,omitempty"`
+}
+
+type httpRouteParentRef struct {
+	Namespace   *string `json:"namespace,omitempty"`
+	Name        string  `json:"name"`
+	SectionName *string `json:"sectionName,omitempty"`
+	Port        *int32  `json:"port,omitempty"`
+}
+
+type httpRouteRule struct {
+	Matches     []httpRouteMatch `json:"matches,omitempty"`
+	BackendRefs []httpBackendRef `json:"backendRefs,omitempty"`
+}
+
+type httpRouteMatch struct {
+	Path *httpPathMatch `json:"path,omitempty"`
+}
+
+type httpPathMatch struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+type httpBackendRef struct {
+	Name   string `json:"name"`
+	Port   *int32 `json:"port,omitempty"`
+	Weight *int32 `json:"weight,omitempty"`
+}
+
+type httpRouteStatus struct {
+	Parents []httpRouteParentStatus `json:"parents,omitempty"`
+}
+
+type httpRouteParentStatus struct {
+	ParentRef      httpRouteParentRef `json:"parentRef"`
+	ControllerName string             `json:"controllerName"`
+	Conditions     []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// 按spec.route生成HTTPRoute的spec：一条规则，matches中任意一个路径匹配时，按权重转发到ElasticWeb的service和其他后端；
+// 没有matches时显式写出Gateway API的默认匹配，和apiserver填充后的spec一致
+func getHTTPRouteSpec(elasticWeb *elasticwebv1.ElasticWeb) httpRouteSpec {
+	route := elasticWeb.Spec.Route
+	spec := httpRouteSpec{}
+	for _, p := range route.ParentRefs {
+		ref := httpRouteParentRef{Name: p.Name, Port: p.Port}
+		if p.Namespace != "" {
+			ref.Namespace = ptr.To(p.Namespace)
+		}
+		if p.SectionName != "" {
+			ref.SectionName = ptr.To(p.SectionName)
+		}
+		spec.ParentRefs = append(spec.ParentRefs, ref)
+	}
+	for _, h := range route.Hostnames {
+		spec.Hostnames = append(spec.Hostnames, string(h))
+	}
+
+	rule := httpRouteRule{}
+	for _, m := range route.Matches {
+		pathType := m.Type
+		if pathType == "" {
+			pathType = "PathPrefix"
+		}
+		rule.Matches = append(rule.Matches, httpRouteMatch{Path: &httpPathMatch{Type: pathType, Value: m.Value}})
+	}
+	if len(rule.Matches) == 0 {
+		rule.Matches = []httpRouteMatch{{Path: &httpPathMatch{Type: "PathPrefix", Value: "/"}}}
+	}
+	rule.BackendRefs = append(rule.BackendRefs, httpBackendRef{
+		Name:   elasticWeb.Name,
+		Port:   ptr.To(elasticWeb.GetRouteServicePort()),
+		Weight: ptr.To(ptr.Deref(route.Weight, 1)),
+	})
+	for _, b := range route.Backends {
+		rule.BackendRefs = append(rule.BackendRefs, httpBackendRef{
+			Name:   b.Name,
+			Port:   ptr.To(b.Port),
+			Weight: ptr.To(ptr.Deref(b.Weight, 1)),
+		})
+	}
+	spec.Rules = []httpRouteRule{rule}
+	return spec
+}
+
+// 只比较controller管理的字段，Gateway API填充的group、kind等默认值不在httpRouteSpec中，不算差异；
+// 去掉hostnames或matches时，空值也要同步到HTTPRoute
+func isHTTPRouteSpecEqual(desired *httpRouteSpec, route *unstructured.Unstructured) bool {
+	spec, ok := route.Object["spec"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	actual := httpRouteSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &actual); err != nil {
+		return false
+	}
+	return equality.Semantic.DeepEqual(desired, &actual)
+}
+
+// 1.设置了spec.route时，创建或更新同名的HTTPRoute，并把它的status.parents复制到status.routeParents；
+// 2.去掉spec.route后删除HTTPRoute，清空status中的路由信息；
+// 3.集群没有安装Gateway API时，RouteAccepted condition为False。
+func reconcileRoute(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	oldStatus := elasticWeb.Status.DeepCopy()
+	if err := syncHTTPRoute(ctx, r, elasticWeb); err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(oldStatus, &elasticWeb.Status) {
+		return nil
+	}
+	if err := r.Status().Update(ctx, elasticWeb); err != nil {
+		log.Error(err, "update route status error")
+		return err
+	}
+	return nil
+}
+
+// 创建、更新或删除HTTPRoute，只修改内存中的status
+func syncHTTPRoute(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	key := types.NamespacedName{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name}
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(httpRouteGVK)
+
+	if elasticWeb.Spec.Route == nil {
+		// 没有设置过spec.route时不访问apiserver
+		if meta.FindStatusCondition(elasticWeb.Status.Conditions, elasticwebv1.ConditionRouteAccepted) == nil {
+			return nil
+		}
+		err := r.Get(ctx, key, route)
+		switch {
+		case err == nil && metav1.IsControlledBy(route, elasticWeb):
+			log.Info("delete httproute")
+			if err = r.Delete(ctx, route); err != nil && !errors.IsNotFound(err) {
+				log.Error(err, "delete httproute error")
+				return err
+			}
+		case err != nil && !errors.IsNotFound(err) && !meta.IsNoMatchError(err):
+			log.Error(err, "query httproute error")
+			return err
+		}
+		elasticWeb.Status.RouteParents = nil
+		meta.RemoveStatusCondition(&elasticWeb.Status.Conditions, elasticwebv1.ConditionRouteAccepted)
+		return nil
+	}
+
+	desiredSpec := getHTTPRouteSpec(elasticWeb)
+	desired, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&desiredSpec)
+	if err != nil {
+		return err
+	}
+
+	err = r.Get(ctx, key, route)
+	switch {
+	case meta.IsNoMatchError(err):
+		elasticWeb.Status.RouteParents = nil
+		meta.SetStatusCondition(&elasticWeb.Status.Conditions, metav1.Condition{
+			Type:               elasticwebv1.ConditionRouteAccepted,
+			Status:             metav1.ConditionFalse,
+			Reason:             elasticwebv1.ReasonGatewayAPIUnavailable,
+			Message:            "the HTTPRoute CRD of Gateway API is not installed",
+			ObservedGeneration: elasticWeb.Generation,
+		})
+		return nil
+	case errors.IsNotFound(err):
+		route.SetNamespace(elasticWeb.Namespace)
+		route.SetName(elasticWeb.Name)
+		route.Object["spec"] = desired
+		if err = controllerutil.SetControllerReference(elasticWeb, route, r.Scheme); err != nil {
+			log.Error(err, "SetControllerReference error")
+			return err
+		}
+		log.Info("start create httproute")
+		if err = r.Create(ctx, route); err != nil {
+			log.Error(err, "create httproute error")
+			return err
+		}
+	case err != nil:
+		log.Error(err, "query httproute error")
+		return err
+	case !metav1.IsControlledBy(route, elasticWeb):
+		return fmt.Errorf("httproute %s already exists and is not owned by the ElasticWeb", key)
+	case !isHTTPRouteSpecEqual(&desiredSpec, route):
+		route.Object["spec"] = desired
+		log.Info("update httproute")
+		if err = r.Update(ctx, route); err != nil {
+			log.Error(err, "update httproute error")
+			return err
+		}
+	}
+
+	return setRouteStatus(elasticWeb, route)
+}
+
+// 把HTTPRoute的status.parents复制到status.routeParents，并汇总成RouteAccepted condition
+func setRouteStatus(elasticWeb *elasticwebv1.ElasticWeb, route *unstructured.Unstructured) error {
+	status := httpRouteStatus{}
+	if raw, ok := route.Object["status"].(map[string]interface{}); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &status); err != nil {
+			return fmt.Errorf("invalid httproute status: %w", err)
+		}
+	}
+
+	var parents []elasticwebv1.ElasticWebRouteParentStatus
+	var notAccepted []string
+	for _, p := range status.Parents {
+		parent := elasticwebv1.ElasticWebRouteParentStatus{
+			ParentRef: elasticwebv1.ElasticWebSpecRouteParentRef{
+				Name:        p.ParentRef.Name,
+				Namespace:   ptr.Deref(p.ParentRef.Namespace, ""),
+				SectionName: ptr.Deref(p.ParentRef.SectionName, ""),
+				Port:        p.ParentRef.Port,
+			},
+			ControllerName: p.ControllerName,
+			Conditions:     p.Conditions,
+		}
+		parents = append(parents, parent)
+		if !meta.IsStatusConditionTrue(p.Conditions, "Accepted") {
+			notAccepted = append(notAccepted, p.ParentRef.Name)
+		}
+	}
+	elasticWeb.Status.RouteParents = parents
+
+	condition := metav1.Condition{
+		Type:               elasticwebv1.ConditionRouteAccepted,
+		ObservedGeneration: elasticWeb.Generation,
+	}
+	switch {
+	case len(parents) < len(elasticWeb.Spec.Route.ParentRefs):
+		// Gateway controller还没有处理，或者parentRef指向的Gateway不存在
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = elasticwebv1.ReasonRoutePending
+		condition.Message = fmt.Sprintf("%d/%d gateways reported a status", len(parents), len(elasticWeb.Spec.Route.ParentRefs))
+	case len(notAccepted) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = elasticwebv1.ReasonRouteNotAccepted
+		condition.Message = "not accepted by gateways " + strings.Join(notAccepted, ",")
+	default:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = elasticwebv1.ReasonRouteAccepted
+		condition.Message = fmt.Sprintf("accepted by %d gateways", len(parents))
+	}
+	meta.SetStatusCondition(&elasticWeb.Status.Conditions, condition)
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	elasticwebv1 "elasticweb/api/v1"
+)
+
+// pod没有指定serviceAccountName时，apiserver填充的默认值
+const DEFAULT_SERVICE_ACCOUNT = "default"
+
+// 1.spec.serviceAccount.create为true时才处理；
+// 2.不存在就创建，并和elasticWeb建立关联，elasticWeb删除时ServiceAccount随之删除；
+// 3.已存在且由该elasticWeb持有时，同步spec中的注解（workload identity的绑定可能会调整），
+// 上次设置过、这次从spec中去掉的注解会被删除，其他工具加的注解保留；
+// 4.同名ServiceAccount已存在但不是该elasticWeb创建的，直接使用，不做修改。
+func createOrUpdateServiceAccount(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) error {
+	if elasticWeb.Spec.ServiceAccount == nil || !elasticWeb.Spec.ServiceAccount.Create {
+		return nil
+	}
+
+	name := elasticWeb.GetServiceAccountName()
+
+	sa := &corev1.ServiceAccount{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: elasticWeb.Namespace, Name: name}, sa)
+	if err == nil {
+		if !metav1.IsControlledBy(sa, elasticWeb) {
+			log.Info("serviceaccount " + name + " exists and is not owned by elasticweb, use it as is")
+			return nil
+		}
+		annotations := getServiceAccountAnnotations(elasticWeb, sa.Annotations)
+		if equality.Semantic.DeepEqual(annotations, sa.Annotations) {
+			return nil
+		}
+		sa.Annotations = annotations
+		log.Info("update serviceaccount annotations")
+		if err := r.Update(ctx, sa); err != nil {
+			log.Error(err, "update serviceaccount error")
+			return err
+		}
+		return nil
+	}
+
+	if !errors.IsNotFound(err) {
+		log.Error(err, "query serviceaccount error")
+		return err
+	}
+
+	sa = &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   elasticWeb.Namespace,
+			Name:        name,
+			Annotations: getServiceAccountAnnotations(elasticWeb, nil),
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(elasticWeb, sa, r.Scheme); err != nil {
+		log.Error(err, "SetControllerReference error")
+		return err
+	}
+
+	log.Info("start create serviceaccount " + name)
+	if err := r.Create(ctx, sa); err != nil {
+		log.Error(err, "create serviceaccount error")
+		return err
+	}
+	return nil
+}
+
+// 在ServiceAccount现有的注解上应用spec中的注解：删除上次设置过、这次去掉的key，
+// 再把这次设置的key记录到ManagedAnnotationsAnnotation中，不修改传入的map
+func getServiceAccountAnnotations(elasticWeb *elasticwebv1.ElasticWeb, current map[string]string) map[string]string {
+	desired := elasticWeb.Spec.ServiceAccount.Annotations
+	annotations := map[string]string{}
+	for k, v := range current {
+		annotations[k] = v
+	}
+
+	var managed []string
+	if value, ok := current[elasticwebv1.ManagedAnnotationsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &managed); err != nil {
+			log.Error(err, "invalid managed annotations of serviceaccount, ignored")
+		}
+	}
+	for _, k := range managed {
+		if _, ok := desired[k]; !ok {
+			delete(annotations, k)
+		}
+	}
+	delete(annotations, elasticwebv1.ManagedAnnotationsAnnotation)
+
+	for k, v := range desired {
+		annotations[k] = v
+	}
+	if keys := sets.List(sets.KeySet(desired)); len(keys) > 0 {
+		value, _ := json.Marshal(keys)
+		annotations[elasticwebv1.ManagedAnnotationsAnnotation] = string(value)
+	}
+
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
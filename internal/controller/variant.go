@@ -0,0 +1,253 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	elasticwebv1 "elasticweb/api/v1"
+)
+
+// 变体的pod标签：在getPodLabels的基础上加上VariantLabel，各个变体的deployment selector互不重叠，
+// service和status.selector仍然按NameLabel选中所有变体的pod
+func getVariantPodLabels(elasticWeb *elasticwebv1.ElasticWeb, deploy *elasticwebv1.ElasticWebSpecDeploy) map[string]string {
+	labels := getPodLabels(elasticWeb)
+	labels[elasticwebv1.VariantLabel] = deploy.Name
+	return labels
+}
+
+// 变体的pod模板只有这个变体自己的容器
+func getVariantPodSpec(elasticWeb *elasticwebv1.ElasticWeb, index int) corev1.PodSpec {
+	podSpec := getPodSpec(elasticWeb)
+	podSpec.Containers = podSpec.Containers[index : index+1]
+	return podSpec
+}
+
+// 1.deployMode为Variants时，spec.deploy中的每一项对应一个deployment，副本数按weight分配；
+// 2.原来的同名deployment（从Containers切换过来时）和已经从spec.deploy中删除的变体的deployment都会被删除；
+// 3.所有变体的pod都在同一个service后面，service按NameLabel选择pod。
+// 返回所有变体是否都已经完成滚动更新
+func reconcileVariants(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) (bool, error) {
+	// 从Containers切换到Variants时，删除原来的deployment，不是该ElasticWeb创建的同名deployment不能动
+	old := &appsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: elasticWeb.Namespace, Name: elasticWeb.Name}, old)
+	switch {
+	case err == nil && metav1.IsControlledBy(old, elasticWeb):
+		if err = r.Delete(ctx, old); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "delete deployment error")
+			return false, err
+		}
+	case err != nil && !errors.IsNotFound(err):
+		log.Error(err, "query deployment error")
+		return false, err
+	}
+
+	existing, err := listVariantDeployments(ctx, r, elasticWeb)
+	if err != nil {
+		return false, err
+	}
+
+	replicas := getExpectReplicas(elasticWeb)
+	variantReplicas := elasticWeb.VariantReplicas(replicas)
+	log.Info(fmt.Sprintf("9. expectReplicas [%d], variants %v", replicas, variantReplicas))
+
+	// 如果对QPS没有需求，此时又没有deployment，就啥事都不做了
+	if replicas < 1 && len(existing) == 0 {
+		log.Info("5.1 not need deployment")
+		if err := updateStatus(ctx, r, elasticWeb); err != nil {
+			log.Error(err, "5.1 update status error")
+			return false, err
+		}
+		return true, nil
+	}
+
+	if err := createOrUpdateService(ctx, r, elasticWeb); err != nil {
+		log.Error(err, "5.2 error")
+		return false, err
+	}
+
+	keep := map[string]bool{}
+	var deployments []*appsv1.Deployment
+	for i := range elasticWeb.Spec.Deploy {
+		name := elasticWeb.VariantName(&elasticWeb.Spec.Deploy[i])
+		keep[name] = true
+
+		var deployment *appsv1.Deployment
+		for _, d := range existing {
+			if d.Name == name {
+				deployment = d
+			}
+		}
+		if deployment == nil {
+			if deployment, err = createVariantDeployment(ctx, r, elasticWeb, i, variantReplicas[i]); err != nil {
+				log.Error(err, "5.3 error")
+				return false, err
+			}
+			deployments = append(deployments, deployment)
+			continue
+		}
+
+		if *deployment.Spec.Replicas != variantReplicas[i] {
+			*deployment.Spec.Replicas = variantReplicas[i]
+			log.Info(fmt.Sprintf("11. update deployment %s replicas", name))
+			if err = r.Update(ctx, deployment); err != nil {
+				log.Error(err, "12. update deployment replicas error")
+				return false, err
+			}
+		}
+		var needUpdate bool
+		if deployment, needUpdate = getDiffDeployment(ctx, elasticWeb, deployment); needUpdate {
+			if err = r.Update(ctx, deployment); err != nil {
+				log.Error(err, "15. update deployment error")
+				return false, err
+			}
+		}
+		deployments = append(deployments, deployment)
+	}
+
+	if err = deleteStaleVariantDeployments(ctx, r, elasticWeb, keep); err != nil {
+		return false, err
+	}
+
+	// 空闲或者还没有ready的pod时，service指向activator
+	var readyReplicas int32
+	for _, deployment := range deployments {
+		readyReplicas += deployment.Status.ReadyReplicas
+	}
+	if err = syncServiceEndpoints(ctx, r, elasticWeb, readyReplicas); err != nil {
+		log.Error(err, "16. sync service endpoints error")
+		return false, err
+	}
+
+	log.Info("13. update status")
+	if err = updateStatus(ctx, r, elasticWeb, deployments...); err != nil {
+		log.Error(err, "14. update status error")
+		return false, err
+	}
+	return isConverged(elasticWeb, deployments...), nil
+}
+
+// 新建一个变体的deployment
+func createVariantDeployment(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb, index int, replicas int32) (*appsv1.Deployment, error) {
+	deploy := &elasticWeb.Spec.Deploy[index]
+	labels := getVariantPodLabels(elasticWeb, deploy)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: elasticWeb.Namespace,
+			Name:      elasticWeb.VariantName(deploy),
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(replicas),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: getVariantPodSpec(elasticWeb, index),
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(elasticWeb, deployment, r.Scheme); err != nil {
+		log.Error(err, "SetControllerReference error")
+		return nil, err
+	}
+
+	log.Info(fmt.Sprintf("start create deployment %s, replicas [%d]", deployment.Name, replicas))
+	if err := r.Create(ctx, deployment); err != nil {
+		// 同名的deployment不是该ElasticWeb持有的，listVariantDeployments没有列出它
+		if errors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("deployment %s already exists and is not owned by the ElasticWeb", deployment.Name)
+		}
+		log.Error(err, "create deployment error")
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// 该ElasticWeb持有的所有变体deployment，包括已经从spec.deploy中删除的变体
+func listVariantDeployments(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb) ([]*appsv1.Deployment, error) {
+	list := &appsv1.DeploymentList{}
+	if err := r.List(ctx, list, client.InNamespace(elasticWeb.Namespace),
+		client.MatchingLabels{elasticwebv1.NameLabel: elasticWeb.Name}, client.HasLabels{elasticwebv1.VariantLabel}); err != nil {
+		log.Error(err, "list variant deployments error")
+		return nil, err
+	}
+	var deployments []*appsv1.Deployment
+	for i := range list.Items {
+		if metav1.IsControlledBy(&list.Items[i], elasticWeb) {
+			deployments = append(deployments, &list.Items[i])
+		}
+	}
+	return deployments, nil
+}
+
+// 删除不在keep中的变体deployment，keep为nil时全部删除
+func deleteStaleVariantDeployments(ctx context.Context, r *ElasticWebReconciler, elasticWeb *elasticwebv1.ElasticWeb, keep map[string]bool) error {
+	deployments, err := listVariantDeployments(ctx, r, elasticWeb)
+	if err != nil {
+		return err
+	}
+	for _, deployment := range deployments {
+		if keep[deployment.Name] {
+			continue
+		}
+		log.Info("delete variant deployment " + deployment.Name)
+		if err := r.Delete(ctx, deployment); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "delete variant deployment error")
+			return err
+		}
+	}
+	return nil
+}
+
+// 每个变体的副本数，deployMode不是Variants时为nil
+func getVariantStatus(elasticWeb *elasticwebv1.ElasticWeb, replicas int32, deployments []*appsv1.Deployment) []elasticwebv1.ElasticWebVariantStatus {
+	if !elasticWeb.IsVariantMode() {
+		return nil
+	}
+	variantReplicas := elasticWeb.VariantReplicas(replicas)
+	var variants []elasticwebv1.ElasticWebVariantStatus
+	for i := range elasticWeb.Spec.Deploy {
+		variant := elasticwebv1.ElasticWebVariantStatus{
+			Name:            elasticWeb.Spec.Deploy[i].Name,
+			DesiredReplicas: variantReplicas[i],
+		}
+		name := elasticWeb.VariantName(&elasticWeb.Spec.Deploy[i])
+		for _, deployment := range deployments {
+			if deployment != nil && deployment.Name == name {
+				variant.ReadyReplicas = deployment.Status.ReadyReplicas
+				variant.AvailableReplicas = deployment.Status.AvailableReplicas
+			}
+		}
+		variants = append(variants, variant)
+	}
+	return variants
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package loadgen is the HTTP load generator behind singlePodQPS calibration. It sends
+// requests to a single pod at increasing, fixed rates and reports the highest rate at
+// which the latency SLO still held.
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config describes a calibration run.
+type Config struct {
+	// URL receives a GET request for every generated request.
+	URL string
+
+	// StartQPS is the first rate tried; StepQPS is added after every step that meets
+	// the SLO, until MaxQPS.
+	StartQPS int
+	StepQPS  int
+	MaxQPS   int
+	// StepDuration is how long each rate is held.
+	StepDuration time.Duration
+
+	// LatencySLO is the highest acceptable latency at Percentile (0-100).
+	LatencySLO time.Duration
+	Percentile float64
+	// MaxErrorRate is the highest acceptable share (0-1) of failed requests; non-2xx
+	// responses and timeouts count as failures.
+	MaxErrorRate float64
+
+	// HTTPClient sends the requests. When nil a client with a timeout of four times the
+	// SLO is used, so a stuck request fails the step instead of the run, and with room
+	// for MaxQPS idle connections to the target, so connections are reused instead of
+	// being dialed for every request.
+	HTTPClient *http.Client
+}
+
+// Step is the outcome of one rate.
+type Step struct {
+	TargetQPS   int           `json:"targetQPS"`
+	AchievedQPS float64       `json:"achievedQPS"`
+	Requests    int           `json:"requests"`
+	Errors      int           `json:"errors"`
+	Latency     time.Duration `json:"latency"`
+	MeetsSLO    bool          `json:"meetsSLO"`
+}
+
+// Result is the outcome of a calibration run.
+type Result struct {
+	// SustainableQPS is the highest rate that met the SLO, 0 when none did.
+	SustainableQPS int    `json:"sustainableQPS"`
+	Steps          []Step `json:"steps"`
+}
+
+// minAchievedRatio is the share of the target rate that must actually complete within a
+// step; a pod that queues requests beyond the step has not sustained the rate.
+const minAchievedRatio = 0.95
+
+func (c *Config) validate() error {
+	switch {
+	case c.URL == "":
+		return errors.New("url is required")
+	case c.StartQPS <= 0 || c.StepQPS <= 0 || c.MaxQPS < c.StartQPS:
+		return fmt.Errorf("invalid rates: start %d, step %d, max %d", c.StartQPS, c.StepQPS, c.MaxQPS)
+	case c.StepDuration <= 0:
+		return errors.New("step duration must be greater than 0")
+	case c.LatencySLO <= 0:
+		return errors.New("latency SLO must be greater than 0")
+	case c.Percentile <= 0 || c.Percentile > 100:
+		return fmt.Errorf("percentile %v must be in (0, 100]", c.Percentile)
+	case c.MaxErrorRate < 0 || c.MaxErrorRate > 1:
+		return fmt.Errorf("max error rate %v must be in [0, 1]", c.MaxErrorRate)
+	}
+	return nil
+}
+
+// Run steps the rate up until a step misses the SLO or MaxQPS has been held, and
+// returns every step it ran.
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConns = cfg.MaxQPS
+		transport.MaxIdleConnsPerHost = cfg.MaxQPS
+		defer transport.CloseIdleConnections()
+		cfg.HTTPClient = &http.Client{Transport: transport, Timeout: 4 * cfg.LatencySLO}
+	}
+
+	result := &Result{}
+	for qps := cfg.StartQPS; ; qps += cfg.StepQPS {
+		qps = min(qps, cfg.MaxQPS)
+		step := runStep(ctx, &cfg, qps)
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		result.Steps = append(result.Steps, step)
+		if !step.MeetsSLO {
+			break
+		}
+		result.SustainableQPS = qps
+		if qps == cfg.MaxQPS {
+			break
+		}
+	}
+	return result, nil
+}
+
+// runStep sends requests at a fixed rate for StepDuration. Requests are scheduled
+// open loop, independent of how fast earlier ones complete, so a slow pod sees the
+// queueing real clients would cause.
+func runStep(ctx context.Context, cfg *Config, qps int) Step {
+	interval := time.Second / time.Duration(qps)
+	total := int(math.Round(cfg.StepDuration.Seconds() * float64(qps)))
+
+	var (
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, total)
+		errs      int
+		wg        sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < total; i++ {
+		if wait := time.Until(start.Add(time.Duration(i) * interval)); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return Step{TargetQPS: qps}
+			case <-time.After(wait):
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latency, err := send(ctx, cfg.HTTPClient, cfg.URL)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs++
+				return
+			}
+			latencies = append(latencies, latency)
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	step := Step{
+		TargetQPS:   qps,
+		AchievedQPS: float64(total) / elapsed.Seconds(),
+		Requests:    total,
+		Errors:      errs,
+		Latency:     percentile(latencies, cfg.Percentile),
+	}
+	step.MeetsSLO = total > 0 &&
+		float64(errs) <= cfg.MaxErrorRate*float64(total) &&
+		step.Latency <= cfg.LatencySLO &&
+		step.AchievedQPS >= minAchievedRatio*float64(qps)
+	return step
+}
+
+func send(ctx context.Context, client *http.Client, url string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	latency := time.Since(start)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return latency, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return latency, nil
+}
+
+// percentile returns the nearest-rank percentile p (0-100) of the latencies, sorting
+// them in place.
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(latencies))))
+	return latencies[max(rank, 1)-1]
+}
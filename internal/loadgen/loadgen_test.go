@@ -0,0 +1,183 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// newServer starts a test server answering with status after holding a single
+// worker for serviceTime, so it serves at most 1/serviceTime requests per second
+// and queues the rest.
+func newServer(status int, serviceTime time.Duration) *httptest.Server {
+	var worker sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		worker.Lock()
+		time.Sleep(serviceTime)
+		worker.Unlock()
+		w.WriteHeader(status)
+	}))
+	DeferCleanup(server.Close)
+	return server
+}
+
+var _ = Describe("Run", func() {
+	ctx := context.Background()
+
+	It("Should find the rate a saturated server still serves within the SLO", func() {
+		// one worker at 4ms per request saturates at 250 QPS
+		server := newServer(http.StatusOK, 4*time.Millisecond)
+		result, err := Run(ctx, Config{
+			URL:          server.URL,
+			StartQPS:     50,
+			StepQPS:      100,
+			MaxQPS:       650,
+			StepDuration: 400 * time.Millisecond,
+			LatencySLO:   50 * time.Millisecond,
+			Percentile:   99,
+			MaxErrorRate: 0.01,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.SustainableQPS).To(BeNumerically(">=", 50))
+		Expect(result.SustainableQPS).To(BeNumerically("<=", 250))
+
+		last := result.Steps[len(result.Steps)-1]
+		Expect(last.MeetsSLO).To(BeFalse())
+		Expect(last.TargetQPS).To(Equal(result.SustainableQPS + 100))
+	})
+
+	It("Should stop at the highest rate", func() {
+		server := newServer(http.StatusOK, 0)
+		result, err := Run(ctx, Config{
+			URL:          server.URL,
+			StartQPS:     20,
+			StepQPS:      20,
+			MaxQPS:       50,
+			StepDuration: 200 * time.Millisecond,
+			LatencySLO:   time.Second,
+			Percentile:   99,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.SustainableQPS).To(Equal(50))
+		Expect(result.Steps).To(HaveLen(3))
+		Expect(result.Steps[2].TargetQPS).To(Equal(50))
+	})
+
+	It("Should reuse connections to the target", func() {
+		var (
+			mu    sync.Mutex
+			conns int
+		)
+		// uneven service times return connections to the pool in bursts
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			time.Sleep(time.Duration(rand.IntN(40)) * time.Millisecond)
+		}))
+		server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				mu.Lock()
+				defer mu.Unlock()
+				conns++
+			}
+		}
+		server.Start()
+		DeferCleanup(server.Close)
+
+		result, err := Run(ctx, Config{
+			URL:          server.URL,
+			StartQPS:     400,
+			StepQPS:      100,
+			MaxQPS:       400,
+			StepDuration: 500 * time.Millisecond,
+			LatencySLO:   time.Second,
+			Percentile:   99,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Steps[0].Requests).To(Equal(200))
+		mu.Lock()
+		defer mu.Unlock()
+		// about 8 requests are in flight at 400 QPS; dialing again whenever more than
+		// two connections are idle at once opens about 50
+		Expect(conns).To(BeNumerically("<", 30))
+	})
+
+	It("Should report 0 when even the first rate fails", func() {
+		server := newServer(http.StatusInternalServerError, 0)
+		result, err := Run(ctx, Config{
+			URL:          server.URL,
+			StartQPS:     20,
+			StepQPS:      20,
+			MaxQPS:       100,
+			StepDuration: 200 * time.Millisecond,
+			LatencySLO:   time.Second,
+			Percentile:   99,
+			MaxErrorRate: 0.01,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.SustainableQPS).To(BeZero())
+		Expect(result.Steps).To(HaveLen(1))
+		Expect(result.Steps[0].Errors).To(Equal(result.Steps[0].Requests))
+	})
+
+	It("Should reject an invalid config", func() {
+		_, err := Run(ctx, Config{URL: "http://localhost", StartQPS: 10, StepQPS: 0, MaxQPS: 10})
+		Expect(err).To(MatchError(ContainSubstring("invalid rates")))
+	})
+})
+
+var _ = Describe("Main", func() {
+	It("Should write the result to the termination log", func() {
+		server := newServer(http.StatusOK, 0)
+		terminationLog := filepath.Join(GinkgoT().TempDir(), "termination-log")
+
+		var stdout, stderr bytes.Buffer
+		code := Main([]string{
+			"--url", server.URL,
+			"--start-qps", "10",
+			"--step-qps", "10",
+			"--max-qps", "20",
+			"--step-duration", "200ms",
+			"--termination-log", terminationLog,
+		}, &stdout, &stderr)
+		Expect(code).To(BeZero(), stderr.String())
+
+		data, err := os.ReadFile(terminationLog)
+		Expect(err).NotTo(HaveOccurred())
+		result := &Result{}
+		Expect(json.Unmarshal(data, result)).To(Succeed())
+		Expect(result.SustainableQPS).To(Equal(20))
+		Expect(stdout.String()).To(ContainSubstring(`"sustainableQPS":20`))
+	})
+
+	It("Should fail without a URL", func() {
+		var stdout, stderr bytes.Buffer
+		Expect(Main([]string{"--termination-log", ""}, &stdout, &stderr)).To(Equal(1))
+		Expect(stderr.String()).To(ContainSubstring("url is required"))
+	})
+})
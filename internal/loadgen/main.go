@@ -0,0 +1,81 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Command is the name of the manager subcommand that runs the load generator.
+const Command = "loadgen"
+
+// Main runs the load generator with command line arguments and returns the exit code.
+// The result is printed as JSON and also written to the termination message file, where
+// the controller reads it from the finished pod.
+func Main(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(Command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg := Config{}
+	var terminationLog string
+	fs.StringVar(&cfg.URL, "url", "", "URL requests are sent to.")
+	fs.IntVar(&cfg.StartQPS, "start-qps", 10, "Rate of the first step.")
+	fs.IntVar(&cfg.StepQPS, "step-qps", 10, "Rate added after every step that meets the SLO.")
+	fs.IntVar(&cfg.MaxQPS, "max-qps", 1000, "Highest rate tried.")
+	fs.DurationVar(&cfg.StepDuration, "step-duration", 30*time.Second, "How long each rate is held.")
+	fs.DurationVar(&cfg.LatencySLO, "latency-slo", 100*time.Millisecond, "Highest acceptable latency at --percentile.")
+	fs.Float64Var(&cfg.Percentile, "percentile", 99, "Latency percentile the SLO applies to.")
+	fs.Float64Var(&cfg.MaxErrorRate, "max-error-rate", 0.01, "Highest acceptable share of failed requests.")
+	fs.StringVar(&terminationLog, "termination-log", "/dev/termination-log",
+		"File the JSON result is written to. Leave empty to only print it.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	result, err := Run(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, "loadgen:", err)
+		return 1
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		fmt.Fprintln(stderr, "loadgen:", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, string(data))
+	if terminationLog != "" {
+		// the termination message is limited to 4KiB, keep only the verdict when the steps do not fit
+		if len(data) > 4096 {
+			data, _ = json.Marshal(&Result{SustainableQPS: result.SustainableQPS})
+		}
+		if err := os.WriteFile(terminationLog, data, 0o644); err != nil {
+			fmt.Fprintln(stderr, "loadgen:", err)
+			return 1
+		}
+	}
+	return 0
+}
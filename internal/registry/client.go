@@ -0,0 +1,225 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// manifestMediaTypes are the manifest types accepted when resolving a tag. Indexes come
+// first so multi-arch images resolve to the index digest, like `docker pull` does.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Resolver resolves image tags to content digests.
+type Resolver interface {
+	// Resolve returns the digest ("sha256:...") the image's tag currently points to.
+	// Images that are already pinned to a digest are returned without contacting the registry.
+	Resolve(ctx context.Context, image string, keychain Keychain) (string, error)
+}
+
+// Client is a Resolver that talks to registries over the Docker Registry HTTP API V2.
+type Client struct {
+	// HTTPClient is used for all requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// InsecureRegistries are registry hosts reached over plain HTTP, e.g. a local test registry.
+	InsecureRegistries []string
+}
+
+var _ Resolver = &Client{}
+
+// NewClient returns a Client with a bounded request timeout.
+func NewClient(insecureRegistries ...string) *Client {
+	return &Client{
+		HTTPClient:         &http.Client{Timeout: 30 * time.Second},
+		InsecureRegistries: insecureRegistries,
+	}
+}
+
+// Resolve implements Resolver.
+func (c *Client) Resolve(ctx context.Context, image string, keychain Keychain) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	if !ref.Tagged() {
+		return ref.Digest, nil
+	}
+
+	scheme := "https"
+	if slices.Contains(c.InsecureRegistries, ref.Registry) {
+		scheme = "http"
+	}
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, ref.apiHost(), ref.Repository, ref.Tag)
+	auth, hasAuth := keychain.lookup(ref.Registry)
+
+	resp, err := c.manifest(ctx, http.MethodHead, manifestURL, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		_ = resp.Body.Close()
+
+		authorization := ""
+		switch {
+		case strings.HasPrefix(strings.ToLower(challenge), "bearer "):
+			token, err := c.token(ctx, challenge, ref.Repository, auth, hasAuth)
+			if err != nil {
+				return "", err
+			}
+			authorization = "Bearer " + token
+		case hasAuth:
+			req := &http.Request{Header: http.Header{}}
+			req.SetBasicAuth(auth.Username, auth.Password)
+			authorization = req.Header.Get("Authorization")
+		default:
+			return "", fmt.Errorf("registry %s requires credentials for %s", ref.Registry, image)
+		}
+
+		resp, err = c.manifest(ctx, http.MethodHead, manifestURL, authorization)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		return c.digest(ctx, resp, manifestURL, authorization, image)
+	}
+	defer resp.Body.Close()
+	return c.digest(ctx, resp, manifestURL, "", image)
+}
+
+// digest extracts the digest from a manifest HEAD response. Registries that omit the
+// Docker-Content-Digest header on HEAD are asked for the manifest body, which is hashed.
+func (c *Client) digest(ctx context.Context, resp *http.Response, manifestURL, authorization, image string) (string, error) {
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolve %s: registry returned %s", image, resp.Status)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	get, err := c.manifest(ctx, http.MethodGet, manifestURL, authorization)
+	if err != nil {
+		return "", err
+	}
+	defer get.Body.Close()
+	if get.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolve %s: registry returned %s", image, get.Status)
+	}
+	if digest := get.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+	body, err := io.ReadAll(get.Body)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(body)), nil
+}
+
+func (c *Client) manifest(ctx context.Context, method, manifestURL, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return c.httpClient().Do(req)
+}
+
+// token fetches a pull token for repository from the realm named in a Bearer challenge.
+func (c *Client) token(ctx context.Context, challenge, repository string, auth Auth, hasAuth bool) (string, error) {
+	params := parseChallenge(challenge)
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("bearer challenge without realm: %q", challenge)
+	}
+
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %w", realm, err)
+	}
+	q := u.Query()
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	q.Set("scope", "repository:"+repository+":pull")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasAuth {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch registry token from %s: %s", u.Host, resp.Status)
+	}
+
+	body := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// parseChallenge parses the parameters of a `Bearer realm="...",service="..."` header.
+func parseChallenge(challenge string) map[string]string {
+	params := map[string]string{}
+	_, rest, _ := strings.Cut(challenge, " ")
+	for _, part := range strings.Split(rest, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		params[strings.ToLower(k)] = strings.Trim(v, `"`)
+	}
+	return params
+}
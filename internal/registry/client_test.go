@@ -0,0 +1,154 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeRegistry is a minimal stand-in for a registry: it serves manifests by tag and,
+// when a password is set, requires a bearer token obtained with basic auth.
+type fakeRegistry struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	digests  map[string]string // "repo:tag" -> digest
+	username string
+	password string
+}
+
+func newFakeRegistry() *fakeRegistry {
+	r := &fakeRegistry{digests: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != r.username || pass != r.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		Expect(req.URL.Query().Get("scope")).To(HavePrefix("repository:"))
+		fmt.Fprint(w, `{"token":"t0ken"}`)
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, req *http.Request) {
+		if r.password != "" && req.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake"`, r.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		path := strings.TrimPrefix(req.URL.Path, "/v2/")
+		repo, tag, _ := strings.Cut(path, "/manifests/")
+		r.mu.Lock()
+		digest, ok := r.digests[repo+":"+tag]
+		r.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+	})
+	r.Server = httptest.NewServer(mux)
+	return r
+}
+
+func (r *fakeRegistry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func (r *fakeRegistry) push(repo, tag, digest string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.digests[repo+":"+tag] = digest
+}
+
+var _ = Describe("ParseReference", func() {
+	DescribeTable("splits image references like the docker CLI",
+		func(image, registry, repository, tag, digest string) {
+			ref, err := ParseReference(image)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ref.Registry).To(Equal(registry))
+			Expect(ref.Repository).To(Equal(repository))
+			Expect(ref.Tag).To(Equal(tag))
+			Expect(ref.Digest).To(Equal(digest))
+		},
+		Entry("official image", "nginx", "docker.io", "library/nginx", "latest", ""),
+		Entry("user image with tag", "bitnami/redis:7", "docker.io", "bitnami/redis", "7", ""),
+		Entry("private registry", "hub.autox.tech/library/tomcat:8.0.18-jre8", "hub.autox.tech", "library/tomcat", "8.0.18-jre8", ""),
+		Entry("registry with port", "localhost:5000/app", "localhost:5000", "app", "latest", ""),
+		Entry("pinned", "nginx@sha256:abc", "docker.io", "library/nginx", "", "sha256:abc"),
+	)
+
+	It("pins to a digest without the tag", func() {
+		ref, err := ParseReference("hub.autox.tech/library/tomcat:8")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref.WithDigest("sha256:abc")).To(Equal("hub.autox.tech/library/tomcat@sha256:abc"))
+	})
+})
+
+var _ = Describe("Client", func() {
+	var (
+		ctx      context.Context
+		registry *fakeRegistry
+		client   *Client
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		registry = newFakeRegistry()
+		DeferCleanup(registry.Close)
+		client = NewClient(registry.host())
+	})
+
+	It("resolves a tag and follows when it moves", func() {
+		registry.push("team/web", "v1", "sha256:1111")
+		Expect(client.Resolve(ctx, registry.host()+"/team/web:v1", nil)).To(Equal("sha256:1111"))
+
+		registry.push("team/web", "v1", "sha256:2222")
+		Expect(client.Resolve(ctx, registry.host()+"/team/web:v1", nil)).To(Equal("sha256:2222"))
+	})
+
+	It("returns pinned images without contacting the registry", func() {
+		registry.Close()
+		Expect(client.Resolve(ctx, registry.host()+"/team/web@sha256:3333", nil)).To(Equal("sha256:3333"))
+	})
+
+	It("fails for unknown tags", func() {
+		_, err := client.Resolve(ctx, registry.host()+"/team/web:missing", nil)
+		Expect(err).To(MatchError(ContainSubstring("404")))
+	})
+
+	It("authenticates with credentials from a dockerconfigjson pull secret", func() {
+		registry.username, registry.password = "robot", "s3cret"
+		registry.push("team/web", "v1", "sha256:4444")
+
+		_, err := client.Resolve(ctx, registry.host()+"/team/web:v1", nil)
+		Expect(err).To(HaveOccurred())
+
+		keychain := Keychain{}
+		auth := base64.StdEncoding.EncodeToString([]byte("robot:s3cret"))
+		Expect(keychain.AddDockerConfigJSON([]byte(fmt.Sprintf(`{"auths":{"http://%s":{"auth":"%s"}}}`, registry.host(), auth)))).To(Succeed())
+		Expect(client.Resolve(ctx, registry.host()+"/team/web:v1", keychain)).To(Equal("sha256:4444"))
+	})
+})
@@ -0,0 +1,90 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Auth holds the credentials for one registry.
+type Auth struct {
+	Username string
+	Password string
+}
+
+// Keychain maps registry hosts to credentials. A nil Keychain means anonymous access.
+type Keychain map[string]Auth
+
+// dockerConfigJSON is the content of a kubernetes.io/dockerconfigjson Secret.
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigEntry `json:"auths"`
+}
+
+type dockerConfigEntry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+// AddDockerConfigJSON adds the credentials of a .dockerconfigjson document to the keychain.
+// Entries already in the keychain win, matching the kubelet which uses the first pull secret
+// that has credentials for a registry.
+func (k Keychain) AddDockerConfigJSON(data []byte) error {
+	cfg := dockerConfigJSON{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("parse dockerconfigjson: %w", err)
+	}
+
+	for server, entry := range cfg.Auths {
+		auth := Auth{Username: entry.Username, Password: entry.Password}
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return fmt.Errorf("decode auth for %s: %w", server, err)
+			}
+			auth.Username, auth.Password, _ = strings.Cut(string(decoded), ":")
+		}
+
+		host := normalizeServer(server)
+		if _, ok := k[host]; !ok {
+			k[host] = auth
+		}
+	}
+	return nil
+}
+
+// lookup returns the credentials for registry, if any.
+func (k Keychain) lookup(registry string) (Auth, bool) {
+	auth, ok := k[registry]
+	return auth, ok
+}
+
+// normalizeServer turns the keys used in docker config files ("https://index.docker.io/v1/",
+// "hub.example.com", "https://hub.example.com") into the registry host used by Reference.
+func normalizeServer(server string) string {
+	server = strings.TrimPrefix(server, "https://")
+	server = strings.TrimPrefix(server, "http://")
+	server, _, _ = strings.Cut(server, "/")
+	switch server {
+	case "index.docker.io", dockerHubAPI:
+		return DockerHub
+	}
+	return server
+}
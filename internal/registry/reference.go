@@ -0,0 +1,106 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DockerHub is the registry used for image references without a registry host.
+	DockerHub = "docker.io"
+
+	dockerHubAPI = "registry-1.docker.io"
+)
+
+// Reference is a parsed container image reference.
+type Reference struct {
+	// Name is the image as written without its tag or digest, e.g. "nginx" or "hub.example.com/library/tomcat".
+	Name string
+	// Registry is the registry host, DockerHub when the reference has none.
+	Registry string
+	// Repository is the path within the registry, e.g. "library/nginx".
+	Repository string
+	// Tag is the tag, "latest" when the reference has neither tag nor digest.
+	Tag string
+	// Digest is the digest when the reference is already pinned, e.g. "sha256:...".
+	Digest string
+}
+
+// ParseReference splits an image reference into registry, repository, tag and digest
+// following the same defaulting rules as the docker CLI.
+func ParseReference(image string) (Reference, error) {
+	if image == "" {
+		return Reference{}, fmt.Errorf("empty image reference")
+	}
+
+	ref := Reference{}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !strings.Contains(ref.Digest, ":") {
+			return Reference{}, fmt.Errorf("invalid digest in image reference %q", image)
+		}
+	}
+
+	// only a ":" after the last "/" starts a tag, an earlier one is a registry port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	if name == "" {
+		return Reference{}, fmt.Errorf("invalid image reference %q", image)
+	}
+	ref.Name = name
+
+	first, rest, found := strings.Cut(name, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry = first
+		ref.Repository = rest
+	} else {
+		ref.Registry = DockerHub
+		ref.Repository = name
+		if !found {
+			ref.Repository = "library/" + name
+		}
+	}
+
+	return ref, nil
+}
+
+// Tagged reports whether the reference is resolved through a tag rather than pinned to a digest.
+func (r Reference) Tagged() bool {
+	return r.Digest == ""
+}
+
+// WithDigest returns the image pinned to digest, dropping any tag.
+func (r Reference) WithDigest(digest string) string {
+	return r.Name + "@" + digest
+}
+
+// apiHost returns the host that serves the registry HTTP API.
+func (r Reference) apiHost() string {
+	if r.Registry == DockerHub {
+		return dockerHubAPI
+	}
+	return r.Registry
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultingPolicyKey is the ConfigMap key holding the defaulting policy as YAML.
+const DefaultingPolicyKey = "policy.yaml"
+
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get
+
+// DefaultingPolicy holds the values ElasticWebCustomDefaulter fills into fields the user left unset.
+//
+// Example ConfigMap:
+//
+//	apiVersion: v1
+//	kind: ConfigMap
+//	metadata:
+//	  name: elasticweb-defaulting-policy
+//	data:
+//	  policy.yaml: |
+//	    totalQPS: 1200
+//	    singlePodQPS: 500
+//	    serviceType: ClusterIP
+//	    resources:
+//	      limits: {cpu: 500m, memory: 512Mi}
+//	    labels:
+//	      team: web
+type DefaultingPolicy struct {
+	TotalQPS       *int32                       `json:"totalQPS,omitempty"`
+	SinglePodQPS   *int32                       `json:"singlePodQPS,omitempty"`
+	Resources      *corev1.ResourceRequirements `json:"resources,omitempty"`
+	ReadinessProbe *corev1.Probe                `json:"readinessProbe,omitempty"`
+	LivenessProbe  *corev1.Probe                `json:"livenessProbe,omitempty"`
+	ServiceType    corev1.ServiceType           `json:"serviceType,omitempty"`
+	// Labels are added to the ElasticWeb unless it already has a label with the same key.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// merge overlays the fields set in override onto p. Labels are merged key by key.
+func (p *DefaultingPolicy) merge(override *DefaultingPolicy) {
+	if override.TotalQPS != nil {
+		p.TotalQPS = override.TotalQPS
+	}
+	if override.SinglePodQPS != nil {
+		p.SinglePodQPS = override.SinglePodQPS
+	}
+	if override.Resources != nil {
+		p.Resources = override.Resources
+	}
+	if override.ReadinessProbe != nil {
+		p.ReadinessProbe = override.ReadinessProbe
+	}
+	if override.LivenessProbe != nil {
+		p.LivenessProbe = override.LivenessProbe
+	}
+	if override.ServiceType != "" {
+		p.ServiceType = override.ServiceType
+	}
+	if len(override.Labels) > 0 && p.Labels == nil {
+		p.Labels = map[string]string{}
+	}
+	for k, v := range override.Labels {
+		p.Labels[k] = v
+	}
+}
+
+// DefaultingPolicyLoader reads the defaulting policy from ConfigMaps named Name: the one in
+// Namespace applies cluster wide, one in the ElasticWeb's own namespace overrides it.
+//
+// Client should be the manager's APIReader: edits to the ConfigMaps are picked up by the next
+// admission request without restarting the manager, and the manager does not have to cache,
+// or narrow its cache to, the ConfigMaps other code may read.
+type DefaultingPolicyLoader struct {
+	Client    client.Reader
+	Namespace string
+	Name      string
+}
+
+// Load returns the cluster-wide policy overlaid with the override for namespace.
+// Missing ConfigMaps are not an error, they simply contribute nothing.
+func (l *DefaultingPolicyLoader) Load(ctx context.Context, namespace string) (*DefaultingPolicy, error) {
+	policy := &DefaultingPolicy{}
+
+	namespaces := []string{l.Namespace}
+	if namespace != l.Namespace {
+		namespaces = append(namespaces, namespace)
+	}
+	for _, ns := range namespaces {
+		cm := &corev1.ConfigMap{}
+		err := l.Client.Get(ctx, types.NamespacedName{Namespace: ns, Name: l.Name}, cm)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		override := &DefaultingPolicy{}
+		if err := yaml.UnmarshalStrict([]byte(cm.Data[DefaultingPolicyKey]), override); err != nil {
+			return nil, fmt.Errorf("parse %s in ConfigMap %s/%s: %w", DefaultingPolicyKey, ns, l.Name, err)
+		}
+		policy.merge(override)
+	}
+
+	return policy, nil
+}
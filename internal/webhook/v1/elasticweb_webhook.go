@@ -19,18 +19,29 @@ package v1
 import (
 	"context"
 	"fmt"
+	"net"
+	"slices"
+	"strings"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	elasticwebv1 "elasticweb/api/v1"
+	"elasticweb/internal/activator"
 
 	corev1 "k8s.io/api/core/v1"
 )
@@ -39,13 +50,43 @@ import (
 // log is for logging in this package.
 var elasticweblog = logf.Log.WithName("elasticweb-resource")
 
+// WebhookOptions configures the ElasticWeb webhooks.
+type WebhookOptions struct {
+	// DefaultingPolicyNamespace and DefaultingPolicyName locate the cluster-wide defaulting
+	// policy ConfigMap. ConfigMaps with the same name in other namespaces override it there.
+	// Defaulting uses only the built-in values when DefaultingPolicyName is empty.
+	DefaultingPolicyNamespace string
+	DefaultingPolicyName      string
+
+	// ImagePolicy restricts the images ElasticWebs may run, nil to allow any image.
+	ImagePolicy *ImagePolicy
+}
+
 // SetupElasticWebWebhookWithManager registers the webhook for ElasticWeb in the manager.
-func SetupElasticWebWebhookWithManager(mgr ctrl.Manager) error {
+func SetupElasticWebWebhookWithManager(mgr ctrl.Manager, opts WebhookOptions) error {
+	defaulter := &ElasticWebCustomDefaulter{
+		DefaultTotalQPS:                 1200,
+		DefaultPodSecurityContext:       hardenedPodSecurityContext(),
+		DefaultContainerSecurityContext: hardenedContainerSecurityContext(),
+	}
+	if opts.DefaultingPolicyName != "" {
+		defaulter.PolicyLoader = &DefaultingPolicyLoader{
+			Client:    mgr.GetAPIReader(),
+			Namespace: opts.DefaultingPolicyNamespace,
+			Name:      opts.DefaultingPolicyName,
+		}
+	}
+
+	mgr.GetWebhookServer().Register(ScaleWebhookPath, &webhook.Admission{
+		Handler: &ElasticWebScaleValidator{Client: mgr.GetAPIReader()},
+	})
+
 	return ctrl.NewWebhookManagedBy(mgr).For(&elasticwebv1.ElasticWeb{}).
-		WithValidator(&ElasticWebCustomValidator{}).
-		WithDefaulter(&ElasticWebCustomDefaulter{
-			DefaultTotalQPS: 1200,
+		WithValidator(&ElasticWebCustomValidator{
+			Client:      mgr.GetAPIReader(),
+			ImagePolicy: opts.ImagePolicy,
 		}).
+		WithDefaulter(defaulter).
 		Complete()
 }
 
@@ -59,9 +100,44 @@ func SetupElasticWebWebhookWithManager(mgr ctrl.Manager) error {
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as it is used only for temporary operations and does not need to be deeply copied.
 type ElasticWebCustomDefaulter struct {
-	// TODO(user): Add more fields as needed for defaulting
+	// DefaultTotalQPS and DefaultResoureces are the built-in defaults, used when the
+	// policy loaded by PolicyLoader does not set totalQPS or resources.
 	DefaultTotalQPS   int32
 	DefaultResoureces corev1.ResourceRequirements
+
+	// PolicyLoader loads the ConfigMap based defaulting policy, nil to use only the built-in defaults.
+	PolicyLoader *DefaultingPolicyLoader
+
+	// DefaultPodSecurityContext and DefaultContainerSecurityContext are merged into every
+	// ElasticWeb field by field: only fields the user left unset are filled, so an instance
+	// can relax the policy by setting e.g. readOnlyRootFilesystem: false explicitly.
+	DefaultPodSecurityContext       *corev1.PodSecurityContext
+	DefaultContainerSecurityContext *corev1.SecurityContext
+}
+
+// hardenedPodSecurityContext is the pod-level part of the default hardening policy.
+func hardenedPodSecurityContext() *corev1.PodSecurityContext {
+	return &corev1.PodSecurityContext{
+		RunAsNonRoot: ptr.To(true),
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+// hardenedContainerSecurityContext is the container-level part of the default hardening policy.
+func hardenedContainerSecurityContext() *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		RunAsNonRoot:             ptr.To(true),
+		ReadOnlyRootFilesystem:   ptr.To(true),
+		AllowPrivilegeEscalation: ptr.To(false),
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
 }
 
 var _ webhook.CustomDefaulter = &ElasticWebCustomDefaulter{}
@@ -75,54 +151,131 @@ func (d *ElasticWebCustomDefaulter) Default(ctx context.Context, obj runtime.Obj
 	}
 	elasticweblog.Info("Defaulting for ElasticWeb", "name", elasticweb.GetName())
 
+	policy := d.policy(ctx, elasticweb.Namespace)
+
 	if elasticweb.Spec.TotalQPS == nil {
-		elasticweb.Spec.TotalQPS = new(int32)
-		*elasticweb.Spec.TotalQPS = d.DefaultTotalQPS
+		elasticweb.Spec.TotalQPS = ptr.To(*policy.TotalQPS)
 		elasticweblog.Info("a. TotalQPS is nil, set default value now", "TotalQPS", *elasticweb.Spec.TotalQPS)
 	} else {
 		elasticweblog.Info("b. TotalQPS exists", "TotalQPS", *elasticweb.Spec.TotalQPS)
 	}
 
-	// for i1, v1 := range elasticweb.Spec.Deploy {
-	// 	if v1.Resources == nil {
-	// 		// Resources: corev1.ResourceRequirements{
-	// 		// 	Requests: corev1.ResourceList{
-	// 		// 		"cpu":    resource.MustParse(CPU_REQUEST),
-	// 		// 		"memory": resource.MustParse(MEM_REQUEST),
-	// 		// 	},
-	// 		// 	Limits: corev1.ResourceList{
-	// 		// 		"cpu":    resource.MustParse(CPU_LIMIT),
-	// 		// 		"memory": resource.MustParse(MEM_LIMIT),
-	// 		// 	},
-	// 		// },
-	// 		*(elasticweb.Spec.Deploy[i1].Resources) = corev1.ResourceRequirements{
-	// 			Requests: corev1.ResourceList{
-	// 				"cpu":    resource.MustParse("1"),
-	// 				"memory": resource.MustParse("2Gi"),
-	// 			},
-	// 			Limits: corev1.ResourceList{
-	// 				"cpu":    resource.MustParse("1"),
-	// 				"memory": resource.MustParse("2Gi"),
-	// 			},
-	// 		}
-	// 	} else {
-	// 		if len(v1.Resources.Requests) == 0 {
-	// 			*(&elasticweb.Spec.Deploy[i1].Resources.Requests) = *(&elasticweb.Spec.Deploy[i1].Resources.Limits)
-	// 		} else {
-	// 			*(&elasticweb.Spec.Deploy[i1].Resources.Limits) = *(&elasticweb.Spec.Deploy[i1].Resources.Requests)
-	// 		}
-	// 	}
-	// }
-
-	// TODO(user): fill in your defaulting logic.
+	if elasticweb.Spec.SinglePodQPS == nil && policy.SinglePodQPS != nil {
+		elasticweb.Spec.SinglePodQPS = ptr.To(*policy.SinglePodQPS)
+	}
+
+	if elasticweb.Spec.Service.Type == "" && policy.ServiceType != "" {
+		elasticweb.Spec.Service.Type = string(policy.ServiceType)
+	}
+
+	for i := range elasticweb.Spec.Deploy {
+		deploy := &elasticweb.Spec.Deploy[i]
+		if deploy.Resources == nil && policy.Resources != nil {
+			deploy.Resources = policy.Resources.DeepCopy()
+		}
+		if deploy.ReadinessProbe == nil && policy.ReadinessProbe != nil {
+			deploy.ReadinessProbe = policy.ReadinessProbe.DeepCopy()
+		}
+		if deploy.LivenessProbe == nil && policy.LivenessProbe != nil {
+			deploy.LivenessProbe = policy.LivenessProbe.DeepCopy()
+		}
+	}
+
+	for k, v := range policy.Labels {
+		if _, ok := elasticweb.Labels[k]; ok {
+			continue
+		}
+		if elasticweb.Labels == nil {
+			elasticweb.Labels = map[string]string{}
+		}
+		elasticweb.Labels[k] = v
+	}
+
+	if d.DefaultPodSecurityContext != nil {
+		if elasticweb.Spec.SecurityContext == nil {
+			elasticweb.Spec.SecurityContext = &corev1.PodSecurityContext{}
+		}
+		defaultPodSecurityContext(elasticweb.Spec.SecurityContext, d.DefaultPodSecurityContext)
+	}
+	if d.DefaultContainerSecurityContext != nil {
+		for i := range elasticweb.Spec.Deploy {
+			if elasticweb.Spec.Deploy[i].SecurityContext == nil {
+				elasticweb.Spec.Deploy[i].SecurityContext = &corev1.SecurityContext{}
+			}
+			defaultContainerSecurityContext(elasticweb.Spec.Deploy[i].SecurityContext, d.DefaultContainerSecurityContext)
+		}
+	}
 
 	return nil
 }
 
-// TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
+// policy returns the built-in defaults overlaid with the ConfigMap policy for namespace.
+// A policy that cannot be loaded is logged and skipped, so a broken ConfigMap does not
+// block every ElasticWeb create and update in the cluster.
+func (d *ElasticWebCustomDefaulter) policy(ctx context.Context, namespace string) *DefaultingPolicy {
+	policy := &DefaultingPolicy{
+		TotalQPS: ptr.To(d.DefaultTotalQPS),
+	}
+	if len(d.DefaultResoureces.Requests) > 0 || len(d.DefaultResoureces.Limits) > 0 {
+		policy.Resources = d.DefaultResoureces.DeepCopy()
+	}
+
+	if d.PolicyLoader == nil {
+		return policy
+	}
+	loaded, err := d.PolicyLoader.Load(ctx, namespace)
+	if err != nil {
+		elasticweblog.Error(err, "load defaulting policy error, use built-in defaults", "namespace", namespace)
+		return policy
+	}
+	policy.merge(loaded)
+	return policy
+}
+
+// defaultPodSecurityContext fills the unset fields of sc from def.
+func defaultPodSecurityContext(sc, def *corev1.PodSecurityContext) {
+	if sc.RunAsNonRoot == nil && def.RunAsNonRoot != nil {
+		sc.RunAsNonRoot = ptr.To(*def.RunAsNonRoot)
+	}
+	if sc.RunAsUser == nil && def.RunAsUser != nil {
+		sc.RunAsUser = ptr.To(*def.RunAsUser)
+	}
+	if sc.RunAsGroup == nil && def.RunAsGroup != nil {
+		sc.RunAsGroup = ptr.To(*def.RunAsGroup)
+	}
+	if sc.FSGroup == nil && def.FSGroup != nil {
+		sc.FSGroup = ptr.To(*def.FSGroup)
+	}
+	if sc.SeccompProfile == nil && def.SeccompProfile != nil {
+		sc.SeccompProfile = def.SeccompProfile.DeepCopy()
+	}
+}
+
+// defaultContainerSecurityContext fills the unset fields of sc from def.
+func defaultContainerSecurityContext(sc, def *corev1.SecurityContext) {
+	if sc.RunAsNonRoot == nil && def.RunAsNonRoot != nil {
+		sc.RunAsNonRoot = ptr.To(*def.RunAsNonRoot)
+	}
+	if sc.RunAsUser == nil && def.RunAsUser != nil {
+		sc.RunAsUser = ptr.To(*def.RunAsUser)
+	}
+	if sc.ReadOnlyRootFilesystem == nil && def.ReadOnlyRootFilesystem != nil {
+		sc.ReadOnlyRootFilesystem = ptr.To(*def.ReadOnlyRootFilesystem)
+	}
+	if sc.AllowPrivilegeEscalation == nil && def.AllowPrivilegeEscalation != nil && !ptr.Deref(sc.Privileged, false) {
+		sc.AllowPrivilegeEscalation = ptr.To(*def.AllowPrivilegeEscalation)
+	}
+	if sc.Capabilities == nil && def.Capabilities != nil {
+		sc.Capabilities = def.Capabilities.DeepCopy()
+	}
+	if sc.SeccompProfile == nil && def.SeccompProfile != nil {
+		sc.SeccompProfile = def.SeccompProfile.DeepCopy()
+	}
+}
+
 // NOTE: The 'path' attribute must follow a specific pattern and should not be modified directly here.
 // Modifying the path for an invalid path can cause API server errors; failing to locate the webhook.
-// +kubebuilder:webhook:path=/validate-elasticweb-com-bolingcavalry-v1-elasticweb,mutating=false,failurePolicy=fail,sideEffects=None,groups=elasticweb.com.bolingcavalry,resources=elasticwebs,verbs=create;update,versions=v1,name=velasticweb-v1.kb.io,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-elasticweb-com-bolingcavalry-v1-elasticweb,mutating=false,failurePolicy=fail,sideEffects=None,groups=elasticweb.com.bolingcavalry,resources=elasticwebs,verbs=create;update;delete,versions=v1,name=velasticweb-v1.kb.io,admissionReviewVersions=v1
 
 // ElasticWebCustomValidator struct is responsible for validating the ElasticWeb resource
 // when it is created, updated, or deleted.
@@ -130,7 +283,12 @@ func (d *ElasticWebCustomDefaulter) Default(ctx context.Context, obj runtime.Obj
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as this struct is used only for temporary operations and does not need to be deeply copied.
 type ElasticWebCustomValidator struct {
-	//TODO(user): Add more fields as needed for validation
+	// Client reads the ElasticWebQuotas and ElasticWebs of a namespace to enforce quotas
+	// and keep the Deployment names of ElasticWebs apart.
+	Client client.Reader
+
+	// ImagePolicy restricts the images an ElasticWeb may run, nil to allow any image.
+	ImagePolicy *ImagePolicy
 }
 
 var _ webhook.CustomValidator = &ElasticWebCustomValidator{}
@@ -143,9 +301,21 @@ func (v *ElasticWebCustomValidator) ValidateCreate(ctx context.Context, obj runt
 	}
 	elasticweblog.Info("Validation for ElasticWeb upon creation", "name", elasticweb.GetName())
 
-	// TODO(user): fill in your validation logic upon object creation.
+	allErrs := validateElasticWebSpec(elasticweb)
+	imageErrs, warnings := v.ImagePolicy.validate(nil, elasticweb)
+	allErrs = append(allErrs, imageErrs...)
+	quotaErrs, err := validateQuota(ctx, v.Client, nil, elasticweb)
+	if err != nil {
+		return nil, err
+	}
+	allErrs = append(allErrs, quotaErrs...)
+	nameErrs, err := validateDeploymentNames(ctx, v.Client, nil, elasticweb)
+	if err != nil {
+		return nil, err
+	}
+	allErrs = append(allErrs, nameErrs...)
 
-	return nil, validateElasticWeb(elasticweb)
+	return warnings, toInvalidError(elasticweb, allErrs)
 }
 
 // ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type ElasticWeb.
@@ -154,11 +324,36 @@ func (v *ElasticWebCustomValidator) ValidateUpdate(ctx context.Context, oldObj,
 	if !ok {
 		return nil, fmt.Errorf("expected a ElasticWeb object for the newObj but got %T", newObj)
 	}
+	oldElasticweb, ok := oldObj.(*elasticwebv1.ElasticWeb)
+	if !ok {
+		return nil, fmt.Errorf("expected a ElasticWeb object for the oldObj but got %T", oldObj)
+	}
 	elasticweblog.Info("Validation for ElasticWeb upon update", "name", elasticweb.GetName())
 
-	// TODO(user): fill in your validation logic upon object update.
+	// the controller removes finalizers from an ElasticWeb being deleted; a spec that became invalid
+	// under a newer policy, quota or webhook version must not keep it from going away
+	if elasticweb.DeletionTimestamp != nil && equality.Semantic.DeepEqual(oldElasticweb.Spec, elasticweb.Spec) {
+		return nil, nil
+	}
 
-	return nil, validateElasticWeb(elasticweb)
+	allErrs := validateElasticWebSpec(elasticweb)
+	updateErrs, warnings := validateElasticWebUpdate(oldElasticweb, elasticweb)
+	allErrs = append(allErrs, updateErrs...)
+	imageErrs, imageWarnings := v.ImagePolicy.validate(oldElasticweb, elasticweb)
+	allErrs = append(allErrs, imageErrs...)
+	warnings = append(warnings, imageWarnings...)
+	quotaErrs, err := validateQuota(ctx, v.Client, oldElasticweb, elasticweb)
+	if err != nil {
+		return nil, err
+	}
+	allErrs = append(allErrs, quotaErrs...)
+	nameErrs, err := validateDeploymentNames(ctx, v.Client, oldElasticweb, elasticweb)
+	if err != nil {
+		return nil, err
+	}
+	allErrs = append(allErrs, nameErrs...)
+
+	return warnings, toInvalidError(elasticweb, allErrs)
 }
 
 // ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type ElasticWeb.
@@ -169,29 +364,977 @@ func (v *ElasticWebCustomValidator) ValidateDelete(ctx context.Context, obj runt
 	}
 	elasticweblog.Info("Validation for ElasticWeb upon deletion", "name", elasticweb.GetName())
 
-	// TODO(user): fill in your validation logic upon object deletion.
+	if !elasticweb.Spec.DeletionProtection {
+		return nil, nil
+	}
+	if elasticweb.Annotations[elasticwebv1.ConfirmDeleteAnnotation] == "true" {
+		return admission.Warnings{"deleting protected ElasticWeb " + elasticweb.Name + " as confirmed by annotation"}, nil
+	}
 
-	return nil, nil
+	var reasons []string
+	if elasticweb.Spec.TotalQPS != nil && *elasticweb.Spec.TotalQPS > 0 {
+		reasons = append(reasons, fmt.Sprintf("totalQPS is %d", *elasticweb.Spec.TotalQPS))
+	}
+	// status.readyReplicas sums the ready pods of every variant Deployment, or of every
+	// member cluster when the ElasticWeb is placed from a hub
+	if elasticweb.Status.ReadyReplicas > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d pods are ready", elasticweb.Status.ReadyReplicas))
+	}
+	if len(reasons) == 0 {
+		return nil, nil
+	}
+
+	return nil, apierrors.NewForbidden(
+		schema.GroupResource{Group: elasticwebv1.GroupVersion.Group, Resource: "elasticwebs"},
+		elasticweb.Name,
+		fmt.Errorf("deletion protection is enabled and %s; set the %s: \"true\" annotation to confirm",
+			strings.Join(reasons, ", "), elasticwebv1.ConfirmDeleteAnnotation))
+}
+
+// toInvalidError aggregates every problem found into one Invalid error, so a user fixing
+// a manifest sees all of them at once.
+func toInvalidError(r *elasticwebv1.ElasticWeb, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		elasticweblog.Info("e. ElasticWeb is valid")
+		return nil
+	}
+
+	elasticweblog.Info("c. Invalid ElasticWeb", "errors", allErrs.ToAggregate().Error())
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "elasticweb.com.bolingcavalry", Kind: "ElasticWeb"},
+		r.Name,
+		allErrs)
 }
 
-func validateElasticWeb(r *elasticwebv1.ElasticWeb) error {
+// maxTotalQPSDecreasePercent is how much totalQPS may shrink in a single update without
+// the allow-disruptive-update annotation.
+const maxTotalQPSDecreasePercent = 50
+
+// validateElasticWebUpdate compares the old and new object. Changes the controller cannot
+// roll out safely are rejected; risky but allowed changes are returned as warnings.
+func validateElasticWebUpdate(oldObj, newObj *elasticwebv1.ElasticWeb) (field.ErrorList, admission.Warnings) {
 	var allErrs field.ErrorList
+	var warnings admission.Warnings
+	specPath := field.NewPath("spec")
+	allowDisruptive := newObj.Annotations[elasticwebv1.AllowDisruptiveUpdateAnnotation] == "true"
 
-	if *r.Spec.SinglePodQPS > 1000 {
-		elasticweblog.Info("c. Invalid SinglePodQPS")
+	// the controller matches containers by name, so a rename looks like removing one container and adding another
+	oldNames, newNames := sets.New[string](), sets.New[string]()
+	for _, d := range oldObj.Spec.Deploy {
+		oldNames.Insert(d.Name)
+	}
+	for _, d := range newObj.Spec.Deploy {
+		newNames.Insert(d.Name)
+	}
+	// variants run in their own Deployments and can come and go, which is how an experiment starts and ends
+	variants := oldObj.IsVariantMode() && newObj.IsVariantMode()
+	switch {
+	case !oldNames.Equal(newNames) && variants:
+		warnings = append(warnings, fmt.Sprintf("variants change from %v to %v, Deployments of removed variants are deleted",
+			sets.List(oldNames), sets.List(newNames)))
+	case !oldNames.Equal(newNames):
+		// sidecars can come and go, but the container the Service sends traffic to must not vanish by mistake
+		if serving := getServingContainer(oldObj); serving != "" && !newNames.Has(serving) && !allowDisruptive {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("deploy"),
+				fmt.Sprintf("container %s serves the Service and is renamed or removed; set the %s: \"true\" annotation to confirm",
+					serving, elasticwebv1.AllowDisruptiveUpdateAnnotation)))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("containers change from %v to %v, all pods are replaced",
+				sets.List(oldNames), sets.List(newNames)))
+		}
+	}
 
-		err := field.Invalid(field.NewPath("spec").Child("singlePodQPS"),
-			*r.Spec.SinglePodQPS,
-			"d. must be less than 1000")
+	// switching the mode replaces the Deployment(s), every pod is recreated at once
+	if oldObj.IsVariantMode() != newObj.IsVariantMode() && !allowDisruptive {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("deployMode"),
+			fmt.Sprintf("changing the deploy mode recreates all pods at once; set the %s: \"true\" annotation to confirm",
+				elasticwebv1.AllowDisruptiveUpdateAnnotation)))
+	}
 
-		allErrs = append(allErrs, err)
+	// the controller creates PVCs from claimTemplate once and never updates them
+	for i, nv := range newObj.Spec.Volumes {
+		for _, ov := range oldObj.Spec.Volumes {
+			if ov.Name == nv.Name && ov.ClaimTemplate != nil && nv.ClaimTemplate != nil &&
+				!equality.Semantic.DeepEqual(ov.ClaimTemplate.Spec, nv.ClaimTemplate.Spec) {
+				allErrs = append(allErrs, field.Forbidden(specPath.Child("volumes").Index(i).Child("claimTemplate", "spec"),
+					"field is immutable"))
+			}
+		}
+	}
 
-		return apierrors.NewInvalid(
-			schema.GroupKind{Group: "elasticweb.com.bolingcavalry", Kind: "ElasticWeb"},
-			r.Name,
-			allErrs)
-	} else {
-		elasticweblog.Info("e. SinglePodQPS is valid")
-		return nil
+	// leaving LoadBalancer releases the external address, clients pointing at it break
+	oldType, newType := oldObj.Spec.Service.Type, newObj.Spec.Service.Type
+	if oldType != newType {
+		switch {
+		case oldType == string(corev1.ServiceTypeLoadBalancer) && !allowDisruptive:
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("service", "type"),
+				fmt.Sprintf("changing the type from LoadBalancer releases the external address; set the %s: \"true\" annotation to confirm",
+					elasticwebv1.AllowDisruptiveUpdateAnnotation)))
+		default:
+			warnings = append(warnings, fmt.Sprintf("spec.service.type changes from %s to %s", oldType, newType))
+		}
+	}
+
+	// clusterIP is immutable, the controller deletes and recreates the Service
+	if oldObj.Spec.Service.IsHeadless() != newObj.Spec.Service.IsHeadless() && !allowDisruptive {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("service", "clusterIP"),
+			fmt.Sprintf("changing clusterIP recreates the Service and drops its connections; set the %s: \"true\" annotation to confirm",
+				elasticwebv1.AllowDisruptiveUpdateAnnotation)))
+	}
+
+	// a hub ElasticWeb never ran local pods, the controller does not clean up local resources when placement is added;
+	// removing placement deletes the ElasticWebs on every member cluster before the pods start here
+	switch {
+	case oldObj.Spec.Placement == nil && newObj.Spec.Placement != nil:
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("placement"),
+			"can only be set when the ElasticWeb is created; recreate the ElasticWeb instead"))
+	case oldObj.Spec.Placement != nil && newObj.Spec.Placement == nil && !allowDisruptive:
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("placement"),
+			fmt.Sprintf("removing placement deletes the ElasticWebs on all member clusters; set the %s: \"true\" annotation to confirm",
+				elasticwebv1.AllowDisruptiveUpdateAnnotation)))
+	}
+
+	if oldObj.Spec.TotalQPS != nil && newObj.Spec.TotalQPS != nil {
+		oldQPS, newQPS := int64(*oldObj.Spec.TotalQPS), int64(*newObj.Spec.TotalQPS)
+		if newQPS < oldQPS && (oldQPS-newQPS)*100 > oldQPS*maxTotalQPSDecreasePercent {
+			if !allowDisruptive {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("totalQPS"), newQPS,
+					fmt.Sprintf("cannot shrink by more than %d%% in one update (was %d); scale down in steps or set the %s: \"true\" annotation",
+						maxTotalQPSDecreasePercent, oldQPS, elasticwebv1.AllowDisruptiveUpdateAnnotation)))
+			} else {
+				warnings = append(warnings, fmt.Sprintf("spec.totalQPS shrinks from %d to %d", oldQPS, newQPS))
+			}
+		}
+	}
+
+	if oldObj.Spec.SinglePodQPS != nil && newObj.Spec.SinglePodQPS != nil && *oldObj.Spec.SinglePodQPS != *newObj.Spec.SinglePodQPS {
+		warnings = append(warnings, fmt.Sprintf("spec.singlePodQPS changes from %d to %d, the replica count will be recalculated",
+			*oldObj.Spec.SinglePodQPS, *newObj.Spec.SinglePodQPS))
+	}
+
+	for _, od := range oldObj.Spec.Deploy {
+		for _, nd := range newObj.Spec.Deploy {
+			if od.Name == nd.Name && od.Image != nd.Image {
+				warnings = append(warnings, fmt.Sprintf("image of container %s changes from %s to %s, pods will be rolled",
+					nd.Name, od.Image, nd.Image))
+			}
+		}
+	}
+
+	return allErrs, warnings
+}
+
+// validateElasticWebSpec validates the object on its own, without looking at other objects.
+func validateElasticWebSpec(r *elasticwebv1.ElasticWeb) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	// the Service and the Deployment are named after the ElasticWeb, and Service names must be DNS-1035 labels
+	for _, msg := range validation.IsDNS1035Label(r.Name) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "name"), r.Name, msg))
+	}
+
+	allErrs = append(allErrs, validateQPS(&r.Spec, specPath)...)
+	allErrs = append(allErrs, validateDeployMode(&r.Spec, specPath)...)
+	allErrs = append(allErrs, validateDeploy(r.Spec.Deploy, r.IsVariantMode(), specPath.Child("deploy"))...)
+	allErrs = append(allErrs, validateService(&r.Spec.Service, r.Spec.Deploy, r.IsVariantMode(), specPath.Child("service"))...)
+	if r.Spec.Route != nil {
+		allErrs = append(allErrs, validateRoute(r, specPath.Child("route"))...)
+	}
+	if r.Spec.NetworkPolicy != nil {
+		allErrs = append(allErrs, validateNetworkPolicy(r.Spec.NetworkPolicy, specPath.Child("networkPolicy"))...)
+	}
+	if r.Spec.Placement != nil {
+		allErrs = append(allErrs, validatePlacement(r, specPath.Child("placement"))...)
+	}
+	allErrs = append(allErrs, validateVolumes(r.Spec.Volumes, r.Spec.Deploy, specPath)...)
+	allErrs = append(allErrs, validatePrivileged(r, specPath)...)
+	allErrs = append(allErrs, validateServiceAccount(r, specPath)...)
+
+	return allErrs
+}
+
+// maxSinglePodQPS is the upper bound for spec.singlePodQPS.
+const maxSinglePodQPS = 1000
+
+// Bounds of spec.capacityPolicy, kept in sync with the CRD schema.
+const (
+	maxHeadroomPercent = 1000
+	maxSparePods       = 100
+	maxZones           = 16
+)
+
+func validateQPS(spec *elasticwebv1.ElasticWebSpec, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	singlePodQPSPath := specPath.Child("singlePodQPS")
+	switch {
+	case spec.SinglePodQPS == nil:
+		allErrs = append(allErrs, field.Required(singlePodQPSPath, ""))
+	case *spec.SinglePodQPS <= 0:
+		allErrs = append(allErrs, field.Invalid(singlePodQPSPath, *spec.SinglePodQPS, "must be greater than 0"))
+	case *spec.SinglePodQPS > maxSinglePodQPS:
+		allErrs = append(allErrs, field.Invalid(singlePodQPSPath, *spec.SinglePodQPS,
+			fmt.Sprintf("must be less than or equal to %d", maxSinglePodQPS)))
+	}
+
+	// totalQPS 0 is allowed and means no Deployment is needed
+	totalQPSPath := specPath.Child("totalQPS")
+	switch {
+	case spec.TotalQPS == nil:
+		allErrs = append(allErrs, field.Required(totalQPSPath, ""))
+	case *spec.TotalQPS < 0:
+		allErrs = append(allErrs, field.Invalid(totalQPSPath, *spec.TotalQPS, "must be greater than or equal to 0"))
+	case *spec.TotalQPS > 0 && spec.SinglePodQPS != nil && *spec.TotalQPS < *spec.SinglePodQPS:
+		allErrs = append(allErrs, field.Invalid(totalQPSPath, *spec.TotalQPS, "must be 0 or at least singlePodQPS"))
+	}
+
+	// replicas is usually written through the scale subresource and overrides the QPS arithmetic
+	if spec.Replicas != nil && *spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), *spec.Replicas, "must be greater than or equal to 0"))
+	}
+
+	if policy := spec.CapacityPolicy; policy != nil {
+		policyPath := specPath.Child("capacityPolicy")
+		if policy.HeadroomPercent < 0 || policy.HeadroomPercent > maxHeadroomPercent {
+			allErrs = append(allErrs, field.Invalid(policyPath.Child("headroomPercent"), policy.HeadroomPercent,
+				fmt.Sprintf("must be between 0 and %d", maxHeadroomPercent)))
+		}
+		if policy.SparePods < 0 || policy.SparePods > maxSparePods {
+			allErrs = append(allErrs, field.Invalid(policyPath.Child("sparePods"), policy.SparePods,
+				fmt.Sprintf("must be between 0 and %d", maxSparePods)))
+		}
+		if policy.Zones < 0 || policy.Zones > maxZones {
+			allErrs = append(allErrs, field.Invalid(policyPath.Child("zones"), policy.Zones,
+				fmt.Sprintf("must be between 0 and %d", maxZones)))
+		}
+		if policy.ZoneTopologyKey != "" {
+			for _, msg := range validation.IsQualifiedName(policy.ZoneTopologyKey) {
+				allErrs = append(allErrs, field.Invalid(policyPath.Child("zoneTopologyKey"), policy.ZoneTopologyKey, msg))
+			}
+		}
+	}
+
+	// an idle period of 0 would scale the ElasticWeb down right after every wake up
+	if spec.Idle != nil && spec.Idle.After.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("idle", "after"), spec.Idle.After.Duration.String(), "must be greater than 0"))
+	}
+
+	// the activator answers every Service port with its HTTP proxy, one activator port per Service port
+	if spec.Idle != nil {
+		if len(spec.Service.Ports) > activator.MaxPorts {
+			allErrs = append(allErrs, field.TooMany(specPath.Child("service", "ports"), len(spec.Service.Ports), activator.MaxPorts))
+		}
+		for i, p := range spec.Service.Ports {
+			if p.GetProtocol() != corev1.ProtocolTCP {
+				allErrs = append(allErrs, field.Forbidden(specPath.Child("service", "ports").Index(i).Child("protocol"),
+					"must be TCP when spec.idle is set, the activator only proxies HTTP"))
+			}
+		}
+	}
+
+	if calibration := spec.Calibration; calibration != nil {
+		allErrs = append(allErrs, validateCalibration(calibration, specPath.Child("calibration"))...)
+		// the load test sends HTTP requests to the first Service port
+		if len(spec.Service.Ports) > 0 && spec.Service.Ports[0].GetProtocol() != corev1.ProtocolTCP {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("service", "ports").Index(0).Child("protocol"),
+				"must be TCP when spec.calibration is set, the load test sends HTTP requests to it"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateCalibration checks the load test parameters. Zero values are left to the controller defaults;
+// the load test never goes beyond maxSinglePodQPS since a higher result could not be applied.
+func validateCalibration(calibration *elasticwebv1.ElasticWebSpecCalibration, calibrationPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if calibration.Path != "" && !strings.HasPrefix(calibration.Path, "/") {
+		allErrs = append(allErrs, field.Invalid(calibrationPath.Child("path"), calibration.Path, "must start with /"))
+	}
+	if calibration.LatencySLO != nil && calibration.LatencySLO.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(calibrationPath.Child("latencySLO"), calibration.LatencySLO.Duration.String(), "must be greater than 0"))
+	}
+	if calibration.Percentile < 0 || calibration.Percentile > 100 {
+		allErrs = append(allErrs, field.Invalid(calibrationPath.Child("percentile"), calibration.Percentile, "must be between 1 and 100"))
+	}
+	if calibration.MaxQPS < 0 || calibration.MaxQPS > maxSinglePodQPS {
+		allErrs = append(allErrs, field.Invalid(calibrationPath.Child("maxQPS"), calibration.MaxQPS,
+			fmt.Sprintf("must be between 1 and %d", maxSinglePodQPS)))
+	}
+	if calibration.StepQPS < 0 {
+		allErrs = append(allErrs, field.Invalid(calibrationPath.Child("stepQPS"), calibration.StepQPS, "must be greater than 0"))
+	}
+	if calibration.StepDuration != nil && calibration.StepDuration.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(calibrationPath.Child("stepDuration"), calibration.StepDuration.Duration.String(), "must be greater than 0"))
+	}
+
+	return allErrs
+}
+
+// maxVariantWeight is the upper bound for spec.deploy[].weight.
+const maxVariantWeight = 1000
+
+// supportedDeployModes are the values of spec.deployMode, empty means Containers.
+var supportedDeployModes = []elasticwebv1.DeployMode{elasticwebv1.DeployModeContainers, elasticwebv1.DeployModeVariants}
+
+// validateDeployMode checks spec.deployMode and the variant weights. Weights only mean
+// something when every entry gets its own Deployment, and at least one variant must run.
+func validateDeployMode(spec *elasticwebv1.ElasticWebSpec, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if spec.DeployMode != "" && !slices.Contains(supportedDeployModes, spec.DeployMode) {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("deployMode"), spec.DeployMode, supportedDeployModes))
+	}
+
+	variants := spec.DeployMode == elasticwebv1.DeployModeVariants
+	running := false
+	for i, d := range spec.Deploy {
+		if d.Weight == nil {
+			running = true
+			continue
+		}
+		weightPath := specPath.Child("deploy").Index(i).Child("weight")
+		switch {
+		case !variants:
+			allErrs = append(allErrs, field.Forbidden(weightPath, "can only be set when spec.deployMode is Variants"))
+		case *d.Weight < 0 || *d.Weight > maxVariantWeight:
+			allErrs = append(allErrs, field.Invalid(weightPath, *d.Weight, fmt.Sprintf("must be between 0 and %d", maxVariantWeight)))
+		case *d.Weight > 0:
+			running = true
+		}
+	}
+	if variants && len(spec.Deploy) > 0 && !running {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("deploy"), len(spec.Deploy), "at least one variant must have a weight greater than 0"))
 	}
+
+	return allErrs
+}
+
+// deploymentNames returns the names of the Deployments an ElasticWeb may own: its own name,
+// used in Containers mode, and <name>-<variant> for every variant in Variants mode.
+func deploymentNames(r *elasticwebv1.ElasticWeb) []string {
+	names := []string{r.Name}
+	if r.IsVariantMode() {
+		for i := range r.Spec.Deploy {
+			names = append(names, r.VariantName(&r.Spec.Deploy[i]))
+		}
+	}
+	return names
+}
+
+// validateDeploymentNames checks that the Deployments of newObj cannot collide with those of
+// another ElasticWeb in the namespace, e.g. variant canary of web and an ElasticWeb named
+// web-canary. oldObj is nil on create; updates that keep the names are not checked again.
+func validateDeploymentNames(ctx context.Context, c client.Reader, oldObj, newObj *elasticwebv1.ElasticWeb) (field.ErrorList, error) {
+	var allErrs field.ErrorList
+	names := deploymentNames(newObj)
+	if c == nil || (oldObj != nil && slices.Equal(deploymentNames(oldObj), names)) {
+		return allErrs, nil
+	}
+
+	elasticwebs := &elasticwebv1.ElasticWebList{}
+	if err := c.List(ctx, elasticwebs, client.InNamespace(newObj.Namespace)); err != nil {
+		return nil, fmt.Errorf("list ElasticWebs: %w", err)
+	}
+	for i := range elasticwebs.Items {
+		other := &elasticwebs.Items[i]
+		if other.Name == newObj.Name {
+			continue
+		}
+		otherNames := deploymentNames(other)
+		for j, name := range names {
+			if !slices.Contains(otherNames, name) {
+				continue
+			}
+			// names[0] is the ElasticWeb's own name, the rest follow spec.deploy
+			namePath := field.NewPath("metadata", "name")
+			if j > 0 {
+				namePath = field.NewPath("spec", "deploy").Index(j - 1).Child("name")
+			}
+			allErrs = append(allErrs, field.Forbidden(namePath,
+				fmt.Sprintf("Deployment %s would also belong to ElasticWeb %s", name, other.Name)))
+		}
+	}
+	return allErrs, nil
+}
+
+// validateDeploy checks the containers. All containers share the pod network namespace,
+// so container port numbers and names must be unique across the whole list. In Variants
+// mode every entry runs in its own pods and only needs unique ports within itself.
+func validateDeploy(deploy []elasticwebv1.ElasticWebSpecDeploy, variants bool, deployPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(deploy) == 0 {
+		return append(allErrs, field.Required(deployPath, "at least one container is required"))
+	}
+
+	supportedPullPolicies := []corev1.PullPolicy{corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever}
+	containerNames := sets.New[string]()
+	portNames := sets.New[string]()
+	portNumbers := sets.New[string]()
+	for i, d := range deploy {
+		containerPath := deployPath.Index(i)
+		if variants {
+			portNames, portNumbers = sets.New[string](), sets.New[string]()
+		}
+
+		if d.Name == "" {
+			allErrs = append(allErrs, field.Required(containerPath.Child("name"), ""))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(d.Name) {
+				allErrs = append(allErrs, field.Invalid(containerPath.Child("name"), d.Name, msg))
+			}
+			if containerNames.Has(d.Name) {
+				allErrs = append(allErrs, field.Duplicate(containerPath.Child("name"), d.Name))
+			}
+			containerNames.Insert(d.Name)
+		}
+
+		if d.Image == "" {
+			allErrs = append(allErrs, field.Required(containerPath.Child("image"), ""))
+		}
+
+		if d.PullPolicy != "" && !slices.Contains(supportedPullPolicies, d.PullPolicy) {
+			allErrs = append(allErrs, field.NotSupported(containerPath.Child("pullPolicy"), d.PullPolicy, supportedPullPolicies))
+		}
+
+		for j, p := range d.Ports {
+			portPath := containerPath.Child("ports").Index(j)
+
+			if p.Name != "" {
+				for _, msg := range validation.IsValidPortName(p.Name) {
+					allErrs = append(allErrs, field.Invalid(portPath.Child("name"), p.Name, msg))
+				}
+				if portNames.Has(p.Name) {
+					allErrs = append(allErrs, field.Duplicate(portPath.Child("name"), p.Name))
+				}
+				portNames.Insert(p.Name)
+			}
+
+			allErrs = append(allErrs, validateProtocol(p.Protocol, p.AppProtocol, portPath)...)
+
+			if p.Port == nil {
+				allErrs = append(allErrs, field.Required(portPath.Child("port"), ""))
+				continue
+			}
+			for _, msg := range validation.IsValidPortNum(int(*p.Port)) {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("port"), *p.Port, msg))
+			}
+			// the same number can be used once per protocol, e.g. DNS on 53/TCP and 53/UDP
+			key := fmt.Sprintf("%d/%s", *p.Port, p.GetProtocol())
+			if portNumbers.Has(key) {
+				allErrs = append(allErrs, field.Duplicate(portPath.Child("port"), *p.Port))
+			}
+			portNumbers.Insert(key)
+		}
+	}
+
+	return allErrs
+}
+
+// supportedProtocols are the port protocols Kubernetes supports for containers and Services.
+var supportedProtocols = []corev1.Protocol{corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP}
+
+// validateProtocol checks the protocol of a container or Service port and its appProtocol, which
+// is either an IANA service name or a prefixed name such as kubernetes.io/h2c.
+func validateProtocol(protocol, appProtocol string, portPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if protocol != "" && !slices.Contains(supportedProtocols, corev1.Protocol(protocol)) {
+		allErrs = append(allErrs, field.NotSupported(portPath.Child("protocol"), protocol, supportedProtocols))
+	}
+	if appProtocol != "" {
+		for _, msg := range validation.IsQualifiedName(appProtocol) {
+			allErrs = append(allErrs, field.Invalid(portPath.Child("appProtocol"), appProtocol, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// supportedServiceTypes are the Service types the controller can create for an ElasticWeb.
+var supportedServiceTypes = []corev1.ServiceType{
+	corev1.ServiceTypeClusterIP,
+	corev1.ServiceTypeNodePort,
+	corev1.ServiceTypeLoadBalancer,
+}
+
+// validateService checks the Service ports and that every targetPort points at a port, by
+// number or by name, that one of the containers declares with the same protocol. In Variants
+// mode the Service sends traffic to the pods of every variant, so every variant must declare it.
+func validateService(svc *elasticwebv1.ElasticWebSpecSvc, deploy []elasticwebv1.ElasticWebSpecDeploy, variants bool, svcPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if !slices.Contains(supportedServiceTypes, corev1.ServiceType(svc.Type)) {
+		allErrs = append(allErrs, field.NotSupported(svcPath.Child("type"), svc.Type, supportedServiceTypes))
+	}
+	allErrs = append(allErrs, validateServiceOptions(svc, svcPath)...)
+
+	if len(svc.Ports) == 0 {
+		return append(allErrs, field.Required(svcPath.Child("ports"), "at least one port is required"))
+	}
+
+	names := sets.New[string]()
+	ports := sets.New[string]()
+	for i, p := range svc.Ports {
+		portPath := svcPath.Child("ports").Index(i)
+
+		switch {
+		case p.Name == "" && len(svc.Ports) > 1:
+			allErrs = append(allErrs, field.Required(portPath.Child("name"), "must be set when the service has more than one port"))
+		case p.Name != "":
+			for _, msg := range validation.IsDNS1123Label(p.Name) {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("name"), p.Name, msg))
+			}
+			if names.Has(p.Name) {
+				allErrs = append(allErrs, field.Duplicate(portPath.Child("name"), p.Name))
+			}
+			names.Insert(p.Name)
+		}
+
+		allErrs = append(allErrs, validateProtocol(p.Protocol, p.AppProtocol, portPath)...)
+
+		if p.Port == nil {
+			allErrs = append(allErrs, field.Required(portPath.Child("port"), ""))
+		} else {
+			for _, msg := range validation.IsValidPortNum(int(*p.Port)) {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("port"), *p.Port, msg))
+			}
+			key := fmt.Sprintf("%d/%s", *p.Port, p.GetProtocol())
+			if ports.Has(key) {
+				allErrs = append(allErrs, field.Duplicate(portPath.Child("port"), *p.Port))
+			}
+			ports.Insert(key)
+		}
+
+		targetPortPath := portPath.Child("targetport")
+		if p.TargetPort == nil {
+			allErrs = append(allErrs, field.Required(targetPortPath, ""))
+			continue
+		}
+		var targetPort interface{} = p.TargetPort.IntVal
+		var msgs []string
+		if p.TargetPort.Type == intstr.String {
+			targetPort = p.TargetPort.StrVal
+			msgs = validation.IsValidPortName(p.TargetPort.StrVal)
+		} else {
+			msgs = validation.IsValidPortNum(int(p.TargetPort.IntVal))
+		}
+		for _, msg := range msgs {
+			allErrs = append(allErrs, field.Invalid(targetPortPath, targetPort, msg))
+		}
+		if len(msgs) > 0 {
+			continue
+		}
+
+		if !slices.ContainsFunc(deploy, func(d elasticwebv1.ElasticWebSpecDeploy) bool { return d.FindPort(&svc.Ports[i]) != nil }) {
+			allErrs = append(allErrs, field.Invalid(targetPortPath, targetPort,
+				fmt.Sprintf("must match the number or name of a %s port declared in spec.deploy[].ports", p.GetProtocol())))
+			continue
+		}
+		if !variants {
+			continue
+		}
+		for j := range deploy {
+			if deploy[j].FindPort(&svc.Ports[i]) == nil {
+				allErrs = append(allErrs, field.Invalid(targetPortPath, targetPort,
+					fmt.Sprintf("must match a port of every variant, variant %s does not declare it", deploy[j].Name)))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// getServingContainer returns the name of the first container a Service port targets, or "" when
+// no container serves the Service.
+func getServingContainer(r *elasticwebv1.ElasticWeb) string {
+	for i := range r.Spec.Deploy {
+		for j := range r.Spec.Service.Ports {
+			if r.Spec.Deploy[i].FindPort(&r.Spec.Service.Ports[j]) != nil {
+				return r.Spec.Deploy[i].Name
+			}
+		}
+	}
+	return ""
+}
+
+// maxSessionAffinityTimeoutSeconds is the upper bound the API server accepts for ClientIP affinity.
+const maxSessionAffinityTimeoutSeconds = 86400
+
+var (
+	supportedSessionAffinities = []corev1.ServiceAffinity{corev1.ServiceAffinityNone, corev1.ServiceAffinityClientIP}
+	supportedTrafficPolicies   = []string{"Cluster", "Local"}
+	supportedIPFamilyPolicies  = []corev1.IPFamilyPolicy{
+		corev1.IPFamilyPolicySingleStack,
+		corev1.IPFamilyPolicyPreferDualStack,
+		corev1.IPFamilyPolicyRequireDualStack,
+	}
+)
+
+// validateServiceOptions rejects Service options that do not apply to the Service type, which the
+// API server would otherwise reject only when the controller creates the Service. kube-proxy does
+// not proxy headless Services, so the options it implements cannot be combined with clusterIP None.
+func validateServiceOptions(svc *elasticwebv1.ElasticWebSpecSvc, svcPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	svcType := corev1.ServiceType(svc.Type)
+
+	if svc.ClusterIP != "" && svc.ClusterIP != corev1.ClusterIPNone {
+		allErrs = append(allErrs, field.NotSupported(svcPath.Child("clusterIP"), svc.ClusterIP, []string{corev1.ClusterIPNone}))
+	}
+	if svc.IsHeadless() && svcType != corev1.ServiceTypeClusterIP {
+		allErrs = append(allErrs, field.Forbidden(svcPath.Child("clusterIP"), "headless is only supported for type ClusterIP"))
+	}
+
+	affinity := corev1.ServiceAffinity(svc.SessionAffinity)
+	switch {
+	case affinity != "" && !slices.Contains(supportedSessionAffinities, affinity):
+		allErrs = append(allErrs, field.NotSupported(svcPath.Child("sessionAffinity"), affinity, supportedSessionAffinities))
+	case affinity == corev1.ServiceAffinityClientIP && svc.IsHeadless():
+		allErrs = append(allErrs, field.Forbidden(svcPath.Child("sessionAffinity"), "cannot be ClientIP for a headless service"))
+	}
+	if t := svc.SessionAffinityTimeoutSeconds; t != nil {
+		if affinity != corev1.ServiceAffinityClientIP {
+			allErrs = append(allErrs, field.Forbidden(svcPath.Child("sessionAffinityTimeoutSeconds"), "may only be set when sessionAffinity is ClientIP"))
+		}
+		if *t < 1 || *t > maxSessionAffinityTimeoutSeconds {
+			allErrs = append(allErrs, field.Invalid(svcPath.Child("sessionAffinityTimeoutSeconds"), *t,
+				fmt.Sprintf("must be between 1 and %d", maxSessionAffinityTimeoutSeconds)))
+		}
+	}
+
+	if p := svc.ExternalTrafficPolicy; p != "" {
+		if !slices.Contains(supportedTrafficPolicies, p) {
+			allErrs = append(allErrs, field.NotSupported(svcPath.Child("externalTrafficPolicy"), p, supportedTrafficPolicies))
+		}
+		if svcType != corev1.ServiceTypeNodePort && svcType != corev1.ServiceTypeLoadBalancer {
+			allErrs = append(allErrs, field.Forbidden(svcPath.Child("externalTrafficPolicy"), "may only be set for type NodePort or LoadBalancer"))
+		}
+	}
+	if p := svc.InternalTrafficPolicy; p != "" {
+		if !slices.Contains(supportedTrafficPolicies, p) {
+			allErrs = append(allErrs, field.NotSupported(svcPath.Child("internalTrafficPolicy"), p, supportedTrafficPolicies))
+		}
+		if svc.IsHeadless() {
+			allErrs = append(allErrs, field.Forbidden(svcPath.Child("internalTrafficPolicy"), "cannot be set for a headless service"))
+		}
+	}
+
+	if len(svc.LoadBalancerSourceRanges) > 0 && svcType != corev1.ServiceTypeLoadBalancer {
+		allErrs = append(allErrs, field.Forbidden(svcPath.Child("loadBalancerSourceRanges"), "may only be set for type LoadBalancer"))
+	}
+	for i, cidr := range svc.LoadBalancerSourceRanges {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			allErrs = append(allErrs, field.Invalid(svcPath.Child("loadBalancerSourceRanges").Index(i), cidr, "must be a CIDR such as 10.0.0.0/8"))
+		}
+	}
+
+	if p := corev1.IPFamilyPolicy(svc.IPFamilyPolicy); p != "" && !slices.Contains(supportedIPFamilyPolicies, p) {
+		allErrs = append(allErrs, field.NotSupported(svcPath.Child("ipFamilyPolicy"), p, supportedIPFamilyPolicies))
+	}
+
+	return allErrs
+}
+
+// maxRouteWeight is the upper bound of HTTPRoute backend weights in Gateway API.
+const maxRouteWeight = 1000000
+
+// supportedPathMatchTypes are the HTTPRoute path match types, empty means PathPrefix.
+var supportedPathMatchTypes = []string{"Exact", "PathPrefix", "RegularExpression"}
+
+// validateRoute checks the HTTPRoute the controller generates: the Gateways it attaches to,
+// the hostnames and paths it matches, and that its backends can actually receive traffic.
+func validateRoute(r *elasticwebv1.ElasticWeb, routePath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	route := r.Spec.Route
+
+	if len(route.ParentRefs) == 0 {
+		allErrs = append(allErrs, field.Required(routePath.Child("parentRefs"), "at least one gateway is required"))
+	}
+	for i, p := range route.ParentRefs {
+		parentPath := routePath.Child("parentRefs").Index(i)
+		if p.Name == "" {
+			allErrs = append(allErrs, field.Required(parentPath.Child("name"), ""))
+		}
+		if p.Namespace != "" {
+			for _, msg := range validation.IsDNS1123Label(p.Namespace) {
+				allErrs = append(allErrs, field.Invalid(parentPath.Child("namespace"), p.Namespace, msg))
+			}
+		}
+		if p.Port != nil {
+			for _, msg := range validation.IsValidPortNum(int(*p.Port)) {
+				allErrs = append(allErrs, field.Invalid(parentPath.Child("port"), *p.Port, msg))
+			}
+		}
+	}
+
+	for i, h := range route.Hostnames {
+		for _, msg := range validation.IsDNS1123Subdomain(strings.TrimPrefix(string(h), "*.")) {
+			allErrs = append(allErrs, field.Invalid(routePath.Child("hostnames").Index(i), h, msg))
+		}
+	}
+
+	for i, m := range route.Matches {
+		matchPath := routePath.Child("matches").Index(i)
+		if m.Type != "" && !slices.Contains(supportedPathMatchTypes, m.Type) {
+			allErrs = append(allErrs, field.NotSupported(matchPath.Child("type"), m.Type, supportedPathMatchTypes))
+		}
+		if !strings.HasPrefix(m.Value, "/") {
+			allErrs = append(allErrs, field.Invalid(matchPath.Child("value"), m.Value, "must start with /"))
+		}
+	}
+
+	// the route sends HTTP traffic to a TCP port the ElasticWeb Service exposes
+	servicePort := r.GetRouteServicePort()
+	if !slices.ContainsFunc(r.Spec.Service.Ports, func(p elasticwebv1.ElasticWebSpecSvcPorts) bool {
+		return p.Port != nil && *p.Port == servicePort && p.GetProtocol() == corev1.ProtocolTCP
+	}) {
+		allErrs = append(allErrs, field.Invalid(routePath.Child("servicePort"), servicePort, "must be one of the TCP ports in spec.service.ports[].port"))
+	}
+
+	validateWeight := func(weightPath *field.Path, weight *int32) bool {
+		if weight == nil {
+			return true
+		}
+		if *weight < 0 || *weight > maxRouteWeight {
+			allErrs = append(allErrs, field.Invalid(weightPath, *weight, fmt.Sprintf("must be between 0 and %d", maxRouteWeight)))
+		}
+		return *weight > 0
+	}
+	receiving := validateWeight(routePath.Child("weight"), route.Weight)
+	backendNames := sets.New(r.Name)
+	for i, b := range route.Backends {
+		backendPath := routePath.Child("backends").Index(i)
+		for _, msg := range validation.IsDNS1035Label(b.Name) {
+			allErrs = append(allErrs, field.Invalid(backendPath.Child("name"), b.Name, msg))
+		}
+		if backendNames.Has(b.Name) {
+			allErrs = append(allErrs, field.Duplicate(backendPath.Child("name"), b.Name))
+		}
+		backendNames.Insert(b.Name)
+		for _, msg := range validation.IsValidPortNum(int(b.Port)) {
+			allErrs = append(allErrs, field.Invalid(backendPath.Child("port"), b.Port, msg))
+		}
+		if validateWeight(backendPath.Child("weight"), b.Weight) {
+			receiving = true
+		}
+	}
+	if !receiving {
+		allErrs = append(allErrs, field.Invalid(routePath.Child("weight"), 0, "at least one backend must have a weight greater than 0"))
+	}
+
+	return allErrs
+}
+
+// maxPlacementShare is the upper bound of spec.placement.clusters[].share, kept in sync with the CRD schema.
+const maxPlacementShare = 100
+
+// validatePlacement checks the member clusters of a hub ElasticWeb. Cluster names are Secret names in the
+// manager namespace, whether those Secrets exist is reported by the controller in the Placed condition.
+func validatePlacement(r *elasticwebv1.ElasticWeb, placementPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	clustersPath := placementPath.Child("clusters")
+
+	if len(r.Spec.Placement.Clusters) == 0 {
+		allErrs = append(allErrs, field.Required(clustersPath, "at least one cluster is required"))
+	}
+	names := sets.New[string]()
+	receiving := false
+	for i, c := range r.Spec.Placement.Clusters {
+		clusterPath := clustersPath.Index(i)
+		for _, msg := range validation.IsDNS1123Label(c.Name) {
+			allErrs = append(allErrs, field.Invalid(clusterPath.Child("name"), c.Name, msg))
+		}
+		if names.Has(c.Name) {
+			allErrs = append(allErrs, field.Duplicate(clusterPath.Child("name"), c.Name))
+		}
+		names.Insert(c.Name)
+		share := c.GetShare()
+		if share < 0 || share > maxPlacementShare {
+			allErrs = append(allErrs, field.Invalid(clusterPath.Child("share"), share, fmt.Sprintf("must be between 0 and %d", maxPlacementShare)))
+		}
+		if share > 0 {
+			receiving = true
+		}
+	}
+	if len(r.Spec.Placement.Clusters) > 0 && !receiving {
+		allErrs = append(allErrs, field.Invalid(clustersPath, 0, "at least one cluster must have a share greater than 0"))
+	}
+
+	// calibration writes singlePodQPS back into the member spec, which the hub would overwrite on the next sync
+	if r.Spec.Calibration != nil && r.Spec.Calibration.Apply {
+		allErrs = append(allErrs, field.Forbidden(placementPath, "cannot be combined with spec.calibration.apply"))
+	}
+
+	return allErrs
+}
+
+// validateNetworkPolicy checks the peers and egress rules copied into the generated NetworkPolicy,
+// so that a bad selector or CIDR is reported here instead of failing every reconcile.
+func validateNetworkPolicy(policy *elasticwebv1.ElasticWebSpecNetworkPolicy, policyPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	selectorOpts := metav1validation.LabelSelectorValidationOptions{}
+
+	for i, peer := range policy.From {
+		peerPath := policyPath.Child("from").Index(i)
+		if len(peer.Namespaces) == 0 && peer.NamespaceSelector == nil && peer.PodSelector == nil {
+			allErrs = append(allErrs, field.Required(peerPath, "one of namespaces, namespaceSelector or podSelector is required"))
+		}
+		if len(peer.Namespaces) > 0 && peer.NamespaceSelector != nil {
+			allErrs = append(allErrs, field.Forbidden(peerPath.Child("namespaceSelector"), "may not be set together with namespaces"))
+		}
+		for j, ns := range peer.Namespaces {
+			for _, msg := range validation.IsDNS1123Label(ns) {
+				allErrs = append(allErrs, field.Invalid(peerPath.Child("namespaces").Index(j), ns, msg))
+			}
+		}
+		if peer.NamespaceSelector != nil {
+			allErrs = append(allErrs, metav1validation.ValidateLabelSelector(peer.NamespaceSelector, selectorOpts, peerPath.Child("namespaceSelector"))...)
+		}
+		if peer.PodSelector != nil {
+			allErrs = append(allErrs, metav1validation.ValidateLabelSelector(peer.PodSelector, selectorOpts, peerPath.Child("podSelector"))...)
+		}
+	}
+
+	for i, rule := range policy.Egress {
+		rulePath := policyPath.Child("egress").Index(i)
+		for j, port := range rule.Ports {
+			portPath := rulePath.Child("ports").Index(j)
+			if port.Protocol != nil && !slices.Contains(supportedProtocols, *port.Protocol) {
+				allErrs = append(allErrs, field.NotSupported(portPath.Child("protocol"), *port.Protocol, supportedProtocols))
+			}
+			if port.Port != nil && port.Port.Type == intstr.Int {
+				for _, msg := range validation.IsValidPortNum(port.Port.IntValue()) {
+					allErrs = append(allErrs, field.Invalid(portPath.Child("port"), port.Port.IntValue(), msg))
+				}
+			}
+			if port.EndPort != nil && (port.Port == nil || port.Port.Type != intstr.Int || *port.EndPort < port.Port.IntVal) {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("endPort"), *port.EndPort, "must be greater than or equal to a numeric port"))
+			}
+		}
+		for j, to := range rule.To {
+			toPath := rulePath.Child("to").Index(j)
+			if to.NamespaceSelector != nil {
+				allErrs = append(allErrs, metav1validation.ValidateLabelSelector(to.NamespaceSelector, selectorOpts, toPath.Child("namespaceSelector"))...)
+			}
+			if to.PodSelector != nil {
+				allErrs = append(allErrs, metav1validation.ValidateLabelSelector(to.PodSelector, selectorOpts, toPath.Child("podSelector"))...)
+			}
+			if to.IPBlock == nil {
+				continue
+			}
+			blockPath := toPath.Child("ipBlock")
+			if to.NamespaceSelector != nil || to.PodSelector != nil {
+				allErrs = append(allErrs, field.Forbidden(blockPath, "may not be set together with selectors"))
+			}
+			_, cidr, err := net.ParseCIDR(to.IPBlock.CIDR)
+			if err != nil {
+				allErrs = append(allErrs, field.Invalid(blockPath.Child("cidr"), to.IPBlock.CIDR, "must be a valid CIDR"))
+				continue
+			}
+			for k, except := range to.IPBlock.Except {
+				_, exceptCIDR, err := net.ParseCIDR(except)
+				if err != nil {
+					allErrs = append(allErrs, field.Invalid(blockPath.Child("except").Index(k), except, "must be a valid CIDR"))
+					continue
+				}
+				cidrOnes, _ := cidr.Mask.Size()
+				exceptOnes, _ := exceptCIDR.Mask.Size()
+				if !cidr.Contains(exceptCIDR.IP) || exceptOnes <= cidrOnes {
+					allErrs = append(allErrs, field.Invalid(blockPath.Child("except").Index(k), except, "must be a strict subset of cidr"))
+				}
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validatePrivileged rejects privileged containers unless the ElasticWeb opts in
+// with the allow-privileged annotation.
+func validatePrivileged(r *elasticwebv1.ElasticWeb, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if r.Annotations[elasticwebv1.AllowPrivilegedAnnotation] == "true" {
+		return allErrs
+	}
+
+	for i, d := range r.Spec.Deploy {
+		if d.SecurityContext != nil && ptr.Deref(d.SecurityContext.Privileged, false) {
+			allErrs = append(allErrs, field.Forbidden(
+				specPath.Child("deploy").Index(i).Child("securityContext", "privileged"),
+				fmt.Sprintf("privileged containers require the %s: \"true\" annotation", elasticwebv1.AllowPrivilegedAnnotation)))
+		}
+	}
+
+	return allErrs
+}
+
+// validateServiceAccount checks the ServiceAccount and image pull secret references.
+func validateServiceAccount(r *elasticwebv1.ElasticWeb, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if sa := r.Spec.ServiceAccount; sa != nil && !sa.Create && len(sa.Annotations) > 0 {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("serviceAccount", "annotations"),
+			"annotations can only be set when serviceAccount.create is true"))
+	}
+
+	for i, s := range r.Spec.ImagePullSecrets {
+		if s.Name == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("imagePullSecrets").Index(i).Child("name"), ""))
+		}
+	}
+
+	return allErrs
+}
+
+// validateVolumes checks that every volume has a unique name and exactly one source,
+// and that every container volumeMount refers to one of the declared volumes.
+func validateVolumes(volumes []elasticwebv1.ElasticWebSpecVolume, deploy []elasticwebv1.ElasticWebSpecDeploy, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	declared := sets.New[string]()
+	for i, v := range volumes {
+		volPath := specPath.Child("volumes").Index(i)
+		if v.Name == "" {
+			allErrs = append(allErrs, field.Required(volPath.Child("name"), ""))
+		} else if declared.Has(v.Name) {
+			allErrs = append(allErrs, field.Duplicate(volPath.Child("name"), v.Name))
+		}
+		declared.Insert(v.Name)
+
+		sources := 0
+		for _, set := range []bool{
+			v.EmptyDir != nil,
+			v.ConfigMap != nil,
+			v.Secret != nil,
+			v.Projected != nil,
+			v.PersistentVolumeClaim != nil,
+			v.ClaimTemplate != nil,
+		} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			allErrs = append(allErrs, field.Invalid(volPath, v.Name,
+				"must specify exactly one of emptyDir, configMap, secret, projected, persistentVolumeClaim, claimTemplate"))
+		}
+	}
+
+	for i, d := range deploy {
+		for j, m := range d.VolumeMounts {
+			mountPath := specPath.Child("deploy").Index(i).Child("volumeMounts").Index(j)
+			if !declared.Has(m.Name) {
+				allErrs = append(allErrs, field.NotFound(mountPath.Child("name"), m.Name))
+			}
+			if m.MountPath == "" {
+				allErrs = append(allErrs, field.Required(mountPath.Child("mountPath"), ""))
+			}
+		}
+	}
+
+	return allErrs
 }
@@ -17,13 +17,50 @@ limitations under the License.
 package v1
 
 import (
+	"encoding/json"
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	elasticwebv1 "elasticweb/api/v1"
 	// TODO (user): Add any additional imports if needed
 )
 
+// newElasticWeb returns an ElasticWeb that passes validation, tests mutate it from there.
+func newElasticWeb() *elasticwebv1.ElasticWeb {
+	return &elasticwebv1.ElasticWeb{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: elasticwebv1.ElasticWebSpec{
+			SinglePodQPS: ptr.To[int32](500),
+			TotalQPS:     ptr.To[int32](1200),
+			Deploy: []elasticwebv1.ElasticWebSpecDeploy{{
+				Name:  "tomcat",
+				Image: "tomcat:8.0.18-jre8",
+				Ports: []elasticwebv1.ElasticWebSpecDeployPorts{{Name: "http", Port: ptr.To[int32](8080)}},
+			}},
+			Service: elasticwebv1.ElasticWebSpecSvc{
+				Type: "ClusterIP",
+				Ports: []elasticwebv1.ElasticWebSpecSvcPorts{
+					{Name: "http", Port: ptr.To[int32](8080), TargetPort: ptr.To(intstr.FromInt32(8080))},
+				},
+			},
+		},
+	}
+}
+
 var _ = Describe("ElasticWeb Webhook", func() {
 	var (
 		obj       *elasticwebv1.ElasticWeb
@@ -33,11 +70,15 @@ var _ = Describe("ElasticWeb Webhook", func() {
 	)
 
 	BeforeEach(func() {
-		obj = &elasticwebv1.ElasticWeb{}
-		oldObj = &elasticwebv1.ElasticWeb{}
+		obj = newElasticWeb()
+		oldObj = newElasticWeb()
 		validator = ElasticWebCustomValidator{}
 		Expect(validator).NotTo(BeNil(), "Expected validator to be initialized")
-		defaulter = ElasticWebCustomDefaulter{}
+		defaulter = ElasticWebCustomDefaulter{
+			DefaultTotalQPS:                 1200,
+			DefaultPodSecurityContext:       hardenedPodSecurityContext(),
+			DefaultContainerSecurityContext: hardenedContainerSecurityContext(),
+		}
 		Expect(defaulter).NotTo(BeNil(), "Expected defaulter to be initialized")
 		Expect(oldObj).NotTo(BeNil(), "Expected oldObj to be initialized")
 		Expect(obj).NotTo(BeNil(), "Expected obj to be initialized")
@@ -59,6 +100,89 @@ var _ = Describe("ElasticWeb Webhook", func() {
 		//     By("checking that the default values are set")
 		//     Expect(obj.SomeFieldWithDefault).To(Equal("default_value"))
 		// })
+
+		It("Should apply the hardened security context when none is set", func() {
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.SecurityContext.RunAsNonRoot).To(HaveValue(BeTrue()))
+			Expect(obj.Spec.SecurityContext.SeccompProfile.Type).To(Equal(corev1.SeccompProfileTypeRuntimeDefault))
+
+			sc := obj.Spec.Deploy[0].SecurityContext
+			Expect(sc.ReadOnlyRootFilesystem).To(HaveValue(BeTrue()))
+			Expect(sc.AllowPrivilegeEscalation).To(HaveValue(BeFalse()))
+			Expect(sc.Capabilities.Drop).To(ConsistOf(corev1.Capability("ALL")))
+		})
+
+		It("Should apply the built-in totalQPS when no policy is configured", func() {
+			obj.Spec.TotalQPS = nil
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.TotalQPS).To(HaveValue(Equal(int32(1200))))
+		})
+
+		It("Should apply the ConfigMap policy with namespace overrides", func() {
+			policyConfigMap := func(namespace, policy string) *corev1.ConfigMap {
+				return &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "elasticweb-defaulting-policy", Namespace: namespace},
+					Data:       map[string]string{DefaultingPolicyKey: policy},
+				}
+			}
+			defaulter.PolicyLoader = &DefaultingPolicyLoader{
+				Client: fake.NewClientBuilder().WithObjects(
+					policyConfigMap("elasticweb-system", `
+totalQPS: 3000
+singlePodQPS: 300
+serviceType: NodePort
+resources:
+  limits: {cpu: 500m}
+labels: {team: web, tier: frontend}
+`),
+					policyConfigMap("default", `
+totalQPS: 600
+labels: {team: payments}
+`),
+				).Build(),
+				Namespace: "elasticweb-system",
+				Name:      "elasticweb-defaulting-policy",
+			}
+			obj.Labels = map[string]string{"tier": "backend"}
+			obj.Spec.TotalQPS = nil
+			obj.Spec.SinglePodQPS = nil
+			obj.Spec.Service.Type = ""
+
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.TotalQPS).To(HaveValue(Equal(int32(600))))
+			Expect(obj.Spec.SinglePodQPS).To(HaveValue(Equal(int32(300))))
+			Expect(obj.Spec.Service.Type).To(Equal("NodePort"))
+			Expect(obj.Spec.Deploy[0].Resources.Limits.Cpu().Equal(resource.MustParse("500m"))).To(BeTrue())
+			Expect(obj.Labels).To(Equal(map[string]string{"team": "payments", "tier": "backend"}))
+		})
+
+		It("Should fall back to the built-in defaults when the policy is broken", func() {
+			defaulter.PolicyLoader = &DefaultingPolicyLoader{
+				Client: fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "default"},
+					Data:       map[string]string{DefaultingPolicyKey: "totalQPS: many"},
+				}).Build(),
+				Namespace: "default",
+				Name:      "policy",
+			}
+			obj.Spec.TotalQPS = nil
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.TotalQPS).To(HaveValue(Equal(int32(1200))))
+		})
+
+		It("Should keep fields the instance relaxed explicitly", func() {
+			obj.Spec.Deploy[0].SecurityContext = &corev1.SecurityContext{
+				ReadOnlyRootFilesystem: ptr.To(false),
+				Capabilities:           &corev1.Capabilities{Add: []corev1.Capability{"NET_BIND_SERVICE"}},
+			}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+			sc := obj.Spec.Deploy[0].SecurityContext
+			Expect(sc.ReadOnlyRootFilesystem).To(HaveValue(BeFalse()))
+			Expect(sc.Capabilities.Add).To(ConsistOf(corev1.Capability("NET_BIND_SERVICE")))
+			Expect(sc.Capabilities.Drop).To(BeEmpty())
+			Expect(sc.RunAsNonRoot).To(HaveValue(BeTrue()))
+		})
 	})
 
 	Context("When creating or updating ElasticWeb under Validating Webhook", func() {
@@ -82,6 +206,610 @@ var _ = Describe("ElasticWeb Webhook", func() {
 		//     obj.SomeRequiredField = "updated_value"
 		//     Expect(validator.ValidateUpdate(ctx, oldObj, obj)).To(BeNil())
 		// })
+
+		It("Should admit a valid ElasticWeb", func() {
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a missing singlePodQPS instead of panicking", func() {
+			obj.Spec.SinglePodQPS = nil
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.singlePodQPS: Required value")))
+		})
+
+		It("Should report every problem at once", func() {
+			obj.Spec.SinglePodQPS = ptr.To[int32](0)
+			obj.Spec.Deploy = append(obj.Spec.Deploy, elasticwebv1.ElasticWebSpecDeploy{
+				Name:  "tomcat",
+				Image: "tomcat:9",
+				Ports: []elasticwebv1.ElasticWebSpecDeployPorts{{Name: "http", Port: ptr.To[int32](70000)}},
+			})
+			obj.Spec.Service.Type = "ExternalName"
+			obj.Spec.Service.Ports[0].TargetPort = ptr.To(intstr.FromInt32(9090))
+
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+			for _, path := range []string{
+				"spec.singlePodQPS",
+				"spec.deploy[1].name: Duplicate value",
+				"spec.deploy[1].ports[0].name: Duplicate value",
+				"spec.deploy[1].ports[0].port",
+				"spec.service.type: Unsupported value",
+				"spec.service.ports[0].targetport",
+			} {
+				Expect(err.Error()).To(ContainSubstring(path))
+			}
+		})
+
+		It("Should deny names that are not DNS-1123 labels", func() {
+			obj.Name = "Web_1"
+			obj.Spec.Deploy[0].Name = "Tomcat"
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("metadata.name")))
+			Expect(err).To(MatchError(ContainSubstring("spec.deploy[0].name")))
+		})
+
+		It("Should admit a volumeMount that refers to a declared volume", func() {
+			obj.Spec.Volumes = []elasticwebv1.ElasticWebSpecVolume{
+				{Name: "cache", EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				{Name: "data", ClaimTemplate: &elasticwebv1.ElasticWebSpecVolumeClaimTemplate{}},
+			}
+			obj.Spec.Deploy[0].VolumeMounts = []corev1.VolumeMount{
+				{Name: "cache", MountPath: "/tmp"},
+				{Name: "data", MountPath: "/data"},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a volumeMount that refers to an undeclared volume", func() {
+			obj.Spec.Deploy[0].VolumeMounts = []corev1.VolumeMount{{Name: "missing", MountPath: "/data"}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.deploy[0].volumeMounts[0].name")))
+		})
+
+		It("Should deny a volume with no source or more than one source", func() {
+			obj.Spec.Volumes = []elasticwebv1.ElasticWebSpecVolume{
+				{Name: "none"},
+				{Name: "both", EmptyDir: &corev1.EmptyDirVolumeSource{}, Secret: &corev1.SecretVolumeSource{}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.volumes[0]")))
+			Expect(err).To(MatchError(ContainSubstring("spec.volumes[1]")))
+		})
+
+		It("Should deny renaming the serving container on update unless confirmed", func() {
+			obj.Spec.Deploy[0].Name = "web"
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(MatchError(ContainSubstring("container tomcat serves the Service")))
+
+			obj.Annotations = map[string]string{elasticwebv1.AllowDisruptiveUpdateAnnotation: "true"}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should admit removing a finalizer from an ElasticWeb being deleted", func() {
+			// a spec that no longer passes validation, e.g. after the rules were tightened
+			oldObj.Spec.SinglePodQPS = ptr.To[int32](0)
+			oldObj.Finalizers = []string{elasticwebv1.PlacementFinalizer}
+			oldObj.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			obj = oldObj.DeepCopy()
+			obj.Finalizers = nil
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+
+			obj.Spec.TotalQPS = ptr.To[int32](100)
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should admit adding and removing a sidecar with a warning", func() {
+			obj.Spec.Deploy = append(obj.Spec.Deploy, elasticwebv1.ElasticWebSpecDeploy{Name: "log-agent", Image: "fluent-bit:3"})
+			warnings, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ContainElement(ContainSubstring("containers change from [tomcat] to [log-agent tomcat]")))
+
+			warnings, err = validator.ValidateUpdate(ctx, obj, oldObj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ContainElement(ContainSubstring("containers change")))
+		})
+
+		Context("with variants", func() {
+			BeforeEach(func() {
+				for _, o := range []*elasticwebv1.ElasticWeb{oldObj, obj} {
+					o.Spec.DeployMode = elasticwebv1.DeployModeVariants
+					canary := *o.Spec.Deploy[0].DeepCopy()
+					canary.Name = "canary"
+					canary.Image = "tomcat:9"
+					canary.Weight = ptr.To[int32](10)
+					o.Spec.Deploy[0].Weight = ptr.To[int32](90)
+					o.Spec.Deploy = append(o.Spec.Deploy, canary)
+				}
+			})
+
+			It("Should admit variants sharing port numbers", func() {
+				Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+			})
+
+			It("Should deny weights outside Variants mode and variants that all weigh 0", func() {
+				obj.Spec.Deploy[0].Weight = ptr.To[int32](0)
+				obj.Spec.Deploy[1].Weight = ptr.To[int32](0)
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("at least one variant must have a weight greater than 0")))
+
+				obj.Spec.DeployMode = ""
+				obj.Spec.Deploy = obj.Spec.Deploy[:1]
+				_, err = validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("spec.deploy[0].weight: Forbidden")))
+			})
+
+			It("Should deny a service port that one of the variants does not serve", func() {
+				obj.Spec.Deploy[1].Ports[0].Port = ptr.To[int32](9090)
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("variant canary does not declare it")))
+			})
+
+			It("Should deny variant Deployments that collide with another ElasticWeb", func() {
+				namesScheme := apimachineryruntime.NewScheme()
+				Expect(elasticwebv1.AddToScheme(namesScheme)).To(Succeed())
+				other := newElasticWeb()
+				other.Name = "test-canary"
+				validator.Client = fake.NewClientBuilder().WithScheme(namesScheme).WithObjects(other).Build()
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("spec.deploy[1].name: Forbidden: Deployment test-canary would also belong to ElasticWeb test-canary")))
+
+				By("Creating the ElasticWeb whose variant Deployment would be taken")
+				validator.Client = fake.NewClientBuilder().WithScheme(namesScheme).WithObjects(obj).Build()
+				_, err = validator.ValidateCreate(ctx, other)
+				Expect(err).To(MatchError(ContainSubstring("metadata.name: Forbidden: Deployment test-canary would also belong to ElasticWeb test")))
+			})
+
+			It("Should allow adding and removing variants with a warning", func() {
+				obj.Spec.Deploy = obj.Spec.Deploy[:1]
+				warnings, err := validator.ValidateUpdate(ctx, oldObj, obj)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(warnings).To(ContainElement(ContainSubstring("Deployments of removed variants are deleted")))
+			})
+
+			It("Should deny switching the deploy mode unless the update is confirmed", func() {
+				oldObj = newElasticWeb()
+				obj.Spec.Deploy = obj.Spec.Deploy[:1]
+				_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+				Expect(err).To(MatchError(ContainSubstring("spec.deployMode: Forbidden")))
+
+				obj.Annotations = map[string]string{elasticwebv1.AllowDisruptiveUpdateAnnotation: "true"}
+				Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+			})
+		})
+
+		It("Should deny leaving LoadBalancer unless the update is confirmed", func() {
+			oldObj.Spec.Service.Type = "LoadBalancer"
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.service.type: Forbidden")))
+
+			obj.Annotations = map[string]string{elasticwebv1.AllowDisruptiveUpdateAnnotation: "true"}
+			warnings, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ContainElement(ContainSubstring("spec.service.type")))
+		})
+
+		It("Should deny switching to a headless service unless the update is confirmed", func() {
+			obj.Spec.Service.ClusterIP = "None"
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.service.clusterIP: Forbidden: changing clusterIP recreates the Service")))
+
+			obj.Annotations = map[string]string{elasticwebv1.AllowDisruptiveUpdateAnnotation: "true"}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should only allow placement on creation and confirmed removal", func() {
+			obj.Spec.Placement = &elasticwebv1.ElasticWebSpecPlacement{
+				Clusters: []elasticwebv1.ElasticWebSpecPlacementCluster{{Name: "east"}},
+			}
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.placement: Forbidden: can only be set when the ElasticWeb is created")))
+
+			_, err = validator.ValidateUpdate(ctx, obj, oldObj)
+			Expect(err).To(MatchError(ContainSubstring("spec.placement: Forbidden: removing placement deletes the ElasticWebs")))
+
+			oldObj.Annotations = map[string]string{elasticwebv1.AllowDisruptiveUpdateAnnotation: "true"}
+			Expect(validator.ValidateUpdate(ctx, obj, oldObj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny service options that do not apply to the service type", func() {
+			obj.Spec.Service.ClusterIP = "None"
+			obj.Spec.Service.Type = "NodePort"
+			obj.Spec.Service.SessionAffinity = "ClientIP"
+			obj.Spec.Service.InternalTrafficPolicy = "Local"
+			obj.Spec.Service.LoadBalancerSourceRanges = []string{"10.0.0.0/8", "10.0.0.1"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.service.clusterIP: Forbidden: headless is only supported for type ClusterIP")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.sessionAffinity: Forbidden")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.internalTrafficPolicy: Forbidden")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.loadBalancerSourceRanges: Forbidden")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.loadBalancerSourceRanges[1]: Invalid value: \"10.0.0.1\"")))
+
+			obj.Spec.Service = elasticwebv1.ElasticWebSpecSvc{
+				Type:                          "ClusterIP",
+				Ports:                         obj.Spec.Service.Ports,
+				SessionAffinityTimeoutSeconds: ptr.To[int32](100000),
+				ExternalTrafficPolicy:         "Local",
+			}
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.service.sessionAffinityTimeoutSeconds: Forbidden")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.sessionAffinityTimeoutSeconds: Invalid value: 100000")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.externalTrafficPolicy: Forbidden")))
+
+			obj.Spec.Service = elasticwebv1.ElasticWebSpecSvc{
+				Type:                          "LoadBalancer",
+				Ports:                         obj.Spec.Service.Ports,
+				SessionAffinity:               "ClientIP",
+				SessionAffinityTimeoutSeconds: ptr.To[int32](600),
+				ExternalTrafficPolicy:         "Local",
+				InternalTrafficPolicy:         "Local",
+				LoadBalancerSourceRanges:      []string{"10.0.0.0/8", "2001:db8::/32"},
+				IPFamilyPolicy:                "PreferDualStack",
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should match targetports by number or name with the same protocol", func() {
+			obj.Spec.Deploy[0].Ports = []elasticwebv1.ElasticWebSpecDeployPorts{
+				{Name: "http", Port: ptr.To[int32](8080), AppProtocol: "kubernetes.io/h2c"},
+				{Name: "dns", Port: ptr.To[int32](53), Protocol: "UDP"},
+				{Name: "dns-tcp", Port: ptr.To[int32](53)},
+			}
+			obj.Spec.Service.Ports = []elasticwebv1.ElasticWebSpecSvcPorts{
+				{Name: "http", Port: ptr.To[int32](80), TargetPort: ptr.To(intstr.FromString("http"))},
+				{Name: "dns", Port: ptr.To[int32](53), TargetPort: ptr.To(intstr.FromString("dns")), Protocol: "UDP"},
+				{Name: "dns-tcp", Port: ptr.To[int32](53), TargetPort: ptr.To(intstr.FromInt32(53))},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			obj.Spec.Service.Ports[0].TargetPort = ptr.To(intstr.FromString("grpc"))
+			obj.Spec.Service.Ports[1].TargetPort = ptr.To(intstr.FromString("dns-tcp"))
+			obj.Spec.Service.Ports[2].AppProtocol = "not a protocol"
+			obj.Spec.Service.Ports[2].Protocol = "QUIC"
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.service.ports[0].targetport: Invalid value: \"grpc\": must match the number or name of a TCP port")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.ports[1].targetport: Invalid value: \"dns-tcp\": must match the number or name of a UDP port")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.ports[2].appProtocol: Invalid value")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.ports[2].protocol: Unsupported value: \"QUIC\"")))
+		})
+
+		It("Should deny UDP ports where only HTTP is served", func() {
+			obj.Spec.Deploy[0].Ports[0].Protocol = "UDP"
+			obj.Spec.Service.Ports[0].Protocol = "UDP"
+			obj.Spec.Idle = &elasticwebv1.ElasticWebSpecIdle{After: metav1.Duration{Duration: time.Minute}}
+			obj.Spec.Calibration = &elasticwebv1.ElasticWebSpecCalibration{Path: "/healthz"}
+			obj.Spec.Route = &elasticwebv1.ElasticWebSpecRoute{
+				ParentRefs: []elasticwebv1.ElasticWebSpecRouteParentRef{{Name: "gateway"}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("must be TCP when spec.idle is set")))
+			Expect(err).To(MatchError(ContainSubstring("must be TCP when spec.calibration is set")))
+			Expect(err).To(MatchError(ContainSubstring("spec.route.servicePort: Invalid value: 8080: must be one of the TCP ports")))
+		})
+
+		It("Should limit how much totalQPS shrinks in one update", func() {
+			oldObj.Spec.TotalQPS = ptr.To[int32](10000)
+			obj.Spec.TotalQPS = ptr.To[int32](6000)
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+
+			obj.Spec.TotalQPS = ptr.To[int32](1000)
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.totalQPS")))
+
+			obj.Annotations = map[string]string{elasticwebv1.AllowDisruptiveUpdateAnnotation: "true"}
+			warnings, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ContainElement(ContainSubstring("spec.totalQPS shrinks")))
+		})
+
+		It("Should warn about image changes", func() {
+			obj.Spec.Deploy[0].Image = "tomcat:9"
+			warnings, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ContainElement(ContainSubstring("pods will be rolled")))
+		})
+
+		It("Should allow deleting an unprotected ElasticWeb", func() {
+			Expect(validator.ValidateDelete(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should protect an ElasticWeb that still serves traffic from deletion", func() {
+			obj.Spec.DeletionProtection = true
+			_, err := validator.ValidateDelete(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("totalQPS is 1200")))
+
+			obj.Annotations = map[string]string{elasticwebv1.ConfirmDeleteAnnotation: "true"}
+			Expect(validator.ValidateDelete(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should protect an ElasticWeb scaled to zero that still has ready pods", func() {
+			obj.Spec.DeletionProtection = true
+			obj.Spec.TotalQPS = ptr.To[int32](0)
+			obj.Status.ReadyReplicas = 2
+
+			_, err := validator.ValidateDelete(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("2 pods are ready")))
+
+			obj.Status.ReadyReplicas = 0
+			Expect(validator.ValidateDelete(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		Context("with an ElasticWebQuota in the namespace", func() {
+			BeforeEach(func() {
+				quotaScheme := apimachineryruntime.NewScheme()
+				Expect(elasticwebv1.AddToScheme(quotaScheme)).To(Succeed())
+
+				existing := newElasticWeb()
+				existing.Name = "existing"
+				existing.Spec.TotalQPS = ptr.To[int32](3000) // 6 replicas
+				validator.Client = fake.NewClientBuilder().WithScheme(quotaScheme).WithObjects(
+					existing,
+					&elasticwebv1.ElasticWebQuota{
+						ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "default"},
+						Spec: elasticwebv1.ElasticWebQuotaSpec{
+							MaxTotalQPS: ptr.To[int32](5000),
+							MaxReplicas: ptr.To[int32](10),
+						},
+					},
+				).Build()
+			})
+
+			It("Should admit an ElasticWeb that fits", func() {
+				obj.Spec.TotalQPS = ptr.To[int32](2000)
+				Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+			})
+
+			It("Should deny an ElasticWeb that exceeds the namespace totalQPS", func() {
+				obj.Spec.TotalQPS = ptr.To[int32](2500)
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("namespace totalQPS would be 5500, limited to 5000")))
+				Expect(err).To(MatchError(ContainSubstring("spec: Forbidden: exceeds ElasticWebQuota team: namespace replicas would be 11, limited to 10")))
+			})
+
+			It("Should report replicas over quota on spec.replicas when it is set", func() {
+				obj.Spec.Replicas = ptr.To[int32](5)
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("spec.replicas: Forbidden: exceeds ElasticWebQuota team: namespace replicas would be 11, limited to 10")))
+			})
+
+			It("Should deny scaling through the scale subresource past the quota", func() {
+				c := validator.Client.(client.Client)
+				Expect(c.Create(ctx, obj)).To(Succeed())
+				scaleValidator := &ElasticWebScaleValidator{Client: c}
+				scale := func(replicas int32) admission.Response {
+					raw, err := json.Marshal(&autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: replicas}})
+					Expect(err).NotTo(HaveOccurred())
+					return scaleValidator.Handle(ctx, admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+						Namespace: obj.Namespace,
+						Name:      obj.Name,
+						Object:    apimachineryruntime.RawExtension{Raw: raw},
+					}})
+				}
+
+				Expect(scale(4).Allowed).To(BeTrue())
+				resp := scale(5)
+				Expect(resp.Allowed).To(BeFalse())
+				Expect(resp.Result.Message).To(ContainSubstring("namespace replicas would be 11, limited to 10"))
+			})
+
+			It("Should admit updates that do not grow usage even when over quota", func() {
+				oldObj.Spec.TotalQPS = ptr.To[int32](4000)
+				obj.Spec.TotalQPS = ptr.To[int32](3000)
+				Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+			})
+		})
+
+		It("Should deny a totalQPS below singlePodQPS unless it is 0", func() {
+			obj.Spec.TotalQPS = ptr.To[int32](100)
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.totalQPS: Invalid value: 100: must be 0 or at least singlePodQPS")))
+
+			obj.Spec.TotalQPS = ptr.To[int32](0)
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny negative replicas", func() {
+			obj.Spec.Replicas = ptr.To[int32](-1)
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.replicas: Invalid value: -1")))
+
+			obj.Spec.Replicas = ptr.To[int32](0)
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny an idle period that is not positive", func() {
+			obj.Spec.Idle = &elasticwebv1.ElasticWebSpecIdle{}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.idle.after: Invalid value: \"0s\": must be greater than 0")))
+
+			obj.Spec.Idle.After = metav1.Duration{Duration: 15 * time.Minute}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a capacity policy out of range", func() {
+			obj.Spec.CapacityPolicy = &elasticwebv1.ElasticWebSpecCapacityPolicy{
+				HeadroomPercent: -5,
+				SparePods:       101,
+				Zones:           17,
+				ZoneTopologyKey: "not a label",
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.capacityPolicy.headroomPercent: Invalid value: -5")))
+			Expect(err).To(MatchError(ContainSubstring("spec.capacityPolicy.sparePods: Invalid value: 101")))
+			Expect(err).To(MatchError(ContainSubstring("spec.capacityPolicy.zones: Invalid value: 17")))
+			Expect(err).To(MatchError(ContainSubstring("spec.capacityPolicy.zoneTopologyKey")))
+
+			obj.Spec.CapacityPolicy = &elasticwebv1.ElasticWebSpecCapacityPolicy{HeadroomPercent: 20, SparePods: 1, Zones: 3}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny calibration parameters out of range", func() {
+			obj.Spec.Calibration = &elasticwebv1.ElasticWebSpecCalibration{
+				Path:         "healthz",
+				LatencySLO:   &metav1.Duration{},
+				Percentile:   101,
+				MaxQPS:       2000,
+				StepDuration: &metav1.Duration{Duration: -time.Second},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.calibration.path: Invalid value: \"healthz\": must start with /")))
+			Expect(err).To(MatchError(ContainSubstring("spec.calibration.latencySLO: Invalid value: \"0s\"")))
+			Expect(err).To(MatchError(ContainSubstring("spec.calibration.percentile: Invalid value: 101")))
+			Expect(err).To(MatchError(ContainSubstring("spec.calibration.maxQPS: Invalid value: 2000")))
+			Expect(err).To(MatchError(ContainSubstring("spec.calibration.stepDuration: Invalid value: \"-1s\"")))
+
+			obj.Spec.Calibration = &elasticwebv1.ElasticWebSpecCalibration{Path: "/healthz", MaxQPS: 500, Apply: true}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny routes that cannot attach or receive traffic", func() {
+			obj.Spec.Route = &elasticwebv1.ElasticWebSpecRoute{
+				Hostnames:   []elasticwebv1.ElasticWebSpecRouteHostname{"Web_Example.com"},
+				Matches:     []elasticwebv1.ElasticWebSpecRoutePathMatch{{Type: "Regex", Value: "api"}},
+				ServicePort: ptr.To[int32](9090),
+				Weight:      ptr.To[int32](0),
+				Backends:    []elasticwebv1.ElasticWebSpecRouteBackend{{Name: "test", Port: 8080, Weight: ptr.To[int32](2000000)}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.route.parentRefs: Required value")))
+			Expect(err).To(MatchError(ContainSubstring("spec.route.hostnames[0]: Invalid value")))
+			Expect(err).To(MatchError(ContainSubstring("spec.route.matches[0].type: Unsupported value: \"Regex\"")))
+			Expect(err).To(MatchError(ContainSubstring("spec.route.matches[0].value: Invalid value: \"api\": must start with /")))
+			Expect(err).To(MatchError(ContainSubstring("spec.route.servicePort: Invalid value: 9090")))
+			Expect(err).To(MatchError(ContainSubstring("spec.route.backends[0].name: Duplicate value: \"test\"")))
+			Expect(err).To(MatchError(ContainSubstring("spec.route.backends[0].weight: Invalid value: 2000000")))
+
+			obj.Spec.Route = &elasticwebv1.ElasticWebSpecRoute{
+				ParentRefs: []elasticwebv1.ElasticWebSpecRouteParentRef{{Name: "gateway", Namespace: "infra"}},
+				Hostnames:  []elasticwebv1.ElasticWebSpecRouteHostname{"*.example.com"},
+				Weight:     ptr.To[int32](0),
+				Backends:   []elasticwebv1.ElasticWebSpecRouteBackend{{Name: "legacy", Port: 80, Weight: ptr.To[int32](0)}},
+			}
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("at least one backend must have a weight greater than 0")))
+
+			obj.Spec.Route.Weight = nil
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny placements that cannot run anywhere", func() {
+			obj.Spec.Placement = &elasticwebv1.ElasticWebSpecPlacement{
+				Clusters: []elasticwebv1.ElasticWebSpecPlacementCluster{
+					{Name: "East", Share: ptr.To[int32](0)},
+					{Name: "west", Share: ptr.To[int32](0)},
+					{Name: "west", Share: ptr.To[int32](200)},
+				},
+			}
+			obj.Spec.Calibration = &elasticwebv1.ElasticWebSpecCalibration{Path: "/healthz", MaxQPS: 500, Apply: true}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.placement.clusters[0].name: Invalid value: \"East\"")))
+			Expect(err).To(MatchError(ContainSubstring("spec.placement.clusters[2].name: Duplicate value: \"west\"")))
+			Expect(err).To(MatchError(ContainSubstring("spec.placement.clusters[2].share: Invalid value: 200")))
+			Expect(err).To(MatchError(ContainSubstring("spec.placement: Forbidden: cannot be combined with spec.calibration.apply")))
+
+			obj.Spec.Placement.Clusters = obj.Spec.Placement.Clusters[:2]
+			obj.Spec.Calibration = nil
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("at least one cluster must have a share greater than 0")))
+
+			obj.Spec.Placement.Clusters[0] = elasticwebv1.ElasticWebSpecPlacementCluster{Name: "east"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny network policy peers and egress rules the NetworkPolicy API rejects", func() {
+			obj.Spec.NetworkPolicy = &elasticwebv1.ElasticWebSpecNetworkPolicy{
+				From: []elasticwebv1.ElasticWebSpecNetworkPolicyPeer{
+					{},
+					{Namespaces: []string{"Frontend"}, NamespaceSelector: &metav1.LabelSelector{}},
+				},
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: ptr.To[corev1.Protocol]("ICMP")}},
+					To:    []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"192.168.0.0/16"}}}},
+				}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.networkPolicy.from[0]: Required value")))
+			Expect(err).To(MatchError(ContainSubstring("spec.networkPolicy.from[1].namespaceSelector: Forbidden")))
+			Expect(err).To(MatchError(ContainSubstring("spec.networkPolicy.from[1].namespaces[0]: Invalid value: \"Frontend\"")))
+			Expect(err).To(MatchError(ContainSubstring("spec.networkPolicy.egress[0].ports[0].protocol: Unsupported value: \"ICMP\"")))
+			Expect(err).To(MatchError(ContainSubstring("spec.networkPolicy.egress[0].to[0].ipBlock.except[0]: Invalid value")))
+
+			obj.Spec.NetworkPolicy = &elasticwebv1.ElasticWebSpecNetworkPolicy{
+				From: []elasticwebv1.ElasticWebSpecNetworkPolicyPeer{{Namespaces: []string{"frontend"}}},
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}}},
+				}},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		Context("with an image policy", func() {
+			BeforeEach(func() {
+				validator.ImagePolicy = &ImagePolicy{
+					Mode:                    ImagePolicyEnforce,
+					AllowedRegistries:       []string{"docker.io/library", "hub.autox.tech"},
+					ForbiddenTags:           []string{"latest"},
+					RequireDigestNamespaces: []string{"production"},
+				}
+			})
+
+			It("Should admit images from allowed registries", func() {
+				obj.Spec.Deploy[0].Image = "hub.autox.tech/web/tomcat:8.0.18-jre8"
+				Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+			})
+
+			It("Should deny images from other registries and forbidden tags", func() {
+				obj.Spec.Deploy[0].Image = "quay.io/web/tomcat:8.0.18-jre8"
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("spec.deploy[0].image: Forbidden: image quay.io/web/tomcat:8.0.18-jre8 is not from an allowed registry")))
+
+				obj.Spec.Deploy[0].Image = "tomcat"
+				_, err = validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring(`tag "latest" is forbidden`)))
+			})
+
+			It("Should require pinned images in the configured namespaces", func() {
+				obj.Namespace = "production"
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("images in namespace production must be pinned")))
+
+				obj.Spec.PinImageDigests = true
+				Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+			})
+
+			It("Should only check changed images on update", func() {
+				oldObj.Spec.Deploy[0].Image = "tomcat"
+				obj.Spec.Deploy[0].Image = "tomcat"
+				obj.Spec.SinglePodQPS = ptr.To[int32](600)
+				Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+			})
+
+			It("Should check unchanged images again when pinImageDigests is turned off", func() {
+				oldObj.Namespace = "production"
+				oldObj.Spec.PinImageDigests = true
+				obj.Namespace = "production"
+				_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+				Expect(err).To(MatchError(ContainSubstring("images in namespace production must be pinned")))
+			})
+
+			It("Should return warnings instead of errors in warn mode", func() {
+				validator.ImagePolicy.Mode = ImagePolicyWarn
+				obj.Spec.Deploy[0].Image = "quay.io/web/tomcat:latest"
+				warnings, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(warnings).To(HaveLen(2))
+				Expect(warnings[0]).To(HavePrefix("image policy (warn mode): spec.deploy[0].image"))
+			})
+		})
+
+		It("Should deny privileged containers without the opt-in annotation", func() {
+			obj.Spec.Deploy[0].SecurityContext = &corev1.SecurityContext{Privileged: ptr.To(true)}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.deploy[0].securityContext.privileged")))
+
+			obj.Annotations = map[string]string{elasticwebv1.AllowPrivilegedAnnotation: "true"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
 	})
 
 })
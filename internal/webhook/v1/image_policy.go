@@ -0,0 +1,151 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+
+	elasticwebv1 "elasticweb/api/v1"
+	"elasticweb/internal/registry"
+)
+
+// ImagePolicyMode decides what happens to images that violate the ImagePolicy.
+type ImagePolicyMode string
+
+const (
+	// ImagePolicyEnforce rejects the ElasticWeb.
+	ImagePolicyEnforce ImagePolicyMode = "Enforce"
+	// ImagePolicyWarn admits the ElasticWeb and returns the violations as admission warnings,
+	// for trying out a policy before enforcing it.
+	ImagePolicyWarn ImagePolicyMode = "Warn"
+)
+
+// ImagePolicy restricts the images an ElasticWeb may run.
+//
+// Example file, passed with --image-policy:
+//
+//	mode: Enforce
+//	allowedRegistries:
+//	- hub.autox.tech
+//	- docker.io/library
+//	forbiddenTags:
+//	- latest
+//	requireDigestNamespaces:
+//	- production
+type ImagePolicy struct {
+	// Mode is Enforce when empty.
+	Mode ImagePolicyMode `json:"mode,omitempty"`
+	// AllowedRegistries are registry hosts, optionally followed by a repository path prefix.
+	// Images without a registry are on docker.io. Any registry is allowed when empty.
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+	// ForbiddenTags are tags that may not be used. An image without tag or digest uses "latest".
+	ForbiddenTags []string `json:"forbiddenTags,omitempty"`
+	// RequireDigestNamespaces are namespaces where images must be pinned, either by writing
+	// image@sha256:... or by setting spec.pinImageDigests.
+	RequireDigestNamespaces []string `json:"requireDigestNamespaces,omitempty"`
+}
+
+// LoadImagePolicy reads an ImagePolicy from a YAML file.
+func LoadImagePolicy(path string) (*ImagePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &ImagePolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("parse image policy %s: %w", path, err)
+	}
+	switch policy.Mode {
+	case "":
+		policy.Mode = ImagePolicyEnforce
+	case ImagePolicyEnforce, ImagePolicyWarn:
+	default:
+		return nil, fmt.Errorf("image policy %s: unsupported mode %q", path, policy.Mode)
+	}
+	return policy, nil
+}
+
+// validate checks the images of newObj. On update only images that changed are checked,
+// so tightening the policy does not block unrelated edits of existing ElasticWebs; all
+// images are checked again when spec.pinImageDigests changes, since turning it off
+// leaves tagged images unpinned.
+// In Warn mode the violations are returned as warnings instead of errors.
+func (p *ImagePolicy) validate(oldObj, newObj *elasticwebv1.ElasticWeb) (field.ErrorList, admission.Warnings) {
+	if p == nil {
+		return nil, nil
+	}
+
+	var allErrs field.ErrorList
+	requireDigest := slices.Contains(p.RequireDigestNamespaces, newObj.Namespace) && !newObj.Spec.PinImageDigests
+	pinUnchanged := oldObj != nil && oldObj.Spec.PinImageDigests == newObj.Spec.PinImageDigests
+	for i, d := range newObj.Spec.Deploy {
+		if pinUnchanged && slices.ContainsFunc(oldObj.Spec.Deploy, func(od elasticwebv1.ElasticWebSpecDeploy) bool {
+			return od.Name == d.Name && od.Image == d.Image
+		}) {
+			continue
+		}
+
+		imagePath := field.NewPath("spec").Child("deploy").Index(i).Child("image")
+		ref, err := registry.ParseReference(d.Image)
+		if err != nil {
+			// an empty image is reported by validateDeploy
+			continue
+		}
+
+		if len(p.AllowedRegistries) > 0 && !slices.ContainsFunc(p.AllowedRegistries, func(allowed string) bool {
+			return allowedBy(ref, allowed)
+		}) {
+			allErrs = append(allErrs, field.Forbidden(imagePath,
+				fmt.Sprintf("image %s is not from an allowed registry %v", d.Image, p.AllowedRegistries)))
+		}
+		if ref.Tagged() && slices.Contains(p.ForbiddenTags, ref.Tag) {
+			allErrs = append(allErrs, field.Forbidden(imagePath,
+				fmt.Sprintf("tag %q is forbidden, use a fixed version or a digest", ref.Tag)))
+		}
+		if requireDigest && ref.Tagged() {
+			allErrs = append(allErrs, field.Forbidden(imagePath,
+				fmt.Sprintf("images in namespace %s must be pinned: use image@sha256:... or set spec.pinImageDigests", newObj.Namespace)))
+		}
+	}
+
+	if p.Mode != ImagePolicyWarn {
+		return allErrs, nil
+	}
+	var warnings admission.Warnings
+	for _, err := range allErrs {
+		warnings = append(warnings, "image policy (warn mode): "+err.Error())
+	}
+	return nil, warnings
+}
+
+// allowedBy reports whether ref is covered by an AllowedRegistries entry: either the
+// registry host itself, or the host followed by a repository path prefix.
+func allowedBy(ref registry.Reference, allowed string) bool {
+	allowed = strings.TrimSuffix(allowed, "/")
+	host, prefix, hasPrefix := strings.Cut(allowed, "/")
+	if host != ref.Registry {
+		return false
+	}
+	return !hasPrefix || ref.Repository == prefix || strings.HasPrefix(ref.Repository, prefix+"/")
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	elasticwebv1 "elasticweb/api/v1"
+)
+
+// validateQuota checks that admitting newObj keeps the namespace within every ElasticWebQuota
+// in it. oldObj is nil on create. Updates that do not grow the object's usage are always
+// admitted, so lowering a quota below current usage does not block unrelated edits.
+//
+// Like ResourceQuota, usage is computed from a list at admission time, so two concurrent
+// creates can together overshoot a quota; the ElasticWebQuota status shows the real usage.
+func validateQuota(ctx context.Context, c client.Reader, oldObj, newObj *elasticwebv1.ElasticWeb) (field.ErrorList, error) {
+	var allErrs field.ErrorList
+	if c == nil {
+		return allErrs, nil
+	}
+
+	newQPS, newReplicas := newObj.QuotaUsage()
+	if oldObj != nil {
+		oldQPS, oldReplicas := oldObj.QuotaUsage()
+		if newQPS <= oldQPS && newReplicas <= oldReplicas {
+			return allErrs, nil
+		}
+	}
+
+	quotas := &elasticwebv1.ElasticWebQuotaList{}
+	if err := c.List(ctx, quotas, client.InNamespace(newObj.Namespace)); err != nil {
+		return nil, fmt.Errorf("list ElasticWebQuotas: %w", err)
+	}
+	if len(quotas.Items) == 0 {
+		return allErrs, nil
+	}
+
+	elasticwebs := &elasticwebv1.ElasticWebList{}
+	if err := c.List(ctx, elasticwebs, client.InNamespace(newObj.Namespace)); err != nil {
+		return nil, fmt.Errorf("list ElasticWebs: %w", err)
+	}
+	usedQPS, usedReplicas := newQPS, newReplicas
+	for i := range elasticwebs.Items {
+		other := &elasticwebs.Items[i]
+		if other.Name == newObj.Name || !other.DeletionTimestamp.IsZero() {
+			continue
+		}
+		qps, replicas := other.QuotaUsage()
+		usedQPS += qps
+		usedReplicas += replicas
+	}
+
+	// replicas follow spec.replicas when it is set, and spec.totalQPS otherwise
+	specPath := field.NewPath("spec")
+	replicasPath := specPath
+	if newObj.Spec.Replicas != nil {
+		replicasPath = specPath.Child("replicas")
+	}
+	for _, quota := range quotas.Items {
+		if max := quota.Spec.MaxTotalQPS; max != nil && usedQPS > *max {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("totalQPS"),
+				fmt.Sprintf("exceeds ElasticWebQuota %s: namespace totalQPS would be %d, limited to %d", quota.Name, usedQPS, *max)))
+		}
+		if max := quota.Spec.MaxReplicas; max != nil && usedReplicas > *max {
+			allErrs = append(allErrs, field.Forbidden(replicasPath,
+				fmt.Sprintf("exceeds ElasticWebQuota %s: namespace replicas would be %d, limited to %d", quota.Name, usedReplicas, *max)))
+		}
+	}
+	return allErrs, nil
+}
+
+// ScaleWebhookPath is where ElasticWebScaleValidator is served.
+const ScaleWebhookPath = "/validate-elasticweb-com-bolingcavalry-v1-elasticweb-scale"
+
+// +kubebuilder:webhook:path=/validate-elasticweb-com-bolingcavalry-v1-elasticweb-scale,mutating=false,failurePolicy=fail,sideEffects=None,groups=elasticweb.com.bolingcavalry,resources=elasticwebs/scale,verbs=update,versions=v1,name=vscaleelasticweb-v1.kb.io,admissionReviewVersions=v1
+
+// ElasticWebScaleValidator enforces ElasticWebQuotas on the scale subresource. Requests to
+// elasticwebs/scale, e.g. from kubectl scale or a HorizontalPodAutoscaler, write spec.replicas
+// without going through the ElasticWeb validating webhook.
+type ElasticWebScaleValidator struct {
+	// Client reads the ElasticWeb being scaled and the quotas and ElasticWebs in its namespace.
+	Client client.Reader
+}
+
+var _ admission.Handler = &ElasticWebScaleValidator{}
+
+// Handle implements admission.Handler.
+func (v *ElasticWebScaleValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	scale := &autoscalingv1.Scale{}
+	if err := json.Unmarshal(req.Object.Raw, scale); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	oldObj := &elasticwebv1.ElasticWeb{}
+	if err := v.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, oldObj); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	newObj := oldObj.DeepCopy()
+	newObj.Spec.Replicas = &scale.Spec.Replicas
+
+	allErrs, err := validateQuota(ctx, v.Client, oldObj, newObj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(allErrs) > 0 {
+		return admission.Denied(allErrs.ToAggregate().Error())
+	}
+	return admission.Allowed("")
+}
@@ -32,6 +32,7 @@ import (
 	admissionv1 "k8s.io/api/admission/v1"
 
 	elasticwebv1 "elasticweb/api/v1"
+	elasticwebv2 "elasticweb/api/v2"
 
 	// +kubebuilder:scaffold:imports
 	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
@@ -95,6 +96,9 @@ var _ = BeforeSuite(func() {
 	err = elasticwebv1.AddToScheme(scheme)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = elasticwebv2.AddToScheme(scheme)
+	Expect(err).NotTo(HaveOccurred())
+
 	err = admissionv1.AddToScheme(scheme)
 	Expect(err).NotTo(HaveOccurred())
 
@@ -118,7 +122,7 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
-	err = SetupElasticWebWebhookWithManager(mgr)
+	err = SetupElasticWebWebhookWithManager(mgr, WebhookOptions{})
 	Expect(err).NotTo(HaveOccurred())
 
 	// +kubebuilder:scaffold:webhook